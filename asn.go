@@ -0,0 +1,131 @@
+package qwis
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ASWhoisResponse struct {
+	rawText      []byte
+	Query        string    `json:"query"`
+	WhoisServer  string    `json:"whois_server"`
+	ASNumber     string    `json:"as_number"`
+	ASName       string    `json:"as_name"`
+	Organization string    `json:"organization"`
+	Country      string    `json:"country,omitempty"`
+	CreationDate string    `json:"creation_date,omitempty"`
+	UpdatedDate  string    `json:"updated_date,omitempty"`
+	CreationTime time.Time `json:"-"`
+	UpdatedTime  time.Time `json:"-"`
+}
+
+// ASNumber returns the number of an "AS15169"-style query.
+func ASNumber(s string) (uint32, bool) {
+	if len(s) < 3 || !strings.EqualFold(s[:2], "as") {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s[2:], 10, 32)
+	return uint32(n), err == nil
+}
+
+func IsASNQuery(s string) bool {
+	_, ok := ASNumber(s)
+	return ok
+}
+
+func asnQuery(server, q string) []byte {
+	n, _ := ASNumber(q)
+	if serverHost(server) == "whois.arin.net" {
+		return []byte(fmt.Sprintf("a + %d\r\n", n))
+	}
+	return []byte(fmt.Sprintf("AS%d\r\n", n))
+}
+
+func ParseASResponse(raw []byte) *ASWhoisResponse {
+	r := &ASWhoisResponse{rawText: raw}
+	first := func(dst *string, v string) {
+		if len(*dst) == 0 {
+			*dst = v
+		}
+	}
+	var descr string
+	keyValues(raw, func(k, v string) {
+		switch k {
+		case "asnumber", "aut-num":
+			first(&r.ASNumber, strings.ToUpper(v))
+		case "asname", "as-name":
+			first(&r.ASName, v)
+		case "orgname", "org-name", "owner":
+			first(&r.Organization, v)
+		case "descr":
+			first(&descr, v)
+		case "country":
+			first(&r.Country, strings.ToUpper(v))
+		case "regdate", "created":
+			first(&r.CreationDate, v)
+		case "updated", "last-modified", "changed":
+			first(&r.UpdatedDate, v)
+		}
+	})
+	first(&r.Organization, descr)
+	if len(r.ASNumber) != 0 && !strings.HasPrefix(r.ASNumber, "AS") {
+		r.ASNumber = "AS" + r.ASNumber
+	}
+	for _, d := range []struct {
+		s *string
+		t *time.Time
+	}{
+		{&r.CreationDate, &r.CreationTime},
+		{&r.UpdatedDate, &r.UpdatedTime},
+	} {
+		if t, ok := ParseDate(*d.s, ""); ok {
+			*d.t = t
+			if !KeepRawDates {
+				*d.s = t.Format(time.RFC3339)
+			}
+		}
+	}
+	return r
+}
+
+func ASWhoisRawContext(ctx context.Context, q string) ([]byte, error) {
+	if !IsASNQuery(q) {
+		return nil, fmt.Errorf("Whois: %s is not an AS number", q)
+	}
+	res, _, err := queryWithReferrals(ctx, q, asnQuery)
+	return res, err
+}
+
+func ASWhoisContext(ctx context.Context, q string) (*ASWhoisResponse, error) {
+	if !IsASNQuery(q) {
+		return nil, fmt.Errorf("Whois: %s is not an AS number", q)
+	}
+	res, server, err := queryWithReferrals(ctx, q, asnQuery)
+	if err != nil {
+		return nil, err
+	}
+	r := ParseASResponse(res)
+	r.Query, r.WhoisServer = q, server
+	return r, nil
+}
+
+func ASWhois(q string) (*ASWhoisResponse, error) {
+	return ASWhoisContext(context.Background(), q)
+}
+
+func (r *ASWhoisResponse) Raw() []byte {
+	return r.rawText
+}
+
+func (r *ASWhoisResponse) WriteAsJSON(w io.Writer) error {
+	return WriteIndentedJSON(w, r)
+}
+
+func (r *ASWhoisResponse) WriteAsRawText(w io.Writer) (err error) {
+	_, err = w.Write(r.rawText)
+	return
+}
@@ -0,0 +1,79 @@
+package qwis
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var notFoundPhrases = [][]byte{
+	[]byte("no match for"),
+	[]byte("no match!!"),
+	[]byte("not found"),
+	[]byte("no data found"),
+	[]byte("no entries found"),
+	[]byte("no object found"),
+	[]byte("nothing found"),
+	[]byte("object does not exist"),
+	[]byte("domain not registered"),
+	[]byte("is available for registration"),
+	[]byte("is free"),
+	[]byte("status: free"),
+	[]byte("status: available"),
+	[]byte("no information available"),
+	[]byte("the domain has not been registered"),
+	[]byte("does not exist in database"),
+}
+
+// IsAvailable reports whether the response is a registry's "not found"
+// answer, i.e. the domain is not registered.
+func (wir *WhoisResponse) IsAvailable() bool {
+	if len(wir.CreationDate) != 0 || len(wir.Registrar) != 0 || len(wir.NameServers) != 0 {
+		return false
+	}
+	raw := bytes.ToLower(wir.rawText)
+	for _, p := range notFoundPhrases {
+		if bytes.Contains(raw, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (wir *WhoisResponse) WriteAsAvailability(w io.Writer) (err error) {
+	a := "registered"
+	if wir.IsAvailable() {
+		a = "available"
+	}
+	_, err = fmt.Fprintln(w, a)
+	return
+}
+
+func IsAvailableContext(ctx context.Context, domainName string) (bool, error) {
+	domainName, err := asciiDomainName(domainName)
+	if err != nil {
+		return false, fmt.Errorf("IsAvailable: %w", err)
+	}
+	if IsRDAPOnly(TopLevelDomain(domainName)) {
+		_, err = RDAPRawContext(ctx, domainName)
+		if errors.Is(err, ErrNoSuchDomain) {
+			return true, nil
+		}
+		return false, err
+	}
+	res, server, err := WhoisRawServerContext(ctx, domainName)
+	if err != nil {
+		return false, err
+	}
+	wir, err := parseServerResponse(res, server, domainName, multiDomain(ctx))
+	if err != nil {
+		return false, err
+	}
+	return wir.IsAvailable(), nil
+}
+
+func IsAvailable(domainName string) (bool, error) {
+	return IsAvailableContext(context.Background(), domainName)
+}
@@ -0,0 +1,249 @@
+package qwis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+type LookupFunc func(ctx context.Context, domainName string) (*WhoisResponse, error)
+
+// BatchResult holds the outcome of one batch query: Response for a domain
+// name, IP for an IP address or CIDR block, AS for an AS number.
+type BatchResult struct {
+	Domain   string
+	Response *WhoisResponse
+	IP       *IPWhoisResponse
+	AS       *ASWhoisResponse
+	Err      error
+}
+
+func (br BatchResult) MarshalJSON() ([]byte, error) {
+	v := struct {
+		Domain   string      `json:"domain"`
+		Response interface{} `json:"response,omitempty"`
+		Error    string      `json:"error,omitempty"`
+	}{Domain: br.Domain}
+	switch {
+	case br.Response != nil:
+		v.Response = br.Response
+	case br.IP != nil:
+		v.Response = br.IP
+	case br.AS != nil:
+		v.Response = br.AS
+	}
+	if br.Err != nil {
+		v.Error = br.Err.Error()
+	}
+	return json.Marshal(v)
+}
+
+// batchQuery looks q up as an IP address or CIDR block, as an AS number or,
+// with lookup, as a domain name.
+func batchQuery(ctx context.Context, q string, lookup LookupFunc) BatchResult {
+	r := BatchResult{Domain: q}
+	switch {
+	case IsIPQuery(q):
+		r.IP, r.Err = IPWhoisContext(ctx, q)
+	case IsASNQuery(q):
+		r.AS, r.Err = ASWhoisContext(ctx, q)
+	default:
+		r.Response, r.Err = lookup(ctx, q)
+	}
+	return r
+}
+
+// BatchLookup queries domains with up to concurrency lookups at a time,
+// sending IP addresses, CIDR blocks and AS numbers to IP and AS whois.
+func BatchLookup(ctx context.Context, domains []string, concurrency int, lookup LookupFunc) []BatchResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]BatchResult, len(domains))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = batchQuery(ctx, domains[i], lookup)
+			}
+		}()
+	}
+	for i := range domains {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+// RegistrableDomain returns the name registered under a public suffix, e.g.
+// example.co.uk for www.example.co.uk.
+func RegistrableDomain(domainName string) (string, error) {
+	a, err := ToASCII(strings.TrimSuffix(domainName, "."))
+	if err != nil {
+		return "", fmt.Errorf("RegistrableDomain: %w", err)
+	}
+	rd, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(a))
+	if err != nil {
+		return "", fmt.Errorf("RegistrableDomain: %w", err)
+	}
+	return rd, nil
+}
+
+// ExtractDomain returns the registrable domain of the host of a URL, such as
+// example.com for https://www.example.com/path?x=1, or of the domain of an
+// email address. Other queries, and URLs of IP addresses, are returned
+// without the registrable domain taken.
+func ExtractDomain(q string) string {
+	q = strings.TrimSpace(q)
+	host := ""
+	switch {
+	case IsIPQuery(q):
+		return q
+	case strings.Contains(q, "://"):
+		if u, err := url.Parse(q); err == nil {
+			host = u.Hostname()
+		}
+	case strings.Contains(q, "@"):
+		host = strings.TrimRight(q[strings.LastIndexByte(q, '@')+1:], ">")
+	case strings.ContainsAny(q, "/?#"):
+		if u, err := url.Parse("http://" + q); err == nil {
+			host = u.Hostname()
+		}
+	default:
+		return q
+	}
+	if len(host) == 0 {
+		return q
+	}
+	if IsIPQuery(host) {
+		return host
+	}
+	if rd, err := RegistrableDomain(host); err == nil {
+		return rd
+	}
+	return host
+}
+
+// BatchLookupRegistrable looks up every distinct registrable domain among
+// domains once and maps each input to the result of its parent, so
+// a.example.com and b.example.com share a single example.com lookup. IP and
+// AS number queries are looked up as they are.
+func BatchLookupRegistrable(ctx context.Context, domains []string, concurrency int, lookup LookupFunc) []BatchResult {
+	parents := make([]string, len(domains))
+	index := map[string]int{}
+	var unique []string
+	for i, dn := range domains {
+		parents[i] = dn
+		if !IsIPQuery(dn) && !IsASNQuery(dn) {
+			if rd, err := RegistrableDomain(dn); err == nil {
+				parents[i] = rd
+			}
+		}
+		if _, ok := index[parents[i]]; !ok {
+			index[parents[i]] = len(unique)
+			unique = append(unique, parents[i])
+		}
+	}
+	shared := BatchLookup(ctx, unique, concurrency, lookup)
+	results := make([]BatchResult, len(domains))
+	for i, dn := range domains {
+		results[i] = shared[index[parents[i]]]
+		results[i].Domain = dn
+	}
+	return results
+}
+
+const (
+	SortByExpiration = "expiration"
+	SortByDomain     = "domain"
+	SortByRegistrar  = "registrar"
+)
+
+// SortBatchResults orders results in place by SortByExpiration (soonest
+// first), SortByDomain or SortByRegistrar. Failed lookups and results
+// lacking the sort key go last, in their original order.
+func SortBatchResults(results []BatchResult, by string) error {
+	var (
+		known func(r BatchResult) bool
+		less  func(a, b BatchResult) bool
+	)
+	switch by {
+	case SortByExpiration:
+		known = func(r BatchResult) bool {
+			return r.Err == nil && r.Response != nil && !r.Response.ExpirationTime.IsZero()
+		}
+		less = func(a, b BatchResult) bool { return a.Response.ExpirationTime.Before(b.Response.ExpirationTime) }
+	case SortByDomain:
+		known = func(r BatchResult) bool { return true }
+		less = func(a, b BatchResult) bool { return strings.ToLower(a.Domain) < strings.ToLower(b.Domain) }
+	case SortByRegistrar:
+		known = func(r BatchResult) bool { return r.Err == nil && r.Response != nil && len(r.Response.Registrar) != 0 }
+		less = func(a, b BatchResult) bool {
+			return strings.ToLower(a.Response.Registrar) < strings.ToLower(b.Response.Registrar)
+		}
+	default:
+		return fmt.Errorf("SortBatchResults: unknown sort key %q", by)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if ki, kj := known(results[i]), known(results[j]); !ki || !kj {
+			return ki && !kj
+		}
+		return less(results[i], results[j])
+	})
+	return nil
+}
+
+func WhoisBatch(ctx context.Context, domains []string, concurrency int) []BatchResult {
+	return BatchLookup(ctx, domains, concurrency, WhoisContext)
+}
+
+func WhoisBatchChan(ctx context.Context, domains <-chan string, concurrency int) <-chan BatchResult {
+	return BatchLookupChan(ctx, domains, concurrency, WhoisContext)
+}
+
+// BatchLookupChan is BatchLookup for queries that arrive over time: it
+// sends each result as soon as its lookup completes and closes the results
+// once domains is closed and drained, or ctx is done.
+func BatchLookupChan(ctx context.Context, domains <-chan string, concurrency int, lookup LookupFunc) <-chan BatchResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make(chan BatchResult)
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case dn, ok := <-domains:
+					if !ok {
+						return
+					}
+					select {
+					case results <- batchQuery(ctx, dn, lookup):
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
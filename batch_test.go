@@ -0,0 +1,188 @@
+package qwis
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkorotkov/qwis/internal/whoistest"
+)
+
+func TestBatchLookupRegistrable(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		queried []string
+	)
+	lookup := func(ctx context.Context, dn string) (*WhoisResponse, error) {
+		mu.Lock()
+		queried = append(queried, dn)
+		mu.Unlock()
+		return &WhoisResponse{DomainName: dn}, nil
+	}
+	results := BatchLookupRegistrable(context.Background(), []string{"a.example.com", "b.example.com"}, 4, lookup)
+	if len(queried) != 1 || queried[0] != "example.com" {
+		t.Fatalf("queried %q, want a single example.com lookup", queried)
+	}
+	for i, want := range []string{"a.example.com", "b.example.com"} {
+		if results[i].Domain != want || results[i].Response.DomainName != "example.com" {
+			t.Errorf("result %d = %s -> %+v", i, results[i].Domain, results[i].Response)
+		}
+	}
+}
+
+func TestRegistrableDomain(t *testing.T) {
+	for in, want := range map[string]string{
+		"www.example.co.uk": "example.co.uk",
+		"a.b.example.com.":  "example.com",
+		"Example.ORG":       "example.org",
+	} {
+		if got, err := RegistrableDomain(in); err != nil || got != want {
+			t.Errorf("RegistrableDomain(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+}
+
+func TestExtractDomain(t *testing.T) {
+	for in, want := range map[string]string{
+		"https://www.example.com/path?x=1":        "example.com",
+		"http://user:pw@shop.example.co.uk:8080/": "example.co.uk",
+		"user@mail.example.org":                   "example.org",
+		"Jane <jane@example.net>":                 "example.net",
+		"www.example.com/index.html":              "example.com",
+		"https://192.0.2.1/":                      "192.0.2.1",
+		"192.0.2.0/24":                            "192.0.2.0/24",
+		"www.example.com":                         "www.example.com",
+		"AS3333":                                  "AS3333",
+	} {
+		if got := ExtractDomain(in); got != want {
+			t.Errorf("ExtractDomain(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestWhoisBatchChan(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{
+		"whois.verisign-grs.com:43": "Domain Name: EXAMPLE.COM\r\nRegistrar: Example Registrar, Inc.\r\n",
+	}}
+	useDial(t, fs.Dial)
+	want := []string{"a.com", "b.com", "c.com", "d.com", "e.com"}
+	domains := make(chan string)
+	go func() {
+		for _, dn := range want {
+			domains <- dn
+		}
+		close(domains)
+	}()
+	var got []string
+	for r := range WhoisBatchChan(context.Background(), domains, 2) {
+		if r.Err != nil || r.Response.Registrar != "Example Registrar, Inc." {
+			t.Errorf("%s: %+v, %v", r.Domain, r.Response, r.Err)
+		}
+		got = append(got, r.Domain)
+	}
+	sort.Strings(got)
+	if len(got) != len(want) {
+		t.Fatalf("got results for %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got results for %q, want %q", got, want)
+			break
+		}
+	}
+}
+
+func TestWhoisBatchChanCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// An input channel that is never closed must not keep the results open
+	// once ctx is done.
+	for r := range WhoisBatchChan(ctx, make(chan string), 2) {
+		t.Errorf("unexpected result %+v", r)
+	}
+}
+
+func TestSortBatchResults(t *testing.T) {
+	expiring := func(dn, registrar, expiry string) BatchResult {
+		wir := &WhoisResponse{DomainName: dn, Registrar: registrar}
+		wir.ExpirationTime, _ = ParseDate(expiry, "")
+		return BatchResult{Domain: dn, Response: wir}
+	}
+	results := []BatchResult{
+		expiring("c.com", "Beta", "2027-01-01"),
+		{Domain: "failed.com", Err: ErrServerUnavailable},
+		expiring("a.com", "gamma", ""),
+		expiring("B.com", "alpha", "2026-06-01"),
+	}
+	for by, want := range map[string][]string{
+		SortByExpiration: {"B.com", "c.com", "failed.com", "a.com"},
+		SortByDomain:     {"a.com", "B.com", "c.com", "failed.com"},
+		SortByRegistrar:  {"B.com", "c.com", "a.com", "failed.com"},
+	} {
+		sorted := append([]BatchResult(nil), results...)
+		if err := SortBatchResults(sorted, by); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range sorted {
+			got = append(got, r.Domain)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("sorted by %s: %q, want %q", by, got, want)
+		}
+	}
+	if err := SortBatchResults(results, "size"); err == nil {
+		t.Error("unknown sort key accepted")
+	}
+}
+
+func TestBatchLookupRoutesIPAndASN(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{
+		"whois.iana.org:43": "inetnum: 192.0.2.0 - 192.0.2.255\nnetname: TEST-NET-1\naut-num: AS64496\nas-name: DOC-AS\n",
+	}}
+	useDial(t, fs.Dial)
+	var looked []string
+	lookup := func(ctx context.Context, dn string) (*WhoisResponse, error) {
+		looked = append(looked, dn)
+		return &WhoisResponse{DomainName: dn}, nil
+	}
+	results := BatchLookup(context.Background(), []string{"192.0.2.1", "AS64496", "example.com"}, 1, lookup)
+	if len(looked) != 1 || looked[0] != "example.com" {
+		t.Errorf("domain lookup called for %q", looked)
+	}
+	if r := results[0]; r.Err != nil || r.IP == nil || r.IP.NetName != "TEST-NET-1" || r.Response != nil {
+		t.Errorf("IP result %+v", r)
+	}
+	if r := results[1]; r.Err != nil || r.AS == nil || r.AS.ASName != "DOC-AS" || r.Response != nil {
+		t.Errorf("AS result %+v", r)
+	}
+	if r := results[2]; r.Err != nil || r.Response == nil || r.IP != nil || r.AS != nil {
+		t.Errorf("domain result %+v", r)
+	}
+	if err := SortBatchResults(results, SortByExpiration); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBatchLookupChan(t *testing.T) {
+	domains := make(chan string, 2)
+	domains <- "a.test"
+	domains <- "b.test"
+	close(domains)
+	lookup := func(ctx context.Context, dn string) (*WhoisResponse, error) {
+		return &WhoisResponse{DomainName: strings.ToUpper(dn)}, nil
+	}
+	n := 0
+	for r := range BatchLookupChan(context.Background(), domains, 1, lookup) {
+		if r.Err != nil || r.Response.DomainName != strings.ToUpper(r.Domain) {
+			t.Errorf("%s: %+v, %v", r.Domain, r.Response, r.Err)
+		}
+		n++
+	}
+	if n != 2 {
+		t.Errorf("got %d results, want 2", n)
+	}
+}
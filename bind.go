@@ -0,0 +1,40 @@
+package qwis
+
+import (
+	"fmt"
+	"net"
+)
+
+// BindInterface sets Dialer.LocalAddr to an address of the named network
+// interface, so that registries see queries come from that address. The
+// address is of the family Network keeps to, or IPv4 when there is one and
+// Network allows either.
+func BindInterface(name string) error {
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return fmt.Errorf("BindInterface: %w", err)
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return fmt.Errorf("BindInterface: %w", err)
+	}
+	var ip net.IP
+	for _, a := range addrs {
+		ipn, ok := a.(*net.IPNet)
+		if !ok || ipn.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		v4 := ipn.IP.To4() != nil
+		if Network == "tcp4" && !v4 || Network == "tcp6" && v4 {
+			continue
+		}
+		if ip == nil || ip.To4() == nil && v4 {
+			ip = ipn.IP
+		}
+	}
+	if ip == nil {
+		return fmt.Errorf("BindInterface: %s has no usable address", name)
+	}
+	Dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	return nil
+}
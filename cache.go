@@ -0,0 +1,166 @@
+package qwis
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Cache stores raw server responses keyed by server and query.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, raw []byte)
+}
+
+// ResponseCache, when set, is consulted before any whois or RDAP server is
+// queried.
+var ResponseCache Cache
+
+// Now is the clock of the package functions: responses in MemoryCache and
+// DiskCache expire by it, as do the TLDs IANA lists without a whois server.
+// Tests set it to freeze time; a Client has WithClock.
+var Now = time.Now
+
+// clockedCache is a Cache whose entries expire by the clock of the lookup
+// rather than Now.
+type clockedCache interface {
+	getAt(key string, now time.Time) ([]byte, bool)
+	setAt(key string, raw []byte, now time.Time)
+}
+
+func cacheGet(ctx context.Context, cache Cache, key string) ([]byte, bool) {
+	if cc, ok := cache.(clockedCache); ok {
+		return cc.getAt(key, clock(ctx)())
+	}
+	return cache.Get(key)
+}
+
+func cacheSet(ctx context.Context, cache Cache, key string, raw []byte) {
+	if cc, ok := cache.(clockedCache); ok {
+		cc.setAt(key, raw, clock(ctx)())
+		return
+	}
+	cache.Set(key, raw)
+}
+
+type memoryEntry struct {
+	raw     []byte
+	expires time.Time
+}
+
+// MemoryCache keeps responses in memory for its TTL. Expired entries are
+// dropped when they are asked for and, so that those never asked for again
+// don't pile up, swept out by Set at most once per TTL.
+type MemoryCache struct {
+	sync.Mutex
+	ttl     time.Duration
+	entries map[string]memoryEntry
+	swept   time.Time
+}
+
+func NewMemoryCache(ttl time.Duration) *MemoryCache {
+	return &MemoryCache{ttl: ttl, entries: map[string]memoryEntry{}}
+}
+
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	return c.getAt(key, Now())
+}
+
+func (c *MemoryCache) getAt(key string, now time.Time) ([]byte, bool) {
+	c.Lock()
+	defer c.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if now.After(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.raw, true
+}
+
+func (c *MemoryCache) Set(key string, raw []byte) {
+	c.setAt(key, raw, Now())
+}
+
+func (c *MemoryCache) setAt(key string, raw []byte, now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	if now.Sub(c.swept) >= c.ttl {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.swept = now
+	}
+	c.entries[key] = memoryEntry{raw, now.Add(c.ttl)}
+}
+
+type DiskCache struct {
+	dir string
+	ttl time.Duration
+}
+
+// DefaultCacheDir returns qwis' directory under the user cache directory
+// ($XDG_CACHE_HOME on Linux).
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "qwis"), nil
+}
+
+func NewDiskCache(dir string, ttl time.Duration) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &DiskCache{dir: dir, ttl: ttl}, nil
+}
+
+func (c *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+func (c *DiskCache) Get(key string) ([]byte, bool) {
+	return c.getAt(key, Now())
+}
+
+func (c *DiskCache) getAt(key string, now time.Time) ([]byte, bool) {
+	p := c.path(key)
+	fi, err := os.Stat(p)
+	if err != nil || now.Sub(fi.ModTime()) > c.ttl {
+		return nil, false
+	}
+	raw, err := os.ReadFile(p)
+	return raw, err == nil
+}
+
+func (c *DiskCache) Set(key string, raw []byte) {
+	c.setAt(key, raw, Now())
+}
+
+// setAt stamps the entry with now, which its age is taken from.
+func (c *DiskCache) setAt(key string, raw []byte, now time.Time) {
+	tmp, err := os.CreateTemp(c.dir, "tmp-")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(raw)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chtimes(tmp.Name(), now, now)
+	}
+	if err != nil || os.Rename(tmp.Name(), c.path(key)) != nil {
+		os.Remove(tmp.Name())
+	}
+}
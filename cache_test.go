@@ -0,0 +1,26 @@
+package qwis
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheSweep(t *testing.T) {
+	c := NewMemoryCache(50 * time.Millisecond)
+	for i := 0; i < 100; i++ {
+		c.Set(fmt.Sprint("old", i), []byte("answer"))
+	}
+	if raw, ok := c.Get("old1"); !ok || string(raw) != "answer" {
+		t.Fatalf("Get = %q, %v", raw, ok)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, ok := c.Get("old1"); ok {
+		t.Error("Get returned an expired entry")
+	}
+	c.Set("new", []byte("answer"))
+	c.Set("newer", []byte("answer"))
+	if n := len(c.entries); n != 2 {
+		t.Errorf("%d entries after the sweep, want 2", n)
+	}
+}
@@ -0,0 +1,76 @@
+package qwis
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"time"
+)
+
+// CertificateInfo describes the leaf certificate a host serves on port 443.
+// Verified says whether it chains to RootCAs, or the system roots, and is
+// valid for the host; VerifyError says why not.
+type CertificateInfo struct {
+	Subject     string   `json:"subject"`
+	Issuer      string   `json:"issuer"`
+	SANs        []string `json:"sans,omitempty"`
+	NotBefore   string   `json:"not_before"`
+	NotAfter    string   `json:"not_after"`
+	Verified    bool     `json:"verified"`
+	VerifyError string   `json:"verify_error,omitempty"`
+}
+
+// FetchCertificate connects to host on port 443 with Dial and returns the
+// leaf certificate of the TLS handshake, accepted whether or not it
+// verifies.
+func FetchCertificate(ctx context.Context, host string) (*CertificateInfo, error) {
+	re := func(e error) error {
+		return fmt.Errorf("FetchCertificate: %w", e)
+	}
+	conn, err := dialFunc(ctx)(ctx, dialNetwork(ctx), net.JoinHostPort(host, "443"))
+	if err != nil {
+		return nil, re(err)
+	}
+	defer conn.Close()
+	tc := tls.Client(conn, &tls.Config{ServerName: host, InsecureSkipVerify: true})
+	if err = tc.HandshakeContext(ctx); err != nil {
+		return nil, re(err)
+	}
+	certs := tc.ConnectionState().PeerCertificates
+	leaf := certs[0]
+	ci := &CertificateInfo{
+		Subject:   leaf.Subject.String(),
+		Issuer:    leaf.Issuer.String(),
+		SANs:      leaf.DNSNames,
+		NotBefore: leaf.NotBefore.UTC().Format(time.RFC3339),
+		NotAfter:  leaf.NotAfter.UTC().Format(time.RFC3339),
+	}
+	for _, ip := range leaf.IPAddresses {
+		ci.SANs = append(ci.SANs, ip.String())
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: RootCAs, Intermediates: intermediates})
+	if ci.Verified = err == nil; err != nil {
+		ci.VerifyError = err.Error()
+	}
+	return ci, nil
+}
+
+// CheckCertificate sets wir.Certificate to the certificate domainName
+// serves, warning when it can't be fetched or doesn't verify.
+func (wir *WhoisResponse) CheckCertificate(ctx context.Context, domainName string) {
+	ci, err := FetchCertificate(ctx, domainName)
+	if err != nil {
+		wir.Warnings = append(wir.Warnings, err.Error())
+		return
+	}
+	wir.Certificate = ci
+	if !ci.Verified {
+		wir.Warnings = append(wir.Warnings, "certificate does not verify: "+ci.VerifyError)
+	}
+}
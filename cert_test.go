@@ -0,0 +1,40 @@
+package qwis
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetchCertificate(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	var dialed string
+	useDial(t, func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = address
+		var d net.Dialer
+		return d.DialContext(ctx, network, srv.Listener.Addr().String())
+	})
+	roots := RootCAs
+	defer func() { RootCAs = roots }()
+	RootCAs = x509.NewCertPool()
+	RootCAs.AddCert(srv.Certificate())
+
+	ci, err := FetchCertificate(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dialed != "example.com:443" || !ci.Verified || ci.Issuer != "O=Acme Co" || ci.SANs[0] != "example.com" || !strings.HasPrefix(ci.NotAfter, "2084-") {
+		t.Errorf("FetchCertificate = %+v after dialing %s", ci, dialed)
+	}
+
+	wir := &WhoisResponse{}
+	wir.CheckCertificate(context.Background(), "other.example")
+	if wir.Certificate == nil || wir.Certificate.Verified || len(wir.Warnings) != 1 || !strings.Contains(wir.Warnings[0], "does not verify") {
+		t.Errorf("Certificate = %+v, warnings %q", wir.Certificate, wir.Warnings)
+	}
+}
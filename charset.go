@@ -0,0 +1,100 @@
+package qwis
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/transform"
+)
+
+// TLDCharsets are the charsets registries answer in when their answers are
+// not UTF-8. Answers from other TLDs that are not UTF-8 are read as
+// Windows-1252, which covers ISO-8859-1.
+var TLDCharsets = map[string]encoding.Encoding{
+	"br": charmap.ISO8859_1,
+	"cn": simplifiedchinese.GBK,
+	"hu": charmap.ISO8859_2,
+	"jp": japanese.ShiftJIS,
+	"kr": korean.EUCKR,
+	"ru": charmap.KOI8R,
+	"su": charmap.KOI8R,
+	"tw": traditionalchinese.Big5,
+}
+
+// ToUTF8 returns raw as UTF-8. Answers that already are UTF-8 are returned
+// unchanged; ISO-2022-JP, which JPRS sends by default, is told apart by its
+// escape sequences and other answers are decoded with TLDCharsets.
+func ToUTF8(raw []byte, tld string) []byte {
+	if utf8.Valid(raw) && bytes.IndexByte(raw, '\x1b') < 0 {
+		return raw
+	}
+	res, _, err := transform.Bytes(newUTF8Transcoder(tld), raw)
+	if err != nil {
+		return raw
+	}
+	return res
+}
+
+// UTF8Reader is ToUTF8 for answers read as they arrive.
+func UTF8Reader(r io.Reader, tld string) io.Reader {
+	return transform.NewReader(r, newUTF8Transcoder(tld))
+}
+
+// utf8Transcoder passes UTF-8 through until the first byte that is not,
+// then decodes the rest with the TLD's charset. What was passed through is
+// ASCII in all the charsets it may switch to, bar the rare answer whose
+// first non-ASCII bytes happen to form valid UTF-8.
+type utf8Transcoder struct {
+	tld string
+	dec transform.Transformer
+}
+
+func newUTF8Transcoder(tld string) *utf8Transcoder {
+	return &utf8Transcoder{tld: strings.ToLower(strings.TrimPrefix(tld, "."))}
+}
+
+func (t *utf8Transcoder) Reset() {
+	t.dec = nil
+}
+
+func (t *utf8Transcoder) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	if t.dec != nil {
+		return t.dec.Transform(dst, src, atEOF)
+	}
+	n := 0
+	for n < len(src) && src[n] != '\x1b' {
+		if !atEOF && !utf8.FullRune(src[n:]) {
+			break
+		}
+		r, size := utf8.DecodeRune(src[n:])
+		if r == utf8.RuneError && size == 1 {
+			break
+		}
+		n += size
+	}
+	if nDst = copy(dst, src[:n]); nDst < n {
+		return nDst, nDst, transform.ErrShortDst
+	}
+	switch {
+	case n == len(src):
+		return n, n, nil
+	case src[n] == '\x1b':
+		t.dec = japanese.ISO2022JP.NewDecoder()
+	case !atEOF && !utf8.FullRune(src[n:]):
+		return n, n, transform.ErrShortSrc
+	case TLDCharsets[t.tld] != nil:
+		t.dec = TLDCharsets[t.tld].NewDecoder()
+	default:
+		t.dec = charmap.Windows1252.NewDecoder()
+	}
+	d, s, err := t.dec.Transform(dst[n:], src[n:], atEOF)
+	return n + d, n + s, err
+}
@@ -0,0 +1,51 @@
+package qwis
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+)
+
+func TestToUTF8(t *testing.T) {
+	const jp = "[Domain Name]  EXAMPLE.JP\n[登録者名]  株式会社エグザンプル\n"
+	for _, tc := range []struct {
+		tld  string
+		enc  encoding.Encoding
+		text string
+	}{
+		{"de", charmap.ISO8859_1, "Domain: example.de\nOrganisation: Müller GmbH\n"},
+		{"ru", charmap.KOI8R, "domain: EXAMPLE.RU\norg: ООО Пример\n"},
+		{"jp", japanese.ShiftJIS, jp},
+		// JPRS sends ISO-2022-JP whatever the TLD's hint says.
+		{"jp", japanese.ISO2022JP, jp},
+		{"com", encoding.Nop, "Domain Name: EXAMPLE.COM\nRegistrant Organization: Müller GmbH\n"},
+	} {
+		raw, err := tc.enc.NewEncoder().Bytes([]byte(tc.text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ToUTF8(raw, tc.tld); string(got) != tc.text {
+			t.Errorf("%s: ToUTF8 = %q, want %q", tc.tld, got, tc.text)
+		}
+		got, err := io.ReadAll(UTF8Reader(iotest.OneByteReader(bytes.NewReader(raw)), tc.tld))
+		if err != nil || string(got) != tc.text {
+			t.Errorf("%s: UTF8Reader = %q, %v; want %q", tc.tld, got, err, tc.text)
+		}
+	}
+}
+
+func TestParseResponseTranscodes(t *testing.T) {
+	raw, _ := charmap.KOI8R.NewEncoder().Bytes([]byte("domain: EXAMPLE.RU\norg: ООО Пример\n"))
+	wir, err := ParseResponseWithTLD(raw, "ru")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wir.RegistrantOrganization != "ООО Пример" {
+		t.Errorf("RegistrantOrganization = %q", wir.RegistrantOrganization)
+	}
+}
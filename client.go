@@ -0,0 +1,375 @@
+package qwis
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Client looks domains, IP addresses and AS numbers up with settings of its
+// own rather than the package variables, so that clients configured
+// differently can be used side by side. It only shares the TLD to server
+// table, which SetWhoisServer and discovery via IANA fill, with them; it
+// doesn't keep connections open as ReuseConnections has the package
+// functions do.
+type Client struct {
+	timeout         time.Duration
+	server          string
+	dial            DialFunc
+	dialSet         bool
+	network         string
+	dialTimeout     time.Duration
+	readTimeout     time.Duration
+	maxResponseSize int64
+	tls             *tls.Config
+	rdap            *http.Client
+	cache           Cache
+	retry           RetryPolicy
+	rateLimit       RateLimitPolicy
+	rateSlots       *rateSlots
+	referrals       bool
+	multiDomain     string
+	logger          *slog.Logger
+	hooks           Hooks
+	now             func() time.Time
+}
+
+type Option func(*Client)
+
+// WithTimeout bounds every lookup, referrals and retries included.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) { c.timeout = d }
+}
+
+// WithServer sends every whois query to server, host[:port], as Server
+// does for the package functions.
+func WithServer(server string) Option {
+	return func(c *Client) { c.server = server }
+}
+
+// ContextDialer makes the connections of a Client. *net.Dialer is one, as
+// are DialFunc and the dialers of golang.org/x/net/proxy.
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+func (f DialFunc) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return f(ctx, network, address)
+}
+
+// WithDialer has whois connections, and RDAP requests unless WithHTTPClient
+// is given, made with dial.
+func WithDialer(dial DialFunc) Option {
+	return WithTransport(dial)
+}
+
+// WithTransport is WithDialer for a ContextDialer.
+func WithTransport(d ContextDialer) Option {
+	return func(c *Client) { c.dial, c.dialSet = d.DialContext, true }
+}
+
+// WithNetwork dials whois servers over network, as Network does for the
+// package functions.
+func WithNetwork(network string) Option {
+	return func(c *Client) { c.network = network }
+}
+
+// WithDialTimeout bounds every connection to a whois server, TLS handshake
+// included, as Dialer.Timeout does for the package functions.
+func WithDialTimeout(d time.Duration) Option {
+	return func(c *Client) { c.dialTimeout = d }
+}
+
+// WithReadTimeout bounds every read of an answer, as ReadTimeout does for
+// the package functions; zero disables it.
+func WithReadTimeout(d time.Duration) Option {
+	return func(c *Client) { c.readTimeout = d }
+}
+
+// WithMaxResponseSize fails answers over n bytes, as MaxResponseSize does
+// for the package functions; zero or less lifts the bound.
+func WithMaxResponseSize(n int64) Option {
+	return func(c *Client) { c.maxResponseSize = n }
+}
+
+// WithWhoisTLS sends whois queries over TLS, to port 853 in place of 43,
+// verifying servers with config, whose ServerName is set for each. A nil
+// config sends them in the clear, as WhoisTLS unset does.
+func WithWhoisTLS(config *tls.Config) Option {
+	return func(c *Client) { c.tls = config }
+}
+
+// WithHTTPClient has RDAP requests sent with hc.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.rdap = hc }
+}
+
+// WithCache serves and saves responses through cache.
+func WithCache(cache Cache) Option {
+	return func(c *Client) { c.cache = cache }
+}
+
+// WithRetries retries a failed query up to n times with the backoff of
+// DefaultRetryPolicy.
+func WithRetries(n int) Option {
+	return func(c *Client) { c.retry.Attempts = n + 1 }
+}
+
+// WithRateLimit spaces out the queries of the Client by p, apart from those
+// of the package functions and other clients.
+func WithRateLimit(p RateLimitPolicy) Option {
+	return func(c *Client) { c.rateLimit = p }
+}
+
+// WithReferralChasing sets whether Whois follows the registry's referral to
+// the registrar's whois server.
+func WithReferralChasing(follow bool) Option {
+	return func(c *Client) { c.referrals = follow }
+}
+
+// WithMultiDomain has answers holding records for more than one domain
+// parsed by policy, one of the MultiDomain values.
+func WithMultiDomain(policy string) Option {
+	return func(c *Client) { c.multiDomain = policy }
+}
+
+// WithLogger has the Client tell l what its lookups do, as Logger is told
+// for the package functions.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *Client) { c.logger = l }
+}
+
+// WithClock has the Client go by now rather than the time of day, as Now
+// has the package functions, for caching and for remembering the TLDs
+// without a whois server.
+func WithClock(now func() time.Time) Option {
+	return func(c *Client) { c.now = now }
+}
+
+// WithHooks calls hooks as the lookups of the Client proceed, as
+// LookupHooks does for the package functions.
+func WithHooks(hooks Hooks) Option {
+	return func(c *Client) { c.hooks = hooks }
+}
+
+// NewClient returns a Client that, but for opts, dials over "tcp" with a
+// zero net.Dialer and no timeout, sends RDAP requests with RDAPClient, asks
+// the server of each TLD in the clear, bounds reads by DefaultReadTimeout
+// and answers by DefaultMaxResponseSize, caches nothing, tries each server
+// once without a rate limit, follows referrals, keeps the first of the
+// domains of an answer, logs nothing and goes by time.Now.
+func NewClient(opts ...Option) *Client {
+	c := &Client{
+		dial:            (&net.Dialer{}).DialContext,
+		network:         "tcp",
+		readTimeout:     DefaultReadTimeout,
+		maxResponseSize: DefaultMaxResponseSize,
+		retry:           DefaultRetryPolicy,
+		rateSlots:       newRateSlots(),
+		referrals:       true,
+		multiDomain:     MultiDomainKeepFirst,
+		now:             time.Now,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	if c.rdap == nil && c.dialSet {
+		// The dialer replaces any proxy RDAPClient goes through, as it does
+		// for whois connections.
+		c.rdap = changeTransport(RDAPClient, func(t *http.Transport) {
+			t.Proxy, t.DialContext = nil, c.dial
+		})
+	}
+	return c
+}
+
+type clientKey struct{}
+
+func (c *Client) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx = context.WithValue(ctx, clientKey{}, c)
+	if c.timeout > 0 {
+		return context.WithTimeout(ctx, c.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func (c *Client) Whois(ctx context.Context, domainName string) (*WhoisResponse, error) {
+	ctx, cancel := c.context(ctx)
+	defer cancel()
+	return WhoisContext(ctx, domainName)
+}
+
+func (c *Client) WhoisRaw(ctx context.Context, domainName string) ([]byte, error) {
+	ctx, cancel := c.context(ctx)
+	defer cancel()
+	return WhoisRawContext(ctx, domainName)
+}
+
+func (c *Client) RDAP(ctx context.Context, domainName string) (*WhoisResponse, error) {
+	ctx, cancel := c.context(ctx)
+	defer cancel()
+	return RDAPContext(ctx, domainName)
+}
+
+// RaceSources is RaceSourcesContext with the settings of c; the lookup
+// that loses the race is cancelled.
+func (c *Client) RaceSources(ctx context.Context, domainName string) (*WhoisResponse, error) {
+	ctx, cancel := c.context(ctx)
+	defer cancel()
+	return RaceSourcesContext(ctx, domainName)
+}
+
+func (c *Client) IPWhois(ctx context.Context, q string) (*IPWhoisResponse, error) {
+	ctx, cancel := c.context(ctx)
+	defer cancel()
+	return IPWhoisContext(ctx, q)
+}
+
+func (c *Client) ASWhois(ctx context.Context, q string) (*ASWhoisResponse, error) {
+	ctx, cancel := c.context(ctx)
+	defer cancel()
+	return ASWhoisContext(ctx, q)
+}
+
+func (c *Client) IsAvailable(ctx context.Context, domainName string) (bool, error) {
+	ctx, cancel := c.context(ctx)
+	defer cancel()
+	return IsAvailableContext(ctx, domainName)
+}
+
+// The accessors below return the setting of the Client a lookup runs for,
+// or the package variable when there is none.
+
+func clientFrom(ctx context.Context) *Client {
+	c, _ := ctx.Value(clientKey{}).(*Client)
+	return c
+}
+
+func dialFunc(ctx context.Context) DialFunc {
+	if c := clientFrom(ctx); c != nil {
+		return c.dial
+	}
+	return Dial
+}
+
+func rdapClient(ctx context.Context) *http.Client {
+	if c := clientFrom(ctx); c != nil && c.rdap != nil {
+		return c.rdap
+	}
+	return RDAPClient
+}
+
+func fixedServer(ctx context.Context) string {
+	if c := clientFrom(ctx); c != nil {
+		return c.server
+	}
+	return Server
+}
+
+func responseCache(ctx context.Context) Cache {
+	if c := clientFrom(ctx); c != nil {
+		return c.cache
+	}
+	return ResponseCache
+}
+
+func retryPolicy(ctx context.Context) RetryPolicy {
+	if c := clientFrom(ctx); c != nil {
+		return c.retry
+	}
+	return Retry
+}
+
+func followReferrals(ctx context.Context) bool {
+	if c := clientFrom(ctx); c != nil {
+		return c.referrals
+	}
+	return FollowReferrals
+}
+
+func lookupHooks(ctx context.Context) Hooks {
+	if c := clientFrom(ctx); c != nil {
+		return c.hooks
+	}
+	return LookupHooks
+}
+
+func dialNetwork(ctx context.Context) string {
+	if c := clientFrom(ctx); c != nil {
+		return c.network
+	}
+	return Network
+}
+
+func dialTimeout(ctx context.Context) time.Duration {
+	if c := clientFrom(ctx); c != nil {
+		return c.dialTimeout
+	}
+	return Dialer.Timeout
+}
+
+func readTimeout(ctx context.Context) time.Duration {
+	if c := clientFrom(ctx); c != nil {
+		return c.readTimeout
+	}
+	return ReadTimeout
+}
+
+func maxResponseSize(ctx context.Context) int64 {
+	if c := clientFrom(ctx); c != nil {
+		return c.maxResponseSize
+	}
+	return MaxResponseSize
+}
+
+// whoisTLSConfig returns the configuration whois connections to host are
+// made over TLS with, or nil when they are not.
+func whoisTLSConfig(ctx context.Context, host string) *tls.Config {
+	if c := clientFrom(ctx); c != nil {
+		if c.tls == nil {
+			return nil
+		}
+		config := c.tls.Clone()
+		config.ServerName = host
+		return config
+	}
+	if !WhoisTLS {
+		return nil
+	}
+	return &tls.Config{ServerName: host, RootCAs: RootCAs, InsecureSkipVerify: InsecureSkipVerify}
+}
+
+func rateLimit(ctx context.Context) (RateLimitPolicy, *rateSlots) {
+	if c := clientFrom(ctx); c != nil {
+		return c.rateLimit, c.rateSlots
+	}
+	return RateLimit, packageRateSlots
+}
+
+func multiDomain(ctx context.Context) string {
+	if c := clientFrom(ctx); c != nil {
+		return c.multiDomain
+	}
+	return MultiDomain
+}
+
+func logger(ctx context.Context) *slog.Logger {
+	if c := clientFrom(ctx); c != nil {
+		return c.logger
+	}
+	return Logger
+}
+
+func reuseConnections(ctx context.Context) bool {
+	return ReuseConnections && clientFrom(ctx) == nil
+}
+
+func clock(ctx context.Context) func() time.Time {
+	if c := clientFrom(ctx); c != nil {
+		return c.now
+	}
+	return Now
+}
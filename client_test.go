@@ -0,0 +1,296 @@
+package qwis
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkorotkov/qwis/internal/whoistest"
+)
+
+func TestClient(t *testing.T) {
+	useDial(t, func(ctx context.Context, network, address string) (net.Conn, error) {
+		t.Errorf("package Dial used for %s", address)
+		return nil, errors.New("connection refused")
+	})
+	FollowReferrals = false
+	defer func() { FollowReferrals = true }()
+	registry := "Domain Name: EXAMPLE.COM\r\nRegistrar WHOIS Server: whois.example-registrar.test\r\n"
+	fs := &whoistest.FakeServers{Responses: map[string]string{
+		"whois.verisign-grs.com:43":       registry,
+		"whois.example-registrar.test:43": "Domain Name: EXAMPLE.COM\r\nRegistrar: Example Registrar\r\n",
+		"whois.fixed.test:43":             "Domain Name: EXAMPLE.COM\r\nRegistrar: Fixed Registrar\r\n",
+	}}
+	ctx := context.Background()
+
+	wir, err := NewClient(WithDialer(fs.Dial)).Whois(ctx, "example.com")
+	if err != nil || wir.Registrar != "Example Registrar" {
+		t.Errorf("Whois with referrals = %+v, %v", wir, err)
+	}
+	wir, err = NewClient(WithDialer(fs.Dial), WithReferralChasing(false)).Whois(ctx, "example.com")
+	if err != nil || len(wir.Registrar) != 0 {
+		t.Errorf("Whois without referrals = %+v, %v", wir, err)
+	}
+	wir, err = NewClient(WithDialer(fs.Dial), WithServer("whois.fixed.test")).Whois(ctx, "example.com")
+	if err != nil || wir.Registrar != "Fixed Registrar" {
+		t.Errorf("Whois with a fixed server = %+v, %v", wir, err)
+	}
+
+	cache := NewMemoryCache(time.Minute)
+	c := NewClient(WithDialer(fs.Dial), WithCache(cache), WithReferralChasing(false))
+	fs.Dialed = nil
+	for i := 0; i < 2; i++ {
+		if _, err = c.WhoisRaw(ctx, "example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n := 0
+	for _, a := range fs.Dialed {
+		if a == "whois.verisign-grs.com:43" {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Errorf("cached client dialed %q", fs.Dialed)
+	}
+	if ResponseCache != nil {
+		t.Error("WithCache set ResponseCache")
+	}
+
+	refused := &whoistest.FakeServers{}
+	c = NewClient(WithDialer(refused.Dial), WithRetries(2), WithServer("whois.down.test"))
+	c.retry.Backoff = time.Millisecond
+	_, err = c.WhoisRaw(ctx, "example.com")
+	if !errors.Is(err, ErrServerUnavailable) || len(refused.Dialed) != 3 {
+		t.Errorf("WithRetries(2): %v after %q", err, refused.Dialed)
+	}
+
+	hang := func(ctx context.Context, network, address string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	start := time.Now()
+	_, err = NewClient(WithDialer(hang), WithTimeout(20*time.Millisecond)).Whois(ctx, "example.com")
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 5*time.Second {
+		t.Errorf("WithTimeout: %v after %s", err, time.Since(start))
+	}
+}
+
+func TestClientSettings(t *testing.T) {
+	// The package settings are set apart from those of the clients, which
+	// must not be read.
+	useDial(t, (&whoistest.FakeServers{}).Dial)
+	readTimeout, maxSize, network, multi, logger := ReadTimeout, MaxResponseSize, Network, MultiDomain, Logger
+	ReadTimeout, MaxResponseSize, Network, MultiDomain = time.Hour, 0, "tcp6", MultiDomainError
+	var packageLog bytes.Buffer
+	Logger = slog.New(slog.NewTextHandler(&packageLog, nil))
+	t.Cleanup(func() {
+		ReadTimeout, MaxResponseSize, Network, MultiDomain, Logger = readTimeout, maxSize, network, multi, logger
+	})
+	answer := "Domain Name: EXAMPLE.COM\r\nRegistrar: First\r\n\r\nDomain Name: EXAMPLE.COM.EVIL.TEST\r\nRegistrar: Last\r\n"
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": answer}}
+	var networks []string
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		networks = append(networks, network)
+		return fs.Dial(ctx, network, address)
+	}
+	ctx := context.Background()
+	var log bytes.Buffer
+	opts := []Option{WithDialer(dial), WithReferralChasing(false), WithLogger(slog.New(slog.NewTextHandler(&log, nil)))}
+	wir, err := NewClient(opts...).Whois(ctx, "example.com")
+	if err != nil || wir.Registrar != "First" || len(wir.Related) != 1 {
+		t.Fatalf("Whois = %+v, %v; want the first record", wir, err)
+	}
+	for _, n := range networks {
+		if n != "tcp" {
+			t.Errorf("dialed over %q, want tcp", networks)
+		}
+	}
+	if !strings.Contains(log.String(), "msg=query server=whois.verisign-grs.com:43") || packageLog.Len() != 0 {
+		t.Errorf("client log:\n%s\npackage log:\n%s", log.String(), packageLog.String())
+	}
+	networks = nil
+	c := NewClient(append(opts, WithNetwork("tcp4"), WithMultiDomain(MultiDomainKeepLast))...)
+	if wir, err = c.Whois(ctx, "example.com"); err != nil || wir.Registrar != "Last" || networks[len(networks)-1] != "tcp4" {
+		t.Errorf("Whois = %+v, %v over %q; want the last record over tcp4", wir, err, networks)
+	}
+	_, err = NewClient(append(opts, WithMaxResponseSize(20))...).Whois(ctx, "example.com")
+	if !errors.Is(err, ErrResponseTooLarge) || !strings.Contains(err.Error(), "over 20 bytes") {
+		t.Errorf("WithMaxResponseSize(20): %v", err)
+	}
+
+	stall := func(ctx context.Context, network, address string) (net.Conn, error) {
+		c, s := net.Pipe()
+		go func() {
+			bufio.NewReader(s).ReadString('\n')
+			io.WriteString(s, "Domain Name: EXAMPLE.COM\r\n")
+			<-ctx.Done()
+			s.Close()
+		}()
+		return c, nil
+	}
+	start := time.Now()
+	_, err = NewClient(WithDialer(stall), WithReadTimeout(20*time.Millisecond), WithTimeout(5*time.Second)).Whois(ctx, "example.com")
+	if err == nil || errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 2*time.Second {
+		t.Errorf("WithReadTimeout: %v after %s", err, time.Since(start))
+	}
+
+	refused := &whoistest.FakeServers{}
+	NewClient(WithDialer(refused.Dial), WithServer("whois.tls.test"), WithWhoisTLS(&tls.Config{})).WhoisRaw(ctx, "example.com")
+	if len(refused.Dialed) != 1 || refused.Dialed[0] != "whois.tls.test:853" {
+		t.Errorf("WithWhoisTLS dialed %q, want whois.tls.test:853", refused.Dialed)
+	}
+
+	limited := NewClient(WithDialer(fs.Dial), WithReferralChasing(false), WithRateLimit(RateLimitPolicy{QPS: 10}))
+	start = time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err = limited.WhoisRaw(ctx, "example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d < 150*time.Millisecond {
+		t.Errorf("3 queries at 10 QPS took %s", d)
+	}
+	start = time.Now()
+	if _, err = NewClient(WithDialer(fs.Dial)).WhoisRaw(ctx, "example.com"); err != nil || time.Since(start) > 50*time.Millisecond {
+		t.Errorf("another client waited on the rate limit of the first: %v after %s", err, time.Since(start))
+	}
+}
+
+func TestClientClock(t *testing.T) {
+	useDial(t, (&whoistest.FakeServers{}).Dial)
+	now := time.Date(2026, 8, 3, 4, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	fs := &whoistest.FakeServers{Responses: map[string]string{
+		"whois.verisign-grs.com:43": "Domain Name: EXAMPLE.COM\r\nRegistry Expiry Date: 2026-08-13T04:00:00Z\r\n",
+	}}
+	disk, err := NewDiskCache(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cache := range []Cache{NewMemoryCache(time.Hour), disk} {
+		c := NewClient(WithDialer(fs.Dial), WithCache(cache), WithReferralChasing(false), WithClock(clock))
+		dials := func() int {
+			fs.Lock()
+			defer fs.Unlock()
+			n := 0
+			for _, a := range fs.Dialed {
+				if a == "whois.verisign-grs.com:43" {
+					n++
+				}
+			}
+			fs.Dialed = nil
+			return n
+		}
+		now = time.Date(2026, 8, 3, 4, 0, 0, 0, time.UTC)
+		wir, err := c.Whois(context.Background(), "example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wir.SetDaysUntilExpiry(clock()); wir.DaysUntilExpiry == nil || *wir.DaysUntilExpiry != 10 {
+			t.Errorf("DaysUntilExpiry = %v, want 10", wir.DaysUntilExpiry)
+		}
+		now = now.Add(59 * time.Minute)
+		c.Whois(context.Background(), "example.com")
+		if n := dials(); n != 1 {
+			t.Errorf("%T: dialed %d times within the TTL, want once", cache, n)
+		}
+		now = now.Add(2 * time.Minute)
+		c.Whois(context.Background(), "example.com")
+		if n := dials(); n != 1 {
+			t.Errorf("%T: dialed %d times once the entry expired, want once", cache, n)
+		}
+	}
+}
+
+// roundTripFunc is an http.RoundTripper.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestClientRaceSources(t *testing.T) {
+	useDial(t, (&whoistest.FakeServers{}).Dial)
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": "Domain Name: EXAMPLE.COM\r\n"}}
+	cancelled := make(chan struct{})
+	hang := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		<-r.Context().Done()
+		close(cancelled)
+		return nil, r.Context().Err()
+	})
+	c := NewClient(WithDialer(fs.Dial), WithHTTPClient(&http.Client{Transport: hang}), WithReferralChasing(false))
+	wir, err := c.RaceSources(context.Background(), "example.com")
+	if err != nil || wir.Source != SourceWhois || wir.DomainName != "EXAMPLE.COM" {
+		t.Fatalf("RaceSources = %+v, %v; want the whois answer", wir, err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Error("RDAP lookup not cancelled")
+	}
+}
+
+// countingDialer sends every connection to addr and counts them.
+type countingDialer struct {
+	d     net.Dialer
+	addr  string
+	dials atomic.Int32
+}
+
+func (cd *countingDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	cd.dials.Add(1)
+	return cd.d.DialContext(ctx, network, cd.addr)
+}
+
+func TestClientTransport(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				bufio.NewReader(c).ReadString('\n')
+				io.WriteString(c, "Domain Name: EXAMPLE.COM\r\nRegistrar: Mock Registrar\r\n")
+			}()
+		}
+	}()
+	cd := &countingDialer{addr: ln.Addr().String()}
+	wir, err := NewClient(WithTransport(cd), WithServer("whois.mock.test")).Whois(context.Background(), "example.com")
+	if err != nil || wir.Registrar != "Mock Registrar" || cd.dials.Load() != 1 {
+		t.Errorf("Whois = %+v, %v after %d dials", wir, err, cd.dials.Load())
+	}
+
+	srv := useRDAPServer(t, "dev", map[string]string{
+		"/rdap/domain/example.dev": `{"ldhName":"example.dev","status":["active"]}`,
+	})
+	cd = &countingDialer{addr: srv.Listener.Addr().String()}
+	if wir, err = NewClient(WithTransport(cd)).RDAP(context.Background(), "example.dev"); err != nil || wir.DomainName != "example.dev" {
+		t.Errorf("RDAP = %+v, %v", wir, err)
+	}
+	if cd.dials.Load() == 0 {
+		t.Error("RDAP requests did not go through the dialer")
+	}
+	hc := srv.Client()
+	cd.dials.Store(0)
+	if _, err = NewClient(WithTransport(cd), WithHTTPClient(hc)).RDAP(context.Background(), "example.dev"); err != nil || cd.dials.Load() != 0 {
+		t.Errorf("RDAP with WithHTTPClient: %v after %d dials", err, cd.dials.Load())
+	}
+}
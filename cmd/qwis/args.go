@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// splitArgs separates the options in args from the operands so that they
+// may come in any order. An option is spelt with one dash or two, takes its
+// value, when withValue says it has one, from the next argument or after
+// "=", and comes out as "-name" followed by the value; "--help" and "-help"
+// come out as "-h". Everything after "--" is an operand, as is "-" alone.
+func splitArgs(args []string, withValue map[string]bool) (opts, operands []string, err error) {
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			return opts, append(operands, args[i+1:]...), nil
+		}
+		if len(a) < 2 || a[0] != '-' {
+			operands = append(operands, a)
+			continue
+		}
+		name, v, hasValue := strings.Cut(strings.TrimPrefix(a, "-"), "=")
+		if name = "-" + strings.TrimPrefix(name, "-"); name == "-help" {
+			name = "-h"
+		}
+		switch {
+		case !withValue[name] && hasValue:
+			return nil, nil, fmt.Errorf("%s takes no value", name)
+		case !withValue[name]:
+			opts = append(opts, name)
+		case !hasValue && i+1 == len(args):
+			return nil, nil, fmt.Errorf("%s needs a value", name)
+		case !hasValue:
+			i++
+			v = args[i]
+			fallthrough
+		default:
+			opts = append(opts, name, v)
+		}
+	}
+	return opts, operands, nil
+}
+
+// helpRequested reports whether opts, as split by splitArgs, ask for help.
+func helpRequested(opts []string, withValue map[string]bool) bool {
+	for i := 0; i < len(opts); i++ {
+		if opts[i] == "-h" {
+			return true
+		}
+		if withValue[opts[i]] {
+			i++
+		}
+	}
+	return false
+}
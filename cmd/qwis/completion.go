@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkorotkov/qwis"
+)
+
+// completionFlag is a flag of a synopsis in usages with the placeholder,
+// such as "<path>", or the choices, such as "json|yaml", of its value.
+type completionFlag struct {
+	name        string
+	placeholder string
+	choices     []string
+}
+
+var usageFlag = regexp.MustCompile(`(?:^|[\[|<\s])(-[a-z0-9][a-z0-9-]*)(?: (<[^>]+>|[a-z0-9-]+(?:\|[a-z0-9-]+)+))?`)
+
+// completionFlags are the flags of the synopsis of command. Flags that
+// share the placeholder of an alternative, such as -local-addr, take theirs
+// from the options with values of the command.
+func completionFlags(command string) []completionFlag {
+	withValue := map[string]map[string]bool{
+		"serve":  serveOptions,
+		"watch":  watchOptions,
+		"report": reportOptions,
+	}[command]
+	if command == "lookup" {
+		withValue = optionsWithValue
+	}
+	var flags []completionFlag
+	seen := map[string]bool{}
+	for _, u := range usages {
+		if u.command != command {
+			continue
+		}
+		for _, m := range usageFlag.FindAllStringSubmatch(u.text, -1) {
+			if seen[m[1]] {
+				continue
+			}
+			seen[m[1]] = true
+			f := completionFlag{name: m[1]}
+			if strings.HasPrefix(m[2], "<") {
+				f.placeholder = m[2]
+			} else if len(m[2]) != 0 {
+				f.choices = strings.Split(m[2], "|")
+			} else if withValue[f.name] {
+				f.placeholder = "<value>"
+			}
+			flags = append(flags, f)
+		}
+	}
+	return flags
+}
+
+// completedCommands are the subcommands with flags of their own; the others
+// take those of the lookup.
+var completedCommands = []string{"serve", "watch", "report", "completion"}
+
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// completionValues returns the words the value of f completes to, or
+// whether it is a file name.
+func completionValues(command string, f completionFlag) ([]string, bool) {
+	switch {
+	case f.placeholder == "<path>" || f.placeholder == "<file>":
+		return nil, true
+	case command == "lookup" && f.name == "-server":
+		hosts := map[string]bool{}
+		for _, m := range qwis.WhoisServers() {
+			hosts[m.Server] = true
+		}
+		servers := make([]string, 0, len(hosts))
+		for h := range hosts {
+			servers = append(servers, h)
+		}
+		sort.Strings(servers)
+		return servers, false
+	}
+	return f.choices, false
+}
+
+func subcommands() []string {
+	var cs []string
+	for _, u := range usages {
+		cs = append(cs, u.command)
+	}
+	return cs
+}
+
+func flagNames(flags []completionFlag) string {
+	names := make([]string, len(flags))
+	for i, f := range flags {
+		names[i] = f.name
+	}
+	return strings.Join(names, " ")
+}
+
+// shellCase writes the case arms of a sh-like script that complete the
+// values of the flags of every command; flags without values to complete
+// return no words, so that their value is not taken for a file.
+func shellCase(w io.Writer, words func(ws []string) string, files string) {
+	for _, c := range append([]string{"lookup"}, completedCommands...) {
+		prefix := c
+		if c == "lookup" {
+			prefix = ""
+		}
+		for _, f := range completionFlags(c) {
+			if len(f.placeholder) == 0 && len(f.choices) == 0 {
+				continue
+			}
+			ws, isFile := completionValues(c, f)
+			switch {
+			case isFile:
+				fmt.Fprintf(w, "\t%s:%s) %s; return;;\n", prefix, f.name, files)
+			case len(ws) != 0:
+				fmt.Fprintf(w, "\t%s:%s) %s; return;;\n", prefix, f.name, words(ws))
+			default:
+				fmt.Fprintf(w, "\t%s:%s) return;;\n", prefix, f.name)
+			}
+		}
+	}
+}
+
+func writeBashCompletion(w io.Writer) {
+	compgen := func(ws []string) string {
+		return fmt.Sprintf(`COMPREPLY=($(compgen -W "%s" -- "$cur"))`, strings.Join(ws, " "))
+	}
+	fmt.Fprintln(w, "# bash completion for qwis; load it with: source <(qwis completion bash)")
+	fmt.Fprintln(w, "_qwis() {")
+	fmt.Fprintln(w, "\tlocal cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]} cmd= w")
+	fmt.Fprintln(w, "\tCOMPREPLY=()")
+	fmt.Fprintln(w, "\tfor w in \"${COMP_WORDS[@]:1:COMP_CWORD-1}\"; do")
+	fmt.Fprintf(w, "\t\tcase $w in %s) cmd=$w; break;; esac\n", strings.Join(completedCommands, "|"))
+	fmt.Fprintln(w, "\tdone")
+	fmt.Fprintln(w, "\tcase $cmd:$prev in")
+	shellCase(w, compgen, `COMPREPLY=($(compgen -f -- "$cur"))`)
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, "\tcase $cmd in")
+	for _, c := range completedCommands[:len(completedCommands)-1] {
+		fmt.Fprintf(w, "\t%s) %s;;\n", c, compgen([]string{flagNames(completionFlags(c))}))
+	}
+	fmt.Fprintf(w, "\tcompletion) %s;;\n", compgen(completionShells))
+	fmt.Fprintf(w, "\t*) %s;;\n", compgen(append(subcommands(), flagNames(completionFlags("lookup")))))
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "complete -F _qwis qwis")
+}
+
+func writeZshCompletion(w io.Writer) {
+	compadd := func(ws []string) string {
+		return "compadd -- " + strings.Join(ws, " ")
+	}
+	fmt.Fprintln(w, "#compdef qwis")
+	fmt.Fprintln(w, "# zsh completion for qwis; load it with: source <(qwis completion zsh)")
+	fmt.Fprintln(w, "_qwis() {")
+	fmt.Fprintln(w, "\tlocal cmd= w")
+	fmt.Fprintln(w, "\tfor w in ${words[2,CURRENT-1]}; do")
+	fmt.Fprintf(w, "\t\tcase $w in %s) cmd=$w; break;; esac\n", strings.Join(completedCommands, "|"))
+	fmt.Fprintln(w, "\tdone")
+	fmt.Fprintln(w, "\tcase $cmd:${words[CURRENT-1]} in")
+	shellCase(w, compadd, "_files")
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, "\tcase $cmd in")
+	for _, c := range completedCommands[:len(completedCommands)-1] {
+		fmt.Fprintf(w, "\t%s) %s;;\n", c, compadd([]string{flagNames(completionFlags(c))}))
+	}
+	fmt.Fprintf(w, "\tcompletion) %s;;\n", compadd(completionShells))
+	fmt.Fprintf(w, "\t*) %s;;\n", compadd(append(subcommands(), flagNames(completionFlags("lookup")))))
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "compdef _qwis qwis")
+}
+
+func writeFishCompletion(w io.Writer) {
+	fmt.Fprintln(w, "# fish completion for qwis; load it with: qwis completion fish | source")
+	fmt.Fprintln(w, "complete -c qwis -f")
+	fmt.Fprintf(w, "complete -c qwis -n 'not __fish_seen_subcommand_from %s' -a '%s'\n",
+		strings.Join(subcommands(), " "), strings.Join(subcommands(), " "))
+	for _, c := range append([]string{"lookup"}, completedCommands...) {
+		cond := "__fish_seen_subcommand_from " + c
+		if c == "lookup" {
+			cond = "not __fish_seen_subcommand_from " + strings.Join(completedCommands, " ")
+		}
+		if c == "completion" {
+			fmt.Fprintf(w, "complete -c qwis -n '%s' -a '%s'\n", cond, strings.Join(completionShells, " "))
+			continue
+		}
+		for _, f := range completionFlags(c) {
+			line := fmt.Sprintf("complete -c qwis -n '%s' -o %s", cond, f.name[1:])
+			if len(f.placeholder) != 0 || len(f.choices) != 0 {
+				ws, isFile := completionValues(c, f)
+				switch {
+				case isFile:
+					line += " -r -F"
+				case len(ws) != 0:
+					line += fmt.Sprintf(" -x -a '%s'", strings.Join(ws, " "))
+				default:
+					line += " -x"
+				}
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+// runCompletion writes the completion script of the shell named in args.
+func runCompletion(args []string, stdout, stderr io.Writer) int {
+	args, operands, err := splitArgs(args, nil)
+	if err != nil {
+		return printErrorMessage(stderr, err.Error(), 1)
+	}
+	if helpRequested(args, nil) {
+		return printHelpMessage(stdout, "completion")
+	}
+	if len(args) != 0 || len(operands) != 1 {
+		return printErrorMessage(stderr, "Invalid set of arguments", 1)
+	}
+	switch operands[0] {
+	case "bash":
+		writeBashCompletion(stdout)
+	case "zsh":
+		writeZshCompletion(stdout)
+	case "fish":
+		writeFishCompletion(stdout)
+	default:
+		return printErrorMessage(stderr, fmt.Sprintf("Unknown shell: %s; expected bash, zsh or fish", operands[0]), 1)
+	}
+	return 0
+}
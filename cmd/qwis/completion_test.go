@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestRunCompletion(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		ec, stdout, stderr := runCLI(t, "", nil, "completion", shell)
+		if ec != 0 {
+			t.Fatalf("completion %s = %d: %s", shell, ec, stderr)
+		}
+		for _, want := range []string{"output", "json yaml xml csv tsv", "keep-first keep-last error", "whois.verisign-grs.com", "-listen", "report"} {
+			if !strings.Contains(stdout, want) {
+				t.Errorf("completion %s lacks %q", shell, want)
+			}
+		}
+	}
+	if ec, _, stderr := runCLI(t, "", nil, "completion", "tcsh"); ec != 1 || !strings.Contains(stderr, "tcsh") {
+		t.Errorf("completion tcsh = %d: %s", ec, stderr)
+	}
+	if ec, _, _ := runCLI(t, "", nil, "completion"); ec != 1 {
+		t.Errorf("completion without a shell = %d, want 1", ec)
+	}
+}
+
+func TestBashCompletion(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("no bash")
+	}
+	_, script, _ := runCLI(t, "", nil, "completion", "bash")
+	for _, tc := range []struct {
+		line string
+		want []string
+	}{
+		{"qwis -output y", []string{"yaml"}},
+		{"qwis -sort-by ", []string{"expiration", "domain", "registrar"}},
+		{"qwis -server whois.verisign", []string{"whois.verisign-grs.com"}},
+		{"qwis -timeout ", nil},
+		{"qwis ser", []string{"servers", "serve"}},
+		{"qwis serve -gr", []string{"-grpc-listen"}},
+		{"qwis completion z", []string{"zsh"}},
+		{"qwis -j -no-n", []string{"-no-normalize"}},
+	} {
+		cmd := exec.Command(bash, "-c", script+`
+read -ra COMP_WORDS <<< "$LINE"
+[[ $LINE == *" " ]] && COMP_WORDS+=("")
+COMP_CWORD=$((${#COMP_WORDS[@]} - 1))
+_qwis
+echo "${COMPREPLY[@]}"`)
+		cmd.Env = []string{"LINE=" + tc.line}
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("%q: %v", tc.line, err)
+		}
+		if got := strings.Fields(string(out)); strings.Join(got, " ") != strings.Join(tc.want, " ") {
+			t.Errorf("%q completes to %q, want %q", tc.line, got, tc.want)
+		}
+	}
+}
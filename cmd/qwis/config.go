@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// configNames are the config files looked for under the user config
+// directory, in order.
+var configNames = []string{"config.toml", "config.yaml", "config.yml"}
+
+// booleanOptions are the flags without a value a config file may set.
+var booleanOptions = map[string]bool{
+	"-4":                true,
+	"-6":                true,
+	"-tls":              true,
+	"-insecure":         true,
+	"-no-cache":         true,
+	"-history":          true,
+	"-no-referrals":     true,
+	"-no-normalize":     true,
+	"-deep":             true,
+	"-dns":              true,
+	"-cert":             true,
+	"-raw-dates":        true,
+	"-reuse-conn":       true,
+	"-registrable":      true,
+	"-ndjson":           true,
+	"-emit-empty-array": true,
+	"-rdap":             true,
+	"-fastest":          true,
+	"-confidence":       true,
+	"-verbose":          true,
+	"-debug":            true,
+}
+
+// fileConfig is what a config file holds: the flags its keys stand for, to
+// go before those on the command line so that the latter win, and the
+// whois servers of its [servers] table as "tld server" lines.
+type fileConfig struct {
+	path    string
+	args    []string
+	servers string
+}
+
+// findConfig returns the path of the first of configNames present under
+// the user config directory, or "" when there is none.
+func findConfig() string {
+	dir, err := userConfigDir()
+	if err != nil {
+		return ""
+	}
+	for _, n := range configNames {
+		p := filepath.Join(dir, "qwis", n)
+		if _, err = os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+// readConfig reads the config file at path, TOML if it ends in .toml and
+// YAML otherwise. Only the flat subset of either is understood: keys are
+// flag names without the dash and values are strings, numbers or booleans,
+// as in
+//
+//	timeout = "10s"
+//	output = "yaml"
+//	no-cache = true
+//
+//	[servers]
+//	de = "whois.denic.de"
+func readConfig(path string) (*fileConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var (
+		fc      = &fileConfig{path: path}
+		toml    = strings.HasSuffix(path, ".toml")
+		sep     = ":"
+		servers bool
+		servTab strings.Builder
+		lineNo  int
+		scanner = bufio.NewScanner(f)
+	)
+	badLine := func(m string) error {
+		return fmt.Errorf("%s:%d: %s", path, lineNo, m)
+	}
+	if toml {
+		sep = "="
+	}
+	for scanner.Scan() {
+		lineNo++
+		l := stripConfigComment(scanner.Text())
+		if len(strings.TrimSpace(l)) == 0 || strings.TrimSpace(l) == "---" {
+			continue
+		}
+		text := strings.TrimSpace(l)
+		if toml && text[0] == '[' {
+			if text != "[servers]" {
+				return nil, badLine("unknown table " + text)
+			}
+			servers = true
+			continue
+		}
+		k, v, ok := strings.Cut(text, sep)
+		if !ok {
+			return nil, badLine("not a key " + sep + " value line")
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if k, err = unquoteConfig(k); err != nil {
+			return nil, badLine(err.Error())
+		}
+		if v, err = unquoteConfig(v); err != nil {
+			return nil, badLine(err.Error())
+		}
+		if !toml {
+			indented := l[0] == ' ' || l[0] == '\t'
+			if !indented {
+				servers = k == "servers" && len(v) == 0
+				if servers {
+					continue
+				}
+			} else if !servers {
+				return nil, badLine("unexpected indentation")
+			}
+		}
+		if servers {
+			servTab.WriteString(k + " " + v + "\n")
+			continue
+		}
+		a := "-" + k
+		switch {
+		case optionsWithValue[a]:
+			fc.args = append(fc.args, a, v)
+		case booleanOptions[a]:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, badLine(fmt.Sprintf("%s wants true or false", k))
+			}
+			if b {
+				fc.args = append(fc.args, a)
+			}
+		default:
+			return nil, badLine("unknown key " + k)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	fc.servers = servTab.String()
+	return fc, nil
+}
+
+func unquoteConfig(v string) (string, error) {
+	switch {
+	case len(v) > 1 && v[0] == '\'' && v[len(v)-1] == '\'':
+		return v[1 : len(v)-1], nil
+	case len(v) > 0 && v[0] == '"':
+		return strconv.Unquote(v)
+	}
+	return v, nil
+}
+
+// stripConfigComment drops a "#" comment that is not inside quotes.
+func stripConfigComment(l string) string {
+	var quote byte
+	for i := 0; i < len(l); i++ {
+		switch c := l[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return l[:i]
+		}
+	}
+	return l
+}
+
+func configFile(fc *fileConfig) string {
+	if fc == nil {
+		return ""
+	}
+	return fc.path
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/pkorotkov/qwis"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// wireMessage is a message of whois.proto.
+type wireMessage interface {
+	marshalWire() []byte
+	unmarshalWire(b []byte) error
+}
+
+// wireCodec encodes the messages of whois.proto with protowire, which keeps
+// the service wire-compatible with stubs generated from the file without
+// generated code here.
+type wireCodec struct{}
+
+func (wireCodec) Name() string {
+	return "proto"
+}
+
+func (wireCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(wireMessage)
+	if !ok {
+		return nil, fmt.Errorf("wireCodec: cannot marshal %T", v)
+	}
+	return m.marshalWire(), nil
+}
+
+func (wireCodec) Unmarshal(b []byte, v any) error {
+	m, ok := v.(wireMessage)
+	if !ok {
+		return fmt.Errorf("wireCodec: cannot unmarshal into %T", v)
+	}
+	return m.unmarshalWire(b)
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if len(s) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// consumeFields calls f with the number and value of each field in b:
+// v for length-delimited fields and x for varints. Fields of other types
+// are skipped.
+func consumeFields(b []byte, f func(num protowire.Number, v []byte, x uint64)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch typ {
+		case protowire.BytesType:
+			var v []byte
+			if v, n = protowire.ConsumeBytes(b); n >= 0 {
+				f(num, v, 0)
+			}
+		case protowire.VarintType:
+			var x uint64
+			if x, n = protowire.ConsumeVarint(b); n >= 0 {
+				f(num, nil, x)
+			}
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+type lookupRequest struct {
+	Query string
+}
+
+func (m *lookupRequest) marshalWire() []byte {
+	return appendString(nil, 1, m.Query)
+}
+
+func (m *lookupRequest) unmarshalWire(b []byte) error {
+	return consumeFields(b, func(num protowire.Number, v []byte, _ uint64) {
+		if num == 1 {
+			m.Query = string(v)
+		}
+	})
+}
+
+type lookupResponse struct {
+	Query          string
+	DomainName     string
+	Registrar      string
+	CreationDate   string
+	ExpirationDate string
+	UpdatedDate    string
+	Statuses       []string
+	NameServers    []string
+	JSON           string
+	Error          string
+}
+
+func (m *lookupResponse) marshalWire() []byte {
+	var b []byte
+	for i, s := range []string{m.Query, m.DomainName, m.Registrar, m.CreationDate, m.ExpirationDate, m.UpdatedDate} {
+		b = appendString(b, protowire.Number(i+1), s)
+	}
+	for _, s := range m.Statuses {
+		b = protowire.AppendString(protowire.AppendTag(b, 7, protowire.BytesType), s)
+	}
+	for _, s := range m.NameServers {
+		b = protowire.AppendString(protowire.AppendTag(b, 8, protowire.BytesType), s)
+	}
+	b = appendString(b, 9, m.JSON)
+	return appendString(b, 10, m.Error)
+}
+
+func (m *lookupResponse) unmarshalWire(b []byte) error {
+	fields := []*string{&m.Query, &m.DomainName, &m.Registrar, &m.CreationDate, &m.ExpirationDate, &m.UpdatedDate}
+	return consumeFields(b, func(num protowire.Number, v []byte, _ uint64) {
+		switch {
+		case num >= 1 && int(num) <= len(fields):
+			*fields[num-1] = string(v)
+		case num == 7:
+			m.Statuses = append(m.Statuses, string(v))
+		case num == 8:
+			m.NameServers = append(m.NameServers, string(v))
+		case num == 9:
+			m.JSON = string(v)
+		case num == 10:
+			m.Error = string(v)
+		}
+	})
+}
+
+type availabilityResponse struct {
+	Query     string
+	Available bool
+	Error     string
+}
+
+func (m *availabilityResponse) marshalWire() []byte {
+	b := appendString(nil, 1, m.Query)
+	if m.Available {
+		b = protowire.AppendVarint(protowire.AppendTag(b, 2, protowire.VarintType), 1)
+	}
+	return appendString(b, 3, m.Error)
+}
+
+func (m *availabilityResponse) unmarshalWire(b []byte) error {
+	return consumeFields(b, func(num protowire.Number, v []byte, x uint64) {
+		switch num {
+		case 1:
+			m.Query = string(v)
+		case 2:
+			m.Available = x != 0
+		case 3:
+			m.Error = string(v)
+		}
+	})
+}
+
+// grpcStatus is lookupStatus for gRPC.
+func grpcStatus(err error) error {
+	c := codes.Unknown
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		c = codes.DeadlineExceeded
+	case errors.Is(err, qwis.ErrNoSuchDomain):
+		c = codes.NotFound
+	case errors.Is(err, qwis.ErrUnsupportedTLD), errors.Is(err, qwis.ErrInvalidDomainName):
+		c = codes.InvalidArgument
+	case errors.Is(err, qwis.ErrRateLimited):
+		c = codes.ResourceExhausted
+	case errors.Is(err, qwis.ErrServerUnavailable):
+		c = codes.Unavailable
+	case errors.Is(err, qwis.ErrParse):
+		c = codes.Internal
+	}
+	return status.Error(c, err.Error())
+}
+
+// grpcServer serves the Whois service of whois.proto with the lookups,
+// timeout, client rate limit and metrics of the REST API.
+type grpcServer struct {
+	timeout time.Duration
+	rl      *rateLimiter
+	m       *metrics
+}
+
+func (s *grpcServer) allow(ctx context.Context) error {
+	if s.rl == nil {
+		return nil
+	}
+	client := "unknown"
+	if p, ok := peer.FromContext(ctx); ok {
+		client = p.Addr.String()
+		if host, _, err := net.SplitHostPort(client); err == nil {
+			client = host
+		}
+	}
+	if !s.rl.allow(client, time.Now()) {
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	return nil
+}
+
+func (s *grpcServer) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.timeout > 0 {
+		return context.WithTimeout(ctx, s.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func newLookupResponse(q string, res interface{}, err error) *lookupResponse {
+	r := &lookupResponse{Query: q}
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	if wir, ok := res.(*qwis.WhoisResponse); ok {
+		r.DomainName, r.Registrar = wir.DomainName, wir.Registrar
+		r.CreationDate, r.ExpirationDate, r.UpdatedDate = wir.CreationDate, wir.ExpirationDate, wir.UpdatedDate
+		r.Statuses, r.NameServers = wir.Statuses, wir.NameServers
+	}
+	if b, err := json.Marshal(res); err != nil {
+		r.Error = err.Error()
+	} else {
+		r.JSON = string(b)
+	}
+	return r
+}
+
+func (s *grpcServer) Lookup(ctx context.Context, req *lookupRequest) (*lookupResponse, error) {
+	if err := s.allow(ctx); err != nil {
+		return nil, err
+	}
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	res, err := serveLookup(ctx, req.Query)
+	s.m.observeLookup("grpc", req.Query, err)
+	if err != nil {
+		return nil, grpcStatus(err)
+	}
+	return newLookupResponse(req.Query, res, nil), nil
+}
+
+func (s *grpcServer) CheckAvailability(ctx context.Context, req *lookupRequest) (*availabilityResponse, error) {
+	if err := s.allow(ctx); err != nil {
+		return nil, err
+	}
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	available, err := qwis.IsAvailableContext(ctx, req.Query)
+	s.m.observeLookup("grpc", req.Query, err)
+	if err != nil {
+		return nil, grpcStatus(err)
+	}
+	return &availabilityResponse{Query: req.Query, Available: available}, nil
+}
+
+// BatchLookup looks up up to 8 requests at a time and sends each response
+// as soon as it is ready; the stream ends once the client has closed its
+// side and every lookup has been answered.
+func (s *grpcServer) BatchLookup(stream grpc.ServerStream) error {
+	ctx := stream.Context()
+	queries := make(chan string)
+	recvErr := make(chan error, 1)
+	go func() {
+		defer close(queries)
+		for {
+			req := &lookupRequest{}
+			if err := stream.RecvMsg(req); err != nil {
+				if err != io.EOF {
+					recvErr <- err
+				}
+				return
+			}
+			if err := s.allow(ctx); err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case queries <- req.Query:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	lookup := func(ctx context.Context, q string) (*qwis.WhoisResponse, error) {
+		ctx, cancel := s.withTimeout(ctx)
+		defer cancel()
+		return qwis.WhoisContext(ctx, q)
+	}
+	results := qwis.BatchLookupChan(ctx, queries, 8, lookup)
+	for r := range results {
+		s.m.observeLookup("grpc", r.Domain, r.Err)
+		var res interface{} = r.Response
+		if br := batchResource(r); br != nil {
+			res = br
+		}
+		if err := stream.SendMsg(newLookupResponse(r.Domain, res, r.Err)); err != nil {
+			return err
+		}
+	}
+	select {
+	case err := <-recvErr:
+		return err
+	default:
+		return ctx.Err()
+	}
+}
+
+func unaryHandler[Req any, PReq interface {
+	*Req
+	wireMessage
+}](method string, f func(s *grpcServer, ctx context.Context, req PReq) (wireMessage, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: method,
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			req := PReq(new(Req))
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			call := func(ctx context.Context, req any) (any, error) {
+				return f(srv.(*grpcServer), ctx, req.(PReq))
+			}
+			if interceptor == nil {
+				return call(ctx, req)
+			}
+			return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/qwis.v1.Whois/" + method}, call)
+		},
+	}
+}
+
+// whoisService describes the Whois service of whois.proto to grpc.Server,
+// which is what generated code would do.
+var whoisService = grpc.ServiceDesc{
+	ServiceName: "qwis.v1.Whois",
+	HandlerType: (*interface {
+		BatchLookup(grpc.ServerStream) error
+	})(nil),
+	Methods: []grpc.MethodDesc{
+		unaryHandler("Lookup", func(s *grpcServer, ctx context.Context, req *lookupRequest) (wireMessage, error) {
+			return s.Lookup(ctx, req)
+		}),
+		unaryHandler("CheckAvailability", func(s *grpcServer, ctx context.Context, req *lookupRequest) (wireMessage, error) {
+			return s.CheckAvailability(ctx, req)
+		}),
+	},
+	Streams: []grpc.StreamDesc{{
+		StreamName: "BatchLookup",
+		Handler: func(srv any, stream grpc.ServerStream) error {
+			return srv.(*grpcServer).BatchLookup(stream)
+		},
+		ServerStreams: true,
+		ClientStreams: true,
+	}},
+	Metadata: "whois.proto",
+}
+
+func newGRPCServer(timeout time.Duration, rl *rateLimiter, m *metrics) *grpc.Server {
+	gs := grpc.NewServer(grpc.ForceServerCodec(wireCodec{}))
+	gs.RegisterService(&whoisService, &grpcServer{timeout, rl, m})
+	return gs
+}
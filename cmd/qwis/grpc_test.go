@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pkorotkov/qwis"
+	"github.com/pkorotkov/qwis/internal/whoistest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestWireCodec(t *testing.T) {
+	in := &lookupResponse{
+		Query:       "example.com",
+		DomainName:  "EXAMPLE.COM",
+		Registrar:   "Example Registrar, Inc.",
+		Statuses:    []string{"clientTransferProhibited", "clientDeleteProhibited"},
+		NameServers: []string{"a.iana-servers.net"},
+		JSON:        `{"domain_name":"EXAMPLE.COM"}`,
+	}
+	b, err := wireCodec{}.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &lookupResponse{}
+	if err = (wireCodec{}).Unmarshal(b, out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Query != in.Query || out.DomainName != in.DomainName || out.Registrar != in.Registrar ||
+		len(out.Statuses) != 2 || out.Statuses[1] != in.Statuses[1] || len(out.NameServers) != 1 || out.JSON != in.JSON {
+		t.Errorf("round trip = %+v, want %+v", out, in)
+	}
+	a := &availabilityResponse{}
+	if b, err = (wireCodec{}).Marshal(&availabilityResponse{Query: "x.com", Available: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err = (wireCodec{}).Unmarshal(b, a); err != nil || a.Query != "x.com" || !a.Available {
+		t.Errorf("availability round trip = %+v, %v", a, err)
+	}
+	if _, err = (wireCodec{}).Marshal("x"); err == nil {
+		t.Error("Marshal of a string: no error")
+	}
+}
+
+func TestServeGRPC(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	dial, cache := qwis.Dial, qwis.ResponseCache
+	t.Cleanup(func() {
+		qwis.Dial, qwis.ResponseCache = dial, cache
+		qwis.ResetWhoisServers()
+	})
+	qwis.ResetWhoisServers()
+	qwis.Dial, qwis.ResponseCache = fs.Dial, nil
+	m := newMetrics()
+	ln := bufconn.Listen(1 << 16)
+	gs := newGRPCServer(5*time.Second, nil, m)
+	go gs.Serve(ln)
+	defer gs.Stop()
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(wireCodec{})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res := &lookupResponse{}
+	if err = conn.Invoke(ctx, "/qwis.v1.Whois/Lookup", &lookupRequest{Query: "example.com"}, res); err != nil {
+		t.Fatal(err)
+	}
+	if res.DomainName != "EXAMPLE.COM" || res.Registrar != "Example Registrar, Inc." || len(res.NameServers) != 1 || len(res.JSON) == 0 {
+		t.Errorf("Lookup = %+v", res)
+	}
+	err = conn.Invoke(ctx, "/qwis.v1.Whois/Lookup", &lookupRequest{Query: "example.org"}, &lookupResponse{})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("Lookup of example.org: %v, want code Unavailable", err)
+	}
+
+	avail := &availabilityResponse{}
+	if err = conn.Invoke(ctx, "/qwis.v1.Whois/CheckAvailability", &lookupRequest{Query: "example.com"}, avail); err != nil {
+		t.Fatal(err)
+	}
+	if avail.Query != "example.com" || avail.Available {
+		t.Errorf("CheckAvailability = %+v", avail)
+	}
+
+	stream, err := conn.NewStream(ctx, &whoisService.Streams[0], "/qwis.v1.Whois/BatchLookup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range []string{"example.com", "example.org", "192.0.2.1"} {
+		if err = stream.SendMsg(&lookupRequest{Query: q}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for {
+		r := &lookupResponse{}
+		if err = stream.RecvMsg(r); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r.Query+" "+r.DomainName+" "+strconv.FormatBool(len(r.Error) != 0))
+	}
+	sort.Strings(got)
+	want := []string{"192.0.2.1  true", "example.com EXAMPLE.COM false", "example.org  true"}
+	if len(got) != len(want) {
+		t.Fatalf("BatchLookup = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("BatchLookup = %q, want %q", got, want)
+			break
+		}
+	}
+	if n := m.lookups[[2]string{"grpc", "com"}]; n != 3 {
+		t.Errorf("grpc com lookups = %d, want 3", n)
+	}
+}
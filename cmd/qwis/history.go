@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkorotkov/qwis"
+	bolt "go.etcd.io/bbolt"
+)
+
+// historyStore keeps a snapshot of every lookup recorded with -history in a
+// bbolt database: a bucket per domain name, keyed by the big-endian Unix
+// time in nanoseconds of the lookup, holding the response as JSON with its
+// raw text.
+type historyStore struct {
+	db *bolt.DB
+}
+
+type snapshot struct {
+	Time     time.Time           `json:"time"`
+	Response *qwis.WhoisResponse `json:"response"`
+}
+
+// defaultHistoryFile is where the history is kept unless -history-file says
+// otherwise.
+func defaultHistoryFile() (string, error) {
+	dir, err := userCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history.db"), nil
+}
+
+func openHistory(path string) (*historyStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &historyStore{db}, nil
+}
+
+func (h *historyStore) Close() error {
+	return h.db.Close()
+}
+
+func historyKey(dn string) []byte {
+	return []byte(strings.ToLower(strings.TrimSuffix(dn, ".")))
+}
+
+// record adds a snapshot of wir, the response for dn, taken at t.
+func (h *historyStore) record(dn string, wir *qwis.WhoisResponse, t time.Time) error {
+	s := *wir
+	s.RawText = string(wir.Raw())
+	v, err := json.Marshal(&s)
+	if err != nil {
+		return err
+	}
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, uint64(t.UnixNano()))
+	return h.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(historyKey(dn))
+		if err != nil {
+			return err
+		}
+		return b.Put(k, v)
+	})
+}
+
+// snapshots returns the last n snapshots of dn, oldest first, or all of
+// them when n is 0.
+func (h *historyStore) snapshots(dn string, n int) ([]snapshot, error) {
+	var ss []snapshot
+	err := h.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(historyKey(dn))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Last(); k != nil && (n == 0 || len(ss) < n); k, v = c.Prev() {
+			s := snapshot{Time: time.Unix(0, int64(binary.BigEndian.Uint64(k))).UTC()}
+			if err := json.Unmarshal(v, &s.Response); err != nil {
+				return fmt.Errorf("%s snapshot of %s: %w", s.Time.Format(time.RFC3339), dn, err)
+			}
+			ss = append(ss, s)
+		}
+		return nil
+	})
+	for i, j := 0, len(ss)-1; i < j; i, j = i+1, j-1 {
+		ss[i], ss[j] = ss[j], ss[i]
+	}
+	return ss, err
+}
+
+func printHistory(w io.Writer, ss []snapshot, asJSON bool) error {
+	if asJSON {
+		for _, s := range ss {
+			s.Response.RawText = ""
+		}
+		return qwis.WriteIndentedJSON(w, ss)
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, s := range ss {
+		r := s.Response
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", s.Time.Format(time.RFC3339), r.Registrar, r.ExpirationDate,
+			strings.Join(r.Statuses, ","), strings.Join(r.NameServers, ","))
+	}
+	return tw.Flush()
+}
+
+type historyDiff struct {
+	Domain  string             `json:"domain"`
+	From    time.Time          `json:"from"`
+	To      time.Time          `json:"to"`
+	Changes []qwis.FieldChange `json:"changes"`
+}
+
+// printDiff writes what changed between the last two snapshots in ss.
+func printDiff(w io.Writer, dn string, ss []snapshot, asJSON bool) error {
+	d := historyDiff{dn, ss[0].Time, ss[1].Time, qwis.CompareResponses(ss[0].Response, ss[1].Response)}
+	if asJSON {
+		if d.Changes == nil {
+			d.Changes = []qwis.FieldChange{}
+		}
+		return qwis.WriteIndentedJSON(w, d)
+	}
+	if _, err := fmt.Fprintf(w, "%s: %s -> %s\n", dn, d.From.Format(time.RFC3339), d.To.Format(time.RFC3339)); err != nil {
+		return err
+	}
+	if len(d.Changes) == 0 {
+		_, err := fmt.Fprintln(w, "no changes")
+		return err
+	}
+	for _, c := range d.Changes {
+		if _, err := fmt.Fprintf(w, "%s: %s -> %s\n", c.Field, c.Old, c.New); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshotChanges is what changed for a domain since its last snapshot, an
+// entry of the -compare-snapshots report.
+type snapshotChanges struct {
+	Domain  string             `json:"domain"`
+	Changes []qwis.FieldChange `json:"changes"`
+}
+
+// compareWithSnapshots looks domains up and writes, as a JSON array, what
+// changed for each since its last snapshot in h, key giving the name it is
+// kept under. Unchanged domains are left out, and so are those without
+// snapshots and failed lookups, with a message on stderr. It exits with the
+// code of the last failed lookup, if any.
+func compareWithSnapshots(ctx context.Context, h *historyStore, domains []string, key func(string) (string, error),
+	concurrency int, lookup qwis.LookupFunc, stdout, stderr io.Writer) int {
+	// The last snapshots are read first, as the lookups may add new ones.
+	last := make([]*qwis.WhoisResponse, len(domains))
+	for i, d := range domains {
+		dn, err := key(d)
+		if err != nil {
+			continue
+		}
+		ss, err := h.snapshots(dn, 1)
+		if err != nil {
+			return printErrorMessage(stderr, err.Error(), 9)
+		}
+		if len(ss) != 0 {
+			last[i] = ss[0].Response
+		}
+	}
+	report := []snapshotChanges{}
+	ec := 0
+	for i, r := range qwis.BatchLookup(ctx, domains, concurrency, lookup) {
+		switch {
+		case r.Err != nil:
+			ec = printErrorMessage(stderr, fmt.Sprintf("%s: %s", r.Domain, r.Err), lookupExitCode(r.Err))
+		case r.Response == nil:
+			// IP addresses and AS numbers have no snapshots.
+		case last[i] == nil:
+			fmt.Fprintf(stderr, "Warning: %s: no snapshot to compare with; look it up with -history first\n", r.Domain)
+		default:
+			if changes := qwis.CompareResponses(last[i], r.Response); len(changes) != 0 {
+				report = append(report, snapshotChanges{r.Domain, changes})
+			}
+		}
+	}
+	if err := qwis.WriteIndentedJSON(stdout, report); err != nil {
+		return printErrorMessage(stderr, err.Error(), 7)
+	}
+	return ec
+}
+
+// runHistory serves the history and diff commands for the domain names in
+// operands.
+func runHistory(command, path string, operands []string, asJSON bool, stdout, stderr io.Writer) int {
+	if len(operands) != 1 {
+		return printErrorMessage(stderr, "Invalid set of arguments", 1)
+	}
+	dn, err := qwis.ToASCII(operands[0])
+	if err != nil {
+		return printErrorMessage(stderr, err.Error(), 1)
+	}
+	h, err := openHistory(path)
+	if err != nil {
+		return printErrorMessage(stderr, err.Error(), 9)
+	}
+	defer h.Close()
+	n := 0
+	if command == "diff" {
+		n = 2
+	}
+	ss, err := h.snapshots(dn, n)
+	if err != nil {
+		return printErrorMessage(stderr, err.Error(), 9)
+	}
+	if len(ss) == 0 {
+		return printErrorMessage(stderr, fmt.Sprintf("no snapshots of %s; look it up with -history first", dn), 9)
+	}
+	if command == "diff" {
+		if len(ss) < 2 {
+			return printErrorMessage(stderr, fmt.Sprintf("fewer than two snapshots of %s; look it up with -history first", dn), 9)
+		}
+		err = printDiff(stdout, dn, ss, asJSON)
+	} else {
+		err = printHistory(stdout, ss, asJSON)
+	}
+	if err != nil {
+		return printErrorMessage(stderr, err.Error(), 7)
+	}
+	return 0
+}
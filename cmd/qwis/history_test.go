@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkorotkov/qwis"
+	"github.com/pkorotkov/qwis/internal/whoistest"
+)
+
+func TestRunHistoryDiff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	hijacked := strings.NewReplacer(
+		"Example Registrar, Inc.", "Other Registrar LLC",
+		"A.IANA-SERVERS.NET", "NS1.ATTACKER.TEST",
+	).Replace(exampleCom)
+	ec, _, stderr := runCLI(t, "", nil, "-history-file", path, "diff", "example.com")
+	if ec != 9 || !strings.Contains(stderr, "no snapshots of example.com") {
+		t.Errorf("diff without history = %d, %q", ec, stderr)
+	}
+	for _, resp := range []string{exampleCom, hijacked} {
+		fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": resp}}
+		if ec, _, stderr := runCLI(t, "", fs, "-no-cache", "-history", "-history-file", path, "example.com"); ec != 0 {
+			t.Fatalf("lookup = %d, %q", ec, stderr)
+		}
+	}
+	ec, stdout, stderr := runCLI(t, "", nil, "-history-file", path, "history", "EXAMPLE.com")
+	if ec != 0 || strings.Count(stdout, "\n") != 2 || !strings.Contains(stdout, "ns1.attacker.test") {
+		t.Errorf("history = %d, %q, %q", ec, stdout, stderr)
+	}
+	ec, stdout, stderr = runCLI(t, "", nil, "diff", "example.com", "-history-file", path)
+	if ec != 0 {
+		t.Fatalf("diff = %d, %q", ec, stderr)
+	}
+	for _, want := range []string{
+		"registrar: Example Registrar, Inc. -> Other Registrar LLC\n",
+		"name_servers: a.iana-servers.net -> ns1.attacker.test\n",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("diff lacks %q:\n%s", want, stdout)
+		}
+	}
+	if strings.Contains(stdout, "expiration_date") {
+		t.Errorf("diff reports an unchanged expiration date:\n%s", stdout)
+	}
+	ec, stdout, _ = runCLI(t, "", nil, "-j", "-history-file", path, "diff", "example.com")
+	var d historyDiff
+	if err := json.Unmarshal([]byte(stdout), &d); ec != 0 || err != nil || len(d.Changes) != 2 || !d.From.Before(d.To) {
+		t.Errorf("diff -j = %d, %+v, %v", ec, d, err)
+	}
+}
+
+func TestRunCompareSnapshots(t *testing.T) {
+	exampleOrg := strings.ReplaceAll(exampleCom, "EXAMPLE.COM", "EXAMPLE.ORG")
+	hijacked := strings.NewReplacer(
+		"Example Registrar, Inc.", "Other Registrar LLC",
+		"A.IANA-SERVERS.NET", "NS1.ATTACKER.TEST",
+	).Replace(exampleCom)
+	dir := t.TempDir()
+	older, newer := filepath.Join(dir, "older.db"), filepath.Join(dir, "newer.db")
+	for path, com := range map[string]string{older: exampleCom, newer: hijacked} {
+		fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": com, "org.whois-servers.net:43": exampleOrg}}
+		if ec, _, stderr := runCLI(t, "", fs, "-no-cache", "-history", "-history-file", path, "example.com", "example.org"); ec != 0 {
+			t.Fatalf("lookup = %d, %q", ec, stderr)
+		}
+	}
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": hijacked, "org.whois-servers.net:43": exampleOrg}}
+	ec, stdout, stderr := runCLI(t, "", fs, "-no-cache", "-compare-snapshots", "-history-file", older, "example.com", "example.org", "example.net")
+	var report []snapshotChanges
+	if err := json.Unmarshal([]byte(stdout), &report); ec != 0 || err != nil {
+		t.Fatalf("-compare-snapshots = %d, %q, %q", ec, stdout, stderr)
+	}
+	want := []snapshotChanges{{"example.com", []qwis.FieldChange{
+		{Field: "registrar", Old: "Example Registrar, Inc.", New: "Other Registrar LLC"},
+		{Field: "name_servers", Old: "a.iana-servers.net", New: "ns1.attacker.test"},
+	}}}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("report = %+v, want %+v", report, want)
+	}
+	if !strings.Contains(stderr, "Warning: example.net: no snapshot to compare with") {
+		t.Errorf("stderr = %q", stderr)
+	}
+	if ec, stdout, _ = runCLI(t, "", fs, "-no-cache", "-compare-snapshots", "-history-file", newer, "example.com", "example.org"); ec != 0 || stdout != "[]" {
+		t.Errorf("against the newer snapshots = %d, %q", ec, stdout)
+	}
+	// With -history, the lookups are compared before they are recorded.
+	for _, n := range []int{1, 0} {
+		ec, stdout, _ = runCLI(t, "", fs, "-no-cache", "-history", "-compare-snapshots", "-history-file", older, "example.com")
+		if err := json.Unmarshal([]byte(stdout), &report); ec != 0 || err != nil || len(report) != n {
+			t.Errorf("-history -compare-snapshots = %d, %q; want %d changed", ec, stdout, n)
+		}
+	}
+	if ec, _, stderr = runCLI(t, "", fs, "-compare-snapshots", "-history-file", older, "example.info"); ec != 2 || !strings.Contains(stderr, "example.info:") {
+		t.Errorf("failed lookup = %d, %q", ec, stderr)
+	}
+}
@@ -0,0 +1,1346 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/pkorotkov/qwis"
+)
+
+const (
+	version = "0.0.1"
+)
+
+// usages are the synopses of the lookup and the subcommands, continuation
+// lines indented to follow "Usage:   ".
+var usages = []struct{ command, text string }{
+	{"lookup", "qwis [lookup] [-r] [-j|-n|-ics|-posture|-available] [-rdap|-cross-check|-fastest]\n" +
+		"              [-no-referrals] [-hex-dump] [-annotate-icann] [-confidence] [-print-config]\n" +
+		"              [-v|-verbose|-debug] [-no-normalize] [-deep] [-dns] [-cert] [-i]\n" +
+		"              [-raw-dates] [-template-file <path>|-format <template>]\n" +
+		"              [-fields <field,...>] [-list-sep <sep>] [-field-map <old=new,...>]\n" +
+		"              [-local-addr|-source-ip <ip>] [-interface <name>] [-4|-6]\n" +
+		"              [-fallback-delay <duration>]\n" +
+		"              [-multi-domain keep-first|keep-last|error] [-max-age <days>]\n" +
+		"              [-timeout <duration>] [-t <duration>] [-expiring-within <days>]\n" +
+		"              [-dial-timeout <duration>] [-read-timeout <duration>] [-rdap-tlds <tld,...>]\n" +
+		"              [-max-response-size <bytes>]\n" +
+		"              [-f <file>|-] [-c <concurrency>] [-ndjson] [-emit-empty-array]\n" +
+		"              [-registrable] [-reuse-conn]\n" +
+		"              [-sort-by expiration|domain|registrar] [-output json|yaml|xml|csv|tsv]\n" +
+		"              [-server <host[:port]>] [-servers-file <path>]\n" +
+		"              [-query-templates <path>] [-server-quirks <path>]\n" +
+		"              [-no-cache] [-cache-ttl <duration>]\n" +
+		"              [-retries <n>] [-retry-backoff <duration>] [-proxy <url>]\n" +
+		"              [-qps <n>] [-qps-per-server <n>] [-history] [-history-file <path>]\n" +
+		"              [-compare-snapshots]\n" +
+		"              [-tls] [-insecure] [-ca-file <path>] [-config <path>]\n" +
+		"              <-h>|<domain-name>...|<ip>|<cidr>|<asn>"},
+	{"servers", "qwis [-j] [-servers-file <path>] servers list"},
+	{"config", "qwis config show [<option>...]"},
+	{"history", "qwis [-j] [-history-file <path>] history <domain-name>"},
+	{"diff", "qwis [-j] [-history-file <path>] diff <domain-name>"},
+	{"serve", "qwis serve [-listen <addr>] [-grpc-listen <addr>] [-rate <requests/min>]\n" +
+		"                    [-timeout <duration>] [-cache-ttl <duration>] [-qps <n>]\n" +
+		"                    [-qps-per-server <n>] [-v|-debug]"},
+	{"watch", "qwis watch [-interval <duration>] [-threshold <days>] [-count <n>]\n" +
+		"                    [-webhook <url>] [-slack-webhook <url>]\n" +
+		"                    [-smtp <host:port> -mail-from <addr> -mail-to <addr,...>]\n" +
+		"                    [-message <template>|-message-file <path>]\n" +
+		"                    [-c <concurrency>] [-f <file>|-]\n" +
+		"                    [-qps <n>] [-qps-per-server <n>] [-v|-debug] <domain-name>..."},
+	{"report", "qwis report [-input <file>|-] [-output <path> [-bom]] [-c <concurrency>]\n" +
+		"                    [-timeout <duration>] [-list-sep <sep>] [-qps <n>]\n" +
+		"                    [-qps-per-server <n>] [-v|-debug] [<domain-name>...]"},
+	{"completion", "qwis completion bash|zsh|fish"},
+}
+
+// printHelpMessage prints the usage of command, or of all of them when
+// command is "".
+func printHelpMessage(w io.Writer, command string) int {
+	fmt.Fprintln(w, "Quick whois utility")
+	fmt.Fprintf(w, "Version: %s\n", version)
+	prefix := "Usage:   "
+	for _, u := range usages {
+		if len(command) == 0 || u.command == command {
+			fmt.Fprintln(w, prefix+u.text)
+			prefix = "         "
+		}
+	}
+	fmt.Fprintln(w, "Options may follow operands, be spelt with two dashes and take their\n"+
+		"value after \"=\", as in --timeout=5s; \"--\" ends them.")
+	fmt.Fprintln(w, exitStatuses)
+	return 0
+}
+
+func printErrorMessage(w io.Writer, m string, ec int) int {
+	fmt.Fprintf(w, "Error: %s\n", m)
+	return ec
+}
+
+// exitStatuses documents the exit codes of run; lookupExitCode maps lookup
+// errors to 1 for invalid domain names, to 2 through 6 and to 9 for any
+// other failure.
+const exitStatuses = "Exit status: 0 success, 1 invalid usage, configuration or domain name,\n" +
+	"             2 network failure or timeout, 3 no such domain, 4 rate limited,\n" +
+	"             5 malformed response, 6 unsupported TLD, 7 output not written,\n" +
+	"             8 registered (-available), 9 other failure, 10 stale data (-max-age),\n" +
+	"             11 alerts raised (watch)"
+
+func lookupExitCode(err error) int {
+	var ne net.Error
+	switch {
+	case errors.Is(err, qwis.ErrInvalidDomainName):
+		return 1
+	case errors.Is(err, qwis.ErrNoSuchDomain):
+		return 3
+	case errors.Is(err, qwis.ErrRateLimited):
+		return 4
+	case errors.Is(err, qwis.ErrParse):
+		return 5
+	case errors.Is(err, qwis.ErrUnsupportedTLD):
+		return 6
+	case errors.Is(err, qwis.ErrServerUnavailable), errors.Is(err, context.DeadlineExceeded), errors.As(err, &ne):
+		return 2
+	}
+	return 9
+}
+
+type effectiveConfig struct {
+	ConfigFile     string  `json:"config_file,omitempty"`
+	Format         string  `json:"format"`
+	Timeout        string  `json:"timeout"`
+	DialTimeout    string  `json:"dial_timeout"`
+	ReadTimeout    string  `json:"read_timeout"`
+	MaxResponse    int64   `json:"max_response_size"`
+	LocalAddr      string  `json:"local_addr,omitempty"`
+	Network        string  `json:"network"`
+	FallbackDelay  string  `json:"fallback_delay"`
+	QPS            float64 `json:"qps,omitempty"`
+	PerServerQPS   float64 `json:"qps_per_server,omitempty"`
+	Server         string  `json:"server,omitempty"`
+	CacheDir       string  `json:"cache_dir,omitempty"`
+	CacheTTL       string  `json:"cache_ttl,omitempty"`
+	HistoryFile    string  `json:"history_file,omitempty"`
+	Proxy          string  `json:"proxy,omitempty"`
+	CAFile         string  `json:"cafile,omitempty"`
+	TLS            bool    `json:"tls"`
+	Insecure       bool    `json:"insecure"`
+	Retries        int     `json:"retries"`
+	RetryBackoff   string  `json:"retry_backoff"`
+	MultiDomain    string  `json:"multi_domain"`
+	RawDates       bool    `json:"raw_dates"`
+	Concurrency    int     `json:"concurrency"`
+	NDJSON         bool    `json:"ndjson"`
+	EmitEmpty      bool    `json:"emit_empty_array"`
+	Registrable    bool    `json:"registrable"`
+	SortBy         string  `json:"sort_by,omitempty"`
+	ReuseConn      bool    `json:"reuse_conn"`
+	EmbedRaw       bool    `json:"embed_raw"`
+	RDAP           bool    `json:"rdap"`
+	CrossCheck     bool    `json:"cross_check"`
+	Parallel       bool    `json:"parallel_sources"`
+	RDAPTLDs       string  `json:"rdap_tlds"`
+	MaxAgeDays     int     `json:"max_age_days,omitempty"`
+	ExpiringWithin int     `json:"expiring_within,omitempty"`
+	NoReferrals    bool    `json:"no_referrals"`
+	NoNormalize    bool    `json:"no_normalize"`
+	Deep           bool    `json:"deep"`
+	DNS            bool    `json:"dns"`
+	Cert           bool    `json:"cert"`
+	HexDump        bool    `json:"hex_dump"`
+	AnnotateICANN  bool    `json:"annotate_icann"`
+	Confidence     bool    `json:"confidence"`
+}
+
+func printConfig(w io.Writer, c *effectiveConfig) error {
+	c.DialTimeout, c.ReadTimeout = qwis.Dialer.Timeout.String(), qwis.ReadTimeout.String()
+	c.MaxResponse = qwis.MaxResponseSize
+	c.MultiDomain, c.RawDates, c.Server = qwis.MultiDomain, qwis.KeepRawDates, qwis.Server
+	c.Retries, c.RetryBackoff = qwis.Retry.Attempts-1, qwis.Retry.Backoff.String()
+	c.ReuseConn, c.RDAPTLDs = qwis.ReuseConnections, strings.Join(qwis.RDAPOnlyTLDs, ",")
+	c.Network, c.FallbackDelay = qwis.Network, qwis.Dialer.FallbackDelay.String()
+	c.QPS, c.PerServerQPS = qwis.RateLimit.QPS, qwis.RateLimit.PerServerQPS
+	if qwis.Dialer.LocalAddr != nil {
+		c.LocalAddr = qwis.Dialer.LocalAddr.String()
+	}
+	cj, err := json.MarshalIndent(c, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(cj))
+	return err
+}
+
+// staleness reports why wir fails the -max-age check, if it does, with
+// stale set when the record is older than maxAge rather than undated.
+func staleness(wir *qwis.WhoisResponse, maxAge time.Duration, now time.Time) (reason string, stale bool) {
+	if wir.UpdatedTime.IsZero() {
+		return "no parseable updated date; freshness not checked", false
+	}
+	if age := now.Sub(wir.UpdatedTime); age > maxAge {
+		return fmt.Sprintf("record not updated for %d days (max %d)", int(age.Hours()/24), int(maxAge.Hours()/24)), true
+	}
+	return "", false
+}
+
+func cacheTTLString(cacheDir string, ttl time.Duration) string {
+	if len(cacheDir) == 0 {
+		return ""
+	}
+	return ttl.String()
+}
+
+func fieldsArg(s string) ([]string, error) {
+	known := qwis.JSONFieldNames()
+	var fields []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); !known[f] {
+			return nil, fmt.Errorf("Invalid field: %q", f)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func fieldMapArg(s string) (map[string]string, error) {
+	fm := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		sides := strings.SplitN(pair, "=", 2)
+		if len(sides) != 2 || len(sides[1]) == 0 {
+			return nil, fmt.Errorf("Invalid field mapping: %s", pair)
+		}
+		if _, ok := fm[sides[0]]; ok {
+			return nil, fmt.Errorf("Invalid field mapping: %s is mapped twice", sides[0])
+		}
+		fm[sides[0]] = sides[1]
+	}
+	if err := qwis.CheckFieldMap(fm); err != nil {
+		return nil, fmt.Errorf("Invalid field mapping: %s", err)
+	}
+	return fm, nil
+}
+
+func readDomains(path string, stdin io.Reader) ([]string, error) {
+	r, err := openDomains(path, stdin)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var domains []string
+	err = scanDomains(r, func(dn string) bool {
+		domains = append(domains, dn)
+		return true
+	})
+	return domains, err
+}
+
+// openDomains opens the domain list at path, "-" standing for stdin.
+func openDomains(path string, stdin io.Reader) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(stdin), nil
+	}
+	return os.Open(path)
+}
+
+// scanDomains passes each domain in r to send as soon as its line is read,
+// skipping blank lines and comments, until send returns false.
+func scanDomains(r io.Reader, send func(string) bool) error {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		if l := strings.TrimSpace(sc.Text()); len(l) != 0 && !strings.HasPrefix(l, "#") && !send(l) {
+			break
+		}
+	}
+	return sc.Err()
+}
+
+// newLogger logs to stderr, which keeps stdout machine-readable, at Info
+// level or, for -debug, Debug.
+func newLogger(stderr io.Writer, debug bool) *slog.Logger {
+	level := slog.LevelInfo
+	if debug {
+		level = slog.LevelDebug
+	}
+	return slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: level}))
+}
+
+// qpsArg parses the rate of -qps and -qps-per-server.
+func qpsArg(s string) (float64, error) {
+	qps, err := strconv.ParseFloat(s, 64)
+	if err != nil || !(qps >= 0) || math.IsInf(qps, 0) {
+		return 0, fmt.Errorf("Invalid rate: %s", s)
+	}
+	return qps, nil
+}
+
+func durationArg(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid duration: %s", s)
+	}
+	return d, nil
+}
+
+var optionsWithValue = map[string]bool{
+	"-template-file":     true,
+	"-format":            true,
+	"-output":            true,
+	"-list-sep":          true,
+	"-fields":            true,
+	"-field-map":         true,
+	"-multi-domain":      true,
+	"-t":                 true,
+	"-timeout":           true,
+	"-dial-timeout":      true,
+	"-read-timeout":      true,
+	"-max-response-size": true,
+	"-local-addr":        true,
+	"-source-ip":         true,
+	"-interface":         true,
+	"-config":            true,
+	"-fallback-delay":    true,
+	"-qps":               true,
+	"-qps-per-server":    true,
+	"-f":                 true,
+	"-c":                 true,
+	"-servers-file":      true,
+	"-server":            true,
+	"-query-templates":   true,
+	"-server-quirks":     true,
+	"-cache-ttl":         true,
+	"-history-file":      true,
+	"-retries":           true,
+	"-retry-backoff":     true,
+	"-proxy":             true,
+	"-cafile":            true,
+	"-ca-file":           true,
+	"-max-age":           true,
+	"-expiring-within":   true,
+	"-rdap-tlds":         true,
+	"-sort-by":           true,
+}
+
+// userConfigDir and userCacheDir locate the default config files and the
+// disk cache; tests point them at temporary directories.
+var (
+	userConfigDir = os.UserConfigDir
+	userCacheDir  = qwis.DefaultCacheDir
+)
+
+func loadConfigFile(path, name string, load func(io.Reader) error) error {
+	if len(path) == 0 {
+		dir, err := userConfigDir()
+		if err != nil {
+			return nil
+		}
+		path = filepath.Join(dir, "qwis", name)
+		if _, err = os.Stat(path); err != nil {
+			return nil
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return load(f)
+}
+
+func printServers(w io.Writer, asJSON bool) error {
+	ms := qwis.WhoisServers()
+	if asJSON {
+		return qwis.WriteIndentedJSON(w, ms)
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, m := range ms {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", m.TLD, m.Server, m.Source)
+	}
+	return tw.Flush()
+}
+
+type resourceResponse interface {
+	WriteAsJSON(w io.Writer) error
+	WriteAsRawText(w io.Writer) error
+}
+
+func runResourceLookup(ctx context.Context, q, format string, stdout, stderr io.Writer) int {
+	var (
+		r   resourceResponse
+		err error
+	)
+	if qwis.IsASNQuery(q) {
+		r, err = qwis.ASWhoisContext(ctx, q)
+	} else {
+		r, err = qwis.IPWhoisContext(ctx, q)
+	}
+	if err != nil {
+		return printErrorMessage(stderr, err.Error(), lookupExitCode(err))
+	}
+	writeAs := r.WriteAsJSON
+	if format == "raw" {
+		writeAs = r.WriteAsRawText
+	}
+	if err = writeAs(stdout); err != nil {
+		return printErrorMessage(stderr, err.Error(), 7)
+	}
+	return 0
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer, d qwis.DialFunc) int {
+	qwis.ResetWhoisServers()
+	qwis.ResetQueryTemplates()
+	qwis.ResetServerQuirks()
+	qwis.Dialer, qwis.ReadTimeout, qwis.MultiDomain, qwis.Dial = net.Dialer{}, qwis.DefaultReadTimeout, qwis.MultiDomainKeepFirst, d
+	qwis.MaxResponseSize = qwis.DefaultMaxResponseSize
+	qwis.KeepRawDates, qwis.Server, qwis.ResponseCache, qwis.ReuseConnections = false, "", nil, false
+	qwis.RecordFieldSources, qwis.RDAPOnlyTLDs = false, qwis.DefaultRDAPOnlyTLDs
+	qwis.Retry, qwis.RDAPClient, qwis.RootCAs = qwis.DefaultRetryPolicy, &http.Client{}, nil
+	qwis.WhoisTLS, qwis.InsecureSkipVerify, qwis.Network = false, false, "tcp"
+	qwis.RateLimit, qwis.Logger, qwis.LookupHooks = qwis.RateLimitPolicy{}, nil, qwis.Hooks{}
+	if len(args) == 0 {
+		return printHelpMessage(stdout, "")
+	}
+	var command string
+	switch args[0] {
+	case "serve":
+		return runServe(args[1:], stdout, stderr)
+	case "watch":
+		return runWatch(args[1:], stdin, stdout, stderr)
+	case "report":
+		return runReport(args[1:], stdin, stdout, stderr)
+	case "completion":
+		return runCompletion(args[1:], stdout, stderr)
+	case "lookup", "config":
+		command, args = args[0], args[1:]
+	}
+	args, operands, err := splitArgs(args, optionsWithValue)
+	if err != nil {
+		return printErrorMessage(stderr, err.Error(), 1)
+	}
+	if len(command) == 0 && len(operands) != 0 {
+		switch operands[0] {
+		case "servers":
+			command = "servers"
+		case "history", "diff":
+			command, operands = operands[0], operands[1:]
+		}
+	}
+	if helpRequested(args, optionsWithValue) {
+		return printHelpMessage(stdout, command)
+	}
+	if command == "config" {
+		if len(operands) != 1 || operands[0] != "show" {
+			return printErrorMessage(stderr, "Invalid set of arguments", 1)
+		}
+		args, operands = append(args, "-print-config"), nil
+	}
+	configPath := findConfig()
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] == "-config" {
+			configPath = args[i+1]
+		}
+		if optionsWithValue[args[i]] {
+			i++
+		}
+	}
+	var fc *fileConfig
+	if len(configPath) != 0 {
+		if fc, err = readConfig(configPath); err != nil {
+			return printErrorMessage(stderr, err.Error(), 1)
+		}
+		args = append(fc.args[:len(fc.args):len(fc.args)], args...)
+	}
+	var (
+		hexDump            bool
+		iface              string
+		insecure           bool
+		annotateICANN      bool
+		confidence         bool
+		printConfigNow     bool
+		rawRequested       bool
+		useRDAP            bool
+		crossCheck         bool
+		parallelSources    bool
+		maxAgeDays         int
+		expiringWithin     int
+		noReferrals        bool
+		noNormalize        bool
+		deep               bool
+		resolveDNS         bool
+		fetchCert          bool
+		interactive        bool
+		timeout            time.Duration
+		jsonRequested      bool
+		ndjson             bool
+		emitEmpty          bool
+		registrable        bool
+		sortBy             string
+		inputFile          string
+		serversFile        string
+		queryTemplatesFile string
+		serverQuirksFile   string
+		proxyURL           = os.Getenv("ALL_PROXY")
+		caFile             string
+		noCache            bool
+		recordHistory      bool
+		compareSnapshots   bool
+		historyFile        string
+		cacheTTL           = time.Hour
+		concurrency        = 8
+		format             = "json"
+		writeAs            = (*qwis.WhoisResponse).WriteAsJSON
+		jsonValue          = func(wir *qwis.WhoisResponse) (interface{}, error) { return wir, nil }
+		fieldMap           map[string]string
+		listSep            = ";"
+		tableFields        = qwis.DefaultTableFields
+		fields             []string
+	)
+	for ; len(args) > 0; args = args[1:] {
+		a, v := args[0], ""
+		if optionsWithValue[a] {
+			if len(args) < 2 {
+				return printErrorMessage(stderr, "Invalid set of arguments", 1)
+			}
+			args = args[1:]
+			v = args[0]
+		}
+		var err error
+		switch a {
+		case "-r":
+			rawRequested = true
+			format, writeAs = "raw", (*qwis.WhoisResponse).WriteAsRawText
+		case "-j":
+			jsonRequested = true
+			format, writeAs = "json", (*qwis.WhoisResponse).WriteAsJSON
+		case "-n":
+			format, writeAs = "expiration", (*qwis.WhoisResponse).WriteAsExpirationDate
+		case "-output":
+			switch v {
+			case "json":
+				jsonRequested = true
+				format, writeAs = "json", (*qwis.WhoisResponse).WriteAsJSON
+			case "csv", "tsv":
+				format = v
+			default:
+				e, ok := qwis.Encoders[v]
+				if !ok {
+					err = fmt.Errorf("Invalid output format: %s", v)
+					break
+				}
+				format = v
+				writeAs = func(wir *qwis.WhoisResponse, w io.Writer) error { return e.Encode(w, wir) }
+			}
+		case "-list-sep":
+			listSep = v
+		case "-fields":
+			fields, err = fieldsArg(v)
+		case "-ics":
+			format, writeAs = "ics", func(wir *qwis.WhoisResponse, w io.Writer) error {
+				return qwis.WriteICalendar(w, []*qwis.WhoisResponse{wir})
+			}
+		case "-available":
+			format, writeAs = "available", (*qwis.WhoisResponse).WriteAsAvailability
+		case "-posture":
+			format, writeAs = "posture", (*qwis.WhoisResponse).WriteSecurityPostureAsJSON
+		case "-rdap":
+			useRDAP = true
+		case "-cross-check":
+			crossCheck = true
+		case "-fastest", "-parallel-sources":
+			parallelSources = true
+		case "-rdap-tlds":
+			qwis.RDAPOnlyTLDs = nil
+			for _, tld := range strings.Split(v, ",") {
+				if tld = strings.TrimSpace(tld); len(tld) != 0 {
+					qwis.RDAPOnlyTLDs = append(qwis.RDAPOnlyTLDs, tld)
+				}
+			}
+		case "-max-age":
+			if maxAgeDays, err = strconv.Atoi(v); err == nil && maxAgeDays < 1 {
+				err = fmt.Errorf("Invalid max age: %s", v)
+			}
+		case "-expiring-within":
+			if expiringWithin, err = strconv.Atoi(v); err == nil && expiringWithin < 1 {
+				err = fmt.Errorf("Invalid number of days: %s", v)
+			}
+		case "-no-referrals":
+			noReferrals = true
+		case "-no-normalize":
+			noNormalize = true
+		case "-deep":
+			deep = true
+		case "-dns":
+			resolveDNS = true
+		case "-cert":
+			fetchCert = true
+		case "-i":
+			interactive = true
+		case "-raw-dates":
+			qwis.KeepRawDates = true
+		case "-hex-dump":
+			hexDump = true
+		case "-verbose", "-v", "-debug":
+			qwis.Logger = newLogger(stderr, a == "-debug")
+		case "-4", "-6":
+			qwis.Network = "tcp" + a[1:]
+		case "-config":
+		case "-tls":
+			qwis.WhoisTLS = true
+		case "-insecure":
+			insecure = true
+		case "-annotate-icann":
+			annotateICANN = true
+		case "-confidence":
+			confidence = true
+			qwis.RecordFieldSources = true
+		case "-print-config":
+			printConfigNow = true
+		case "-format":
+			if !strings.HasSuffix(v, "\n") {
+				v += "\n"
+			}
+			var t *template.Template
+			if t, err = template.New("format").Parse(v); err == nil {
+				format, writeAs = "template", func(wir *qwis.WhoisResponse, w io.Writer) error {
+					return wir.WriteWithTemplate(w, t)
+				}
+			}
+		case "-template-file":
+			var t *template.Template
+			if t, err = template.ParseFiles(v); err == nil {
+				format, writeAs = "template", func(wir *qwis.WhoisResponse, w io.Writer) error {
+					return wir.WriteWithTemplate(w, t)
+				}
+			}
+		case "-field-map":
+			fieldMap, err = fieldMapArg(v)
+		case "-multi-domain":
+			switch v {
+			case qwis.MultiDomainKeepFirst, qwis.MultiDomainKeepLast, qwis.MultiDomainError:
+				qwis.MultiDomain = v
+			default:
+				err = fmt.Errorf("Invalid multi-domain mode: %s", v)
+			}
+		case "-t":
+			qwis.Dialer.Timeout, err = durationArg(v)
+			qwis.ReadTimeout = qwis.Dialer.Timeout
+		case "-timeout":
+			timeout, err = durationArg(v)
+		case "-dial-timeout":
+			qwis.Dialer.Timeout, err = durationArg(v)
+		case "-read-timeout":
+			qwis.ReadTimeout, err = durationArg(v)
+		case "-max-response-size":
+			if qwis.MaxResponseSize, err = strconv.ParseInt(v, 10, 64); err == nil && qwis.MaxResponseSize < 0 {
+				err = fmt.Errorf("Invalid response size: %s", v)
+			}
+		case "-f":
+			inputFile = v
+		case "-c":
+			if concurrency, err = strconv.Atoi(v); err == nil && concurrency < 1 {
+				err = fmt.Errorf("Invalid concurrency: %s", v)
+			}
+		case "-ndjson":
+			ndjson = true
+		case "-emit-empty-array":
+			emitEmpty = true
+		case "-registrable":
+			registrable = true
+		case "-sort-by":
+			switch v {
+			case qwis.SortByExpiration, qwis.SortByDomain, qwis.SortByRegistrar:
+				sortBy = v
+			default:
+				err = fmt.Errorf("Invalid sort key: %s", v)
+			}
+		case "-reuse-conn":
+			qwis.ReuseConnections = true
+		case "-servers-file":
+			serversFile = v
+		case "-server":
+			qwis.Server = v
+		case "-query-templates":
+			queryTemplatesFile = v
+		case "-server-quirks":
+			serverQuirksFile = v
+		case "-no-cache":
+			noCache = true
+		case "-history":
+			recordHistory = true
+		case "-compare-snapshots":
+			compareSnapshots = true
+		case "-history-file":
+			historyFile = v
+		case "-cache-ttl":
+			cacheTTL, err = durationArg(v)
+		case "-retries":
+			var n int
+			if n, err = strconv.Atoi(v); err == nil && n < 0 {
+				err = fmt.Errorf("Invalid number of retries: %s", v)
+			}
+			qwis.Retry.Attempts = n + 1
+		case "-retry-backoff":
+			qwis.Retry.Backoff, err = durationArg(v)
+		case "-proxy":
+			proxyURL = v
+		case "-cafile", "-ca-file":
+			caFile = v
+		case "-interface":
+			iface = v
+		case "-qps":
+			qwis.RateLimit.QPS, err = qpsArg(v)
+		case "-qps-per-server":
+			qwis.RateLimit.PerServerQPS, err = qpsArg(v)
+		case "-fallback-delay":
+			qwis.Dialer.FallbackDelay, err = durationArg(v)
+		case "-local-addr", "-source-ip":
+			ip := net.ParseIP(v)
+			if ip == nil {
+				err = fmt.Errorf("Invalid local address")
+			}
+			qwis.Dialer.LocalAddr = &net.TCPAddr{IP: ip}
+		default:
+			err = fmt.Errorf("Invalid set of arguments")
+		}
+		if err != nil {
+			return printErrorMessage(stderr, err.Error(), 1)
+		}
+	}
+	args = operands
+	if len(iface) != 0 {
+		if err := qwis.BindInterface(iface); err != nil {
+			return printErrorMessage(stderr, err.Error(), 1)
+		}
+	}
+	defer qwis.CloseIdleConnections()
+	if useRDAP && crossCheck {
+		return printErrorMessage(stderr, "-cross-check already queries RDAP; drop -rdap", 1)
+	}
+	if parallelSources && (useRDAP || crossCheck) {
+		return printErrorMessage(stderr, "-fastest cannot be combined with -rdap or -cross-check", 1)
+	}
+	qwis.FollowReferrals = !noReferrals
+	if fc != nil && len(fc.servers) != 0 {
+		if err := qwis.LoadWhoisServers(strings.NewReader(fc.servers)); err != nil {
+			return printErrorMessage(stderr, fmt.Sprintf("%s: %s", fc.path, err), 1)
+		}
+	}
+	if err := loadConfigFile(serversFile, "servers.txt", qwis.LoadWhoisServers); err != nil {
+		return printErrorMessage(stderr, err.Error(), 1)
+	}
+	if err := loadConfigFile(queryTemplatesFile, "query-templates.txt", qwis.LoadQueryTemplates); err != nil {
+		return printErrorMessage(stderr, err.Error(), 1)
+	}
+	if err := loadConfigFile(serverQuirksFile, "server-quirks.txt", qwis.LoadServerQuirks); err != nil {
+		return printErrorMessage(stderr, err.Error(), 1)
+	}
+	if len(proxyURL) == 0 {
+		proxyURL = os.Getenv("all_proxy")
+	}
+	if len(proxyURL) != 0 {
+		if err := qwis.SetProxy(proxyURL); err != nil {
+			return printErrorMessage(stderr, err.Error(), 1)
+		}
+	}
+	if len(caFile) != 0 {
+		if err := qwis.LoadRootCAs(caFile); err != nil {
+			return printErrorMessage(stderr, err.Error(), 1)
+		}
+	}
+	if insecure {
+		qwis.SetInsecureSkipVerify(true)
+	}
+	var cacheDir string
+	if !noCache && cacheTTL > 0 {
+		if dir, err := userCacheDir(); err == nil {
+			if dc, err := qwis.NewDiskCache(dir, cacheTTL); err == nil {
+				qwis.ResponseCache, cacheDir = dc, dir
+			}
+		}
+	}
+	if (command == "history" || command == "diff" || recordHistory || compareSnapshots) && len(historyFile) == 0 {
+		var err error
+		if historyFile, err = defaultHistoryFile(); err != nil {
+			return printErrorMessage(stderr, err.Error(), 1)
+		}
+	}
+	if command == "history" || command == "diff" {
+		return runHistory(command, historyFile, args, jsonRequested, stdout, stderr)
+	}
+	if len(args) == 2 && args[0] == "servers" && args[1] == "list" {
+		if err := printServers(stdout, jsonRequested); err != nil {
+			return printErrorMessage(stderr, err.Error(), 7)
+		}
+		return 0
+	}
+	embedRaw := rawRequested && jsonRequested
+	if embedRaw {
+		format, writeAs = "json", (*qwis.WhoisResponse).WriteAsJSON
+	}
+	if fieldMap != nil && format != "json" {
+		return printErrorMessage(stderr, "-field-map applies to JSON output only", 1)
+	}
+	if fields != nil {
+		if format != "json" && format != "csv" && format != "tsv" {
+			return printErrorMessage(stderr, "-fields applies to JSON, CSV and TSV output only", 1)
+		}
+		tableFields = fields
+	}
+	if fieldMap != nil || fields != nil {
+		jsonValue = func(wir *qwis.WhoisResponse) (interface{}, error) {
+			return wir.SelectFields(fields, fieldMap)
+		}
+		writeAs = func(wir *qwis.WhoisResponse, w io.Writer) error {
+			v, err := jsonValue(wir)
+			if err != nil {
+				return err
+			}
+			return qwis.WriteIndentedJSON(w, v)
+		}
+	}
+	if printConfigNow {
+		err := printConfig(stdout, &effectiveConfig{
+			ConfigFile:     configFile(fc),
+			Format:         format,
+			EmbedRaw:       embedRaw,
+			RDAP:           useRDAP,
+			CrossCheck:     crossCheck,
+			Parallel:       parallelSources,
+			MaxAgeDays:     maxAgeDays,
+			ExpiringWithin: expiringWithin,
+			NoReferrals:    noReferrals,
+			NoNormalize:    noNormalize,
+			Deep:           deep,
+			DNS:            resolveDNS,
+			Cert:           fetchCert,
+			Timeout:        timeout.String(),
+			Concurrency:    concurrency,
+			NDJSON:         ndjson,
+			EmitEmpty:      emitEmpty,
+			Registrable:    registrable,
+			SortBy:         sortBy,
+			HexDump:        hexDump,
+			AnnotateICANN:  annotateICANN,
+			Confidence:     confidence,
+			CacheDir:       cacheDir,
+			Proxy:          proxyURL,
+			CAFile:         caFile,
+			TLS:            qwis.WhoisTLS,
+			Insecure:       insecure,
+			CacheTTL:       cacheTTLString(cacheDir, cacheTTL),
+			HistoryFile:    historyFile,
+		})
+		if err != nil {
+			return printErrorMessage(stderr, err.Error(), 7)
+		}
+		return 0
+	}
+	domains := args
+	// NDJSON lines need neither the whole list nor each other, so they are
+	// written as lookups complete and the list is looked up as it is read.
+	stream := ndjson && format == "json" && len(sortBy) == 0 && !registrable && !compareSnapshots && len(inputFile) != 0
+	if len(inputFile) != 0 && !stream {
+		fd, err := readDomains(inputFile, stdin)
+		if err != nil {
+			return printErrorMessage(stderr, err.Error(), 1)
+		}
+		domains = append(domains, fd...)
+	}
+	if interactive && (len(domains) != 0 || len(inputFile) != 0 || deep || expiringWithin > 0 || format == "csv" || format == "tsv") {
+		return printErrorMessage(stderr, "-i takes queries at its prompt and cannot be combined with domain names, -f, -deep, -expiring-within or CSV and TSV output", 1)
+	}
+	if len(domains) == 0 && len(inputFile) == 0 && !interactive {
+		return printErrorMessage(stderr, "Invalid set of arguments", 1)
+	}
+	// -i has no domain names up front, so it skips the paths of single
+	// lookups, which take domains[0].
+	batch := len(domains) > 1 || len(inputFile) != 0 || interactive
+	// URLs and email addresses are looked up by the registrable domain of
+	// their host unless -no-normalize is given.
+	normalize := qwis.ExtractDomain
+	if noNormalize {
+		normalize = func(q string) string { return q }
+	}
+	if !batch {
+		domains[0] = normalize(domains[0])
+	}
+	ctx := context.Background()
+	if timeout > 0 && !interactive {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if !batch && (qwis.IsIPQuery(domains[0]) || qwis.IsASNQuery(domains[0])) {
+		return runResourceLookup(ctx, domains[0], format, stdout, stderr)
+	}
+	if batch && deep {
+		return printErrorMessage(stderr, "-deep applies to single lookups only", 1)
+	}
+	if compareSnapshots && (deep || interactive) {
+		return printErrorMessage(stderr, "-compare-snapshots cannot be combined with -deep or -i", 1)
+	}
+	if !batch && expiringWithin > 0 {
+		return printErrorMessage(stderr, "-expiring-within applies to batch lookups only", 1)
+	}
+	if !batch && registrable {
+		if rd, err := qwis.RegistrableDomain(domains[0]); err == nil {
+			domains[0] = rd
+		}
+	}
+	if !batch && format == "raw" && !deep && !hexDump && !useRDAP && !parallelSources && !qwis.IsRDAPOnly(qwis.TopLevelDomain(domains[0])) {
+		rs, err := qwis.WhoisRawStreamContext(ctx, domains[0])
+		if err != nil {
+			return printErrorMessage(stderr, err.Error(), lookupExitCode(err))
+		}
+		defer rs.Close()
+		if _, err = io.Copy(stdout, qwis.UTF8Reader(rs, qwis.TopLevelDomain(domains[0]))); err != nil {
+			return printErrorMessage(stderr, err.Error(), 7)
+		}
+		return 0
+	}
+	var (
+		stderrMu sync.Mutex
+		stale    bool
+	)
+	maxAge := time.Duration(maxAgeDays) * 24 * time.Hour
+	var history, snapshots *historyStore
+	if recordHistory || compareSnapshots {
+		h, err := openHistory(historyFile)
+		if err != nil {
+			return printErrorMessage(stderr, err.Error(), 9)
+		}
+		defer h.Close()
+		if recordHistory {
+			history = h
+		}
+		if compareSnapshots {
+			snapshots = h
+		}
+	}
+	lookup := func(ctx context.Context, dn string) (*qwis.WhoisResponse, error) {
+		dn, err := qwis.ToASCII(normalize(dn))
+		if err != nil {
+			return nil, err
+		}
+		var server string
+		fetch := func(ctx context.Context, dn string) (raw []byte, err error) {
+			raw, server, err = qwis.WhoisRawServerContext(ctx, dn)
+			return raw, err
+		}
+		parse := func(raw []byte) (*qwis.WhoisResponse, error) {
+			return qwis.ParseServerResponse(raw, server, dn)
+		}
+		rdap := useRDAP || qwis.IsRDAPOnly(qwis.TopLevelDomain(dn))
+		if rdap {
+			fetch, parse = qwis.RDAPRawContext, qwis.ParseRDAPResponse
+		}
+		var wir *qwis.WhoisResponse
+		if parallelSources && !rdap {
+			if wir, err = qwis.RaceSourcesContext(ctx, dn); err != nil {
+				return nil, err
+			}
+			rdap = wir.Source == qwis.SourceRDAP
+		} else {
+			raw, err := fetch(ctx, dn)
+			if err != nil {
+				return nil, err
+			}
+			if hexDump {
+				stderrMu.Lock()
+				fmt.Fprint(stderr, hex.Dump(raw))
+				stderrMu.Unlock()
+			}
+			if wir, err = parse(raw); err != nil {
+				return nil, err
+			}
+			if !rdap && !noReferrals {
+				if err = qwis.FollowReferral(ctx, wir, dn); err != nil {
+					stderrMu.Lock()
+					fmt.Fprintf(stderr, "Warning: %s: referral not followed: %s\n", dn, err)
+					stderrMu.Unlock()
+				}
+			}
+		}
+		wir.InferDomainName(dn)
+		if format != "available" && format != "raw" && wir.IsAvailable() {
+			return nil, fmt.Errorf("Whois: %w: %s", qwis.ErrNoSuchDomain, dn)
+		}
+		if crossCheck && !rdap {
+			if rir, err := qwis.RDAPContext(ctx, dn); err != nil {
+				stderrMu.Lock()
+				fmt.Fprintf(stderr, "Warning: %s: not cross-checked: %s\n", dn, err)
+				stderrMu.Unlock()
+			} else {
+				wir.Discrepancies = qwis.CompareResponses(wir, rir)
+			}
+		}
+		if resolveDNS {
+			wir.CheckDNS(ctx, dn)
+		}
+		if fetchCert {
+			wir.CheckCertificate(ctx, dn)
+		}
+		if maxAge > 0 {
+			if reason, s := staleness(wir, maxAge, qwis.Now()); len(reason) != 0 {
+				stderrMu.Lock()
+				fmt.Fprintf(stderr, "Warning: %s: %s\n", dn, reason)
+				stale = stale || s
+				stderrMu.Unlock()
+			}
+		}
+		if format == "ics" && wir.ExpirationTime.IsZero() {
+			stderrMu.Lock()
+			fmt.Fprintf(stderr, "Warning: %s: no parseable expiration date; left out of the calendar\n", dn)
+			stderrMu.Unlock()
+		}
+		if history != nil {
+			if err := history.record(dn, wir, qwis.Now()); err != nil {
+				stderrMu.Lock()
+				fmt.Fprintf(stderr, "Warning: %s: not recorded in the history: %s\n", dn, err)
+				stderrMu.Unlock()
+			}
+		}
+		wir.SetDaysUntilExpiry(qwis.Now())
+		if annotateICANN {
+			wir.AnnotateStatuses()
+		}
+		if embedRaw {
+			wir.RawText = string(wir.Raw())
+		}
+		return wir, nil
+	}
+	if deep {
+		// The registrations looked up are written as one JSON document,
+		// whatever the output format.
+		inf, err := qwis.DeepLookup(ctx, domains[0], concurrency, lookup)
+		if err != nil {
+			return printErrorMessage(stderr, err.Error(), lookupExitCode(err))
+		}
+		if err = qwis.WriteIndentedJSON(stdout, inf); err != nil {
+			return printErrorMessage(stderr, err.Error(), 7)
+		}
+		if stale {
+			return 10
+		}
+		return 0
+	}
+	if snapshots != nil {
+		key := func(dn string) (string, error) { return qwis.ToASCII(normalize(dn)) }
+		return compareWithSnapshots(ctx, snapshots, domains, key, concurrency, lookup, stdout, stderr)
+	}
+	if interactive {
+		r := &repl{lookup: lookup, format: format, write: writeAs, timeout: timeout, stdout: stdout, stderr: stderr}
+		return r.run(stdin)
+	}
+	if !batch {
+		wir, err := lookup(ctx, domains[0])
+		if format == "available" && errors.Is(err, qwis.ErrNoSuchDomain) {
+			fmt.Fprintln(stdout, "available")
+			return 0
+		}
+		if err != nil {
+			return printErrorMessage(stderr, err.Error(), lookupExitCode(err))
+		}
+		if format == "csv" || format == "tsv" {
+			if ec := writeTable([]qwis.BatchResult{{Domain: domains[0], Response: wir}}, format, tableFields, listSep, stdout, stderr); ec != 0 {
+				return ec
+			}
+		} else if err = writeAs(wir, stdout); err != nil {
+			return printErrorMessage(stderr, err.Error(), 7)
+		}
+		if format == "available" && !wir.IsAvailable() {
+			return 8
+		}
+		if stale {
+			return 10
+		}
+		return 0
+	}
+	// keep leaves out of batch output the domains not expiring within
+	// -expiring-within days; failed lookups are always written.
+	keep := func(r qwis.BatchResult) bool {
+		if expiringWithin == 0 || r.Err != nil || r.Response == nil {
+			return true
+		}
+		d := r.Response.DaysUntilExpiry
+		return d != nil && *d <= expiringWithin
+	}
+	if stream {
+		ec := streamBatch(ctx, domains, inputFile, stdin, concurrency, lookup, keep, jsonValue, emitEmpty, stdout, stderr)
+		if ec == 0 && stale {
+			return 10
+		}
+		return ec
+	}
+	batchLookup := qwis.BatchLookup
+	if registrable {
+		batchLookup = qwis.BatchLookupRegistrable
+	}
+	var results []qwis.BatchResult
+	for _, r := range batchLookup(ctx, domains, concurrency, lookup) {
+		if keep(r) {
+			results = append(results, r)
+		}
+	}
+	if len(sortBy) != 0 {
+		qwis.SortBatchResults(results, sortBy)
+	}
+	var ec int
+	if format == "csv" || format == "tsv" {
+		ec = writeTable(results, format, tableFields, listSep, stdout, stderr)
+	} else {
+		ec = writeBatch(results, format, ndjson, emitEmpty, writeAs, jsonValue, stdout, stderr)
+	}
+	if ec == 0 && stale {
+		return 10
+	}
+	return ec
+}
+
+type batchEntry struct {
+	Domain   string      `json:"domain"`
+	Response interface{} `json:"response,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// writeTable writes results as CSV or TSV: a header row, then the query,
+// the chosen fields and any error for every result.
+func writeTable(results []qwis.BatchResult, format string, fields []string, listSep string, stdout, stderr io.Writer) int {
+	cw := csv.NewWriter(stdout)
+	if format == "tsv" {
+		cw.Comma = '\t'
+	}
+	cw.Write(append(append([]string{"domain"}, fields...), "error"))
+	ec := 0
+	for _, r := range results {
+		row, errText := make([]string, len(fields)), ""
+		switch {
+		case r.Err != nil:
+			errText, ec = r.Err.Error(), lookupExitCode(r.Err)
+		case r.Response != nil:
+			var err error
+			if row, err = r.Response.FieldStrings(fields, listSep); err != nil {
+				return printErrorMessage(stderr, err.Error(), 7)
+			}
+		}
+		cw.Write(append(append([]string{r.Domain}, row...), errText))
+	}
+	if cw.Flush(); cw.Error() != nil {
+		return printErrorMessage(stderr, cw.Error().Error(), 7)
+	}
+	return ec
+}
+
+// batchResource returns the IP or AS whois response in r, if any.
+func batchResource(r qwis.BatchResult) resourceResponse {
+	switch {
+	case r.IP != nil:
+		return r.IP
+	case r.AS != nil:
+		return r.AS
+	}
+	return nil
+}
+
+// writeBatch writes results in format. With ndjson, emitEmpty has "[]"
+// written for no results, so that consumers always get JSON.
+func writeBatch(results []qwis.BatchResult, format string, ndjson, emitEmpty bool,
+	writeAs func(*qwis.WhoisResponse, io.Writer) error,
+	jsonValue func(*qwis.WhoisResponse) (interface{}, error),
+	stdout, stderr io.Writer) int {
+	ec := 0
+	if format == "ics" {
+		var responses []*qwis.WhoisResponse
+		for _, r := range results {
+			if r.Err != nil {
+				ec = printErrorMessage(stderr, r.Domain+": "+r.Err.Error(), lookupExitCode(r.Err))
+				continue
+			}
+			if r.Response != nil {
+				responses = append(responses, r.Response)
+			}
+		}
+		if err := qwis.WriteICalendar(stdout, responses); err != nil {
+			return printErrorMessage(stderr, err.Error(), 7)
+		}
+		return ec
+	}
+	if format == "expiration" || format == "available" {
+		// One "domain<TAB>value" line per input, with "-" standing in for
+		// failed lookups, so that lines stay matched to domains.
+		registered := false
+		for _, r := range results {
+			value := "-"
+			switch {
+			case r.Err == nil && r.Response == nil:
+				// IP and AS numbers have neither an expiry nor availability.
+			case r.Err == nil && format == "expiration":
+				if len(r.Response.ExpirationDate) != 0 {
+					value = r.Response.ExpirationDate
+				}
+			case r.Err == nil && r.Response.IsAvailable(), errors.Is(r.Err, qwis.ErrNoSuchDomain) && format == "available":
+				value = "available"
+			case r.Err == nil:
+				value, registered = "registered", true
+			default:
+				ec = printErrorMessage(stderr, r.Domain+": "+r.Err.Error(), lookupExitCode(r.Err))
+			}
+			if _, err := fmt.Fprintf(stdout, "%s\t%s\n", r.Domain, value); err != nil {
+				return printErrorMessage(stderr, err.Error(), 7)
+			}
+		}
+		if ec == 0 && registered {
+			return 8
+		}
+		return ec
+	}
+	if format != "json" {
+		for _, r := range results {
+			if r.Err != nil {
+				ec = printErrorMessage(stderr, r.Domain+": "+r.Err.Error(), lookupExitCode(r.Err))
+				continue
+			}
+			if res := batchResource(r); res != nil {
+				writeRes := res.WriteAsJSON
+				if format == "raw" {
+					writeRes = res.WriteAsRawText
+				}
+				if err := writeRes(stdout); err != nil {
+					return printErrorMessage(stderr, err.Error(), 7)
+				}
+				continue
+			}
+			if err := writeAs(r.Response, stdout); err != nil {
+				return printErrorMessage(stderr, err.Error(), 7)
+			}
+		}
+		return ec
+	}
+	entries := make([]batchEntry, len(results))
+	for i, r := range results {
+		var rc int
+		if entries[i], rc = newBatchEntry(r, jsonValue); rc != 0 {
+			ec = rc
+		}
+	}
+	if ndjson {
+		enc := json.NewEncoder(stdout)
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				return printErrorMessage(stderr, err.Error(), 7)
+			}
+		}
+		if emitEmpty && len(entries) == 0 {
+			if _, err := fmt.Fprintln(stdout, "[]"); err != nil {
+				return printErrorMessage(stderr, err.Error(), 7)
+			}
+		}
+		return ec
+	}
+	if err := qwis.WriteIndentedJSON(stdout, entries); err != nil {
+		return printErrorMessage(stderr, err.Error(), 7)
+	}
+	return ec
+}
+
+// newBatchEntry returns r as written to JSON and the exit code of its
+// error, 0 if it has none.
+func newBatchEntry(r qwis.BatchResult, jsonValue func(*qwis.WhoisResponse) (interface{}, error)) (batchEntry, int) {
+	e := batchEntry{Domain: r.Domain}
+	if res := batchResource(r); res != nil {
+		e.Response = res
+	} else if r.Err == nil {
+		e.Response, r.Err = jsonValue(r.Response)
+	}
+	if r.Err != nil {
+		e.Error = r.Err.Error()
+		return e, lookupExitCode(r.Err)
+	}
+	return e, 0
+}
+
+// streamBatch looks up domains and then those of the list at path as its
+// lines are read, writing each result as an NDJSON line once it is in, or
+// "[]" if none is kept and emitEmpty is set.
+func streamBatch(ctx context.Context, domains []string, path string, stdin io.Reader, concurrency int,
+	lookup qwis.LookupFunc, keep func(qwis.BatchResult) bool, jsonValue func(*qwis.WhoisResponse) (interface{}, error),
+	emitEmpty bool, stdout, stderr io.Writer) int {
+	r, err := openDomains(path, stdin)
+	if err != nil {
+		return printErrorMessage(stderr, err.Error(), 1)
+	}
+	defer r.Close()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		queries = make(chan string)
+		scanErr error
+	)
+	go func() {
+		defer close(queries)
+		send := func(dn string) bool {
+			select {
+			case queries <- dn:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		for _, dn := range domains {
+			if !send(dn) {
+				return
+			}
+		}
+		scanErr = scanDomains(r, send)
+	}()
+	ec, written := 0, 0
+	enc := json.NewEncoder(stdout)
+	for res := range qwis.BatchLookupChan(ctx, queries, concurrency, lookup) {
+		if !keep(res) {
+			continue
+		}
+		e, rc := newBatchEntry(res, jsonValue)
+		if rc != 0 {
+			ec = rc
+		}
+		if err := enc.Encode(e); err != nil {
+			return printErrorMessage(stderr, err.Error(), 7)
+		}
+		written++
+	}
+	if emitEmpty && written == 0 {
+		if _, err := fmt.Fprintln(stdout, "[]"); err != nil {
+			return printErrorMessage(stderr, err.Error(), 7)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return printErrorMessage(stderr, err.Error(), lookupExitCode(err))
+	}
+	if scanErr != nil {
+		return printErrorMessage(stderr, scanErr.Error(), 1)
+	}
+	return ec
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr, qwis.Dialer.DialContext))
+}
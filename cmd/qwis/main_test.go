@@ -0,0 +1,1026 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pkorotkov/qwis"
+	"github.com/pkorotkov/qwis/internal/whoistest"
+)
+
+const exampleCom = "Domain Name: EXAMPLE.COM\r\n" +
+	"Registrar: Example Registrar, Inc.\r\n" +
+	"Creation Date: 1995-08-14T04:00:00Z\r\n" +
+	"Registry Expiry Date: 2026-08-13T04:00:00Z\r\n" +
+	"Domain Status: clientTransferProhibited https://icann.org/epp#clientTransferProhibited\r\n" +
+	"Name Server: A.IANA-SERVERS.NET\r\n"
+
+func runCLI(t *testing.T, stdin string, fs *whoistest.FakeServers, args ...string) (int, string, string) {
+	t.Helper()
+	if fs == nil {
+		fs = &whoistest.FakeServers{}
+	}
+	return runDialing(t, stdin, fs.Dial, args...)
+}
+
+// runDialing runs the CLI with dial, isolated from the user's config and
+// cache directories and proxy settings.
+func runDialing(t *testing.T, stdin string, dial qwis.DialFunc, args ...string) (int, string, string) {
+	t.Helper()
+	configDir, cacheDir := t.TempDir(), t.TempDir()
+	userConfigDir = func() (string, error) { return configDir, nil }
+	userCacheDir = func() (string, error) { return cacheDir, nil }
+	t.Setenv("ALL_PROXY", "")
+	t.Setenv("all_proxy", "")
+	var stdout, stderr bytes.Buffer
+	ec := run(args, strings.NewReader(stdin), &stdout, &stderr, dial)
+	return ec, stdout.String(), stderr.String()
+}
+
+func TestRunHelp(t *testing.T) {
+	for _, args := range [][]string{nil, {"-h"}} {
+		ec, stdout, _ := runCLI(t, "", nil, args...)
+		if ec != 0 || !strings.Contains(stdout, "Usage:") {
+			t.Errorf("run(%q) = %d, %q", args, ec, stdout)
+		}
+	}
+}
+
+func TestRunVersion(t *testing.T) {
+	_, stdout, _ := runCLI(t, "", nil, "-h")
+	if !strings.Contains(stdout, "Version: "+version+"\n") {
+		t.Errorf("help lacks version %s: %q", version, stdout)
+	}
+}
+
+func TestRunLookup(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	ec, stdout, stderr := runCLI(t, "", fs, "example.com")
+	if ec != 0 {
+		t.Fatalf("exit code %d, stderr %q", ec, stderr)
+	}
+	for _, want := range []string{`"domain_name": "EXAMPLE.COM"`, `"registrar": "Example Registrar, Inc."`} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("output lacks %s:\n%s", want, stdout)
+		}
+	}
+}
+
+func TestRunLookupError(t *testing.T) {
+	ec, stdout, stderr := runCLI(t, "", &whoistest.FakeServers{}, "example.com")
+	if ec != 2 || len(stdout) != 0 || !strings.HasPrefix(stderr, "Error: ") {
+		t.Errorf("run = %d, %q, %q", ec, stdout, stderr)
+	}
+}
+
+func TestRunClock(t *testing.T) {
+	now := qwis.Now
+	t.Cleanup(func() { qwis.Now = now })
+	qwis.Now = func() time.Time { return time.Date(2026, 8, 3, 4, 0, 0, 0, time.UTC) }
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	ec, stdout, stderr := runCLI(t, "", fs, "-j", "example.com")
+	if ec != 0 || !strings.Contains(stdout, `"days_until_expiry": 10`) {
+		t.Errorf("run = %d, %q, %q; want 10 days until expiry", ec, stdout, stderr)
+	}
+}
+
+func TestRunInvalidDomainName(t *testing.T) {
+	ec, stdout, stderr := runCLI(t, "", &whoistest.FakeServers{}, "exa_mple.com")
+	if ec != 1 || len(stdout) != 0 || !strings.Contains(stderr, `invalid domain name "exa_mple.com"`) {
+		t.Errorf("run = %d, %q, %q", ec, stdout, stderr)
+	}
+}
+
+func TestRunNormalize(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	for _, q := range []string{"https://www.example.com/path?x=1", "user@example.com"} {
+		if ec, stdout, stderr := runCLI(t, "", fs, "-n", q); ec != 0 || stdout != "2026-08-13T04:00:00Z\n" {
+			t.Errorf("%s: run = %d, %q, %q", q, ec, stdout, stderr)
+		}
+	}
+	if ec, _, stderr := runCLI(t, "", fs, "-no-normalize", "user@example.com"); ec != 1 || !strings.Contains(stderr, "invalid domain name") {
+		t.Errorf("-no-normalize: run = %d, %q", ec, stderr)
+	}
+}
+
+func TestRunDeep(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	ec, stdout, stderr := runCLI(t, "", fs, "-deep", "example.com")
+	var inf qwis.Infrastructure
+	if ec != 0 || json.Unmarshal([]byte(stdout), &inf) != nil {
+		t.Fatalf("run = %d, %q, %q", ec, stdout, stderr)
+	}
+	if len(inf.NameServers) != 1 || inf.NameServers[0].Domain != "iana-servers.net" || inf.NameServers[0].Response == nil ||
+		inf.Registrar == nil || inf.Registrar.Name != "Example Registrar, Inc." {
+		t.Errorf("run wrote %s", stdout)
+	}
+	if ec, _, stderr = runCLI(t, "", fs, "-deep", "example.com", "example.net"); ec != 1 || !strings.Contains(stderr, "single lookups") {
+		t.Errorf("batch: run = %d, %q", ec, stderr)
+	}
+}
+
+// nsResolver serves the same NS records for every name and no addresses
+// or MX records.
+type nsResolver []*net.NS
+
+func (r nsResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func (r nsResolver) LookupNS(ctx context.Context, name string) ([]*net.NS, error) {
+	return r, nil
+}
+
+func (r nsResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func TestRunDNS(t *testing.T) {
+	old := qwis.Resolver
+	defer func() { qwis.Resolver = old }()
+	qwis.Resolver = nsResolver{{Host: "a.iana-servers.net."}, {Host: "ns.elsewhere.test."}}
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	ec, stdout, stderr := runCLI(t, "", fs, "-j", "-dns", "example.com")
+	var wir qwis.WhoisResponse
+	if ec != 0 || json.Unmarshal([]byte(stdout), &wir) != nil || wir.DNS == nil {
+		t.Fatalf("run = %d, %q, %q", ec, stdout, stderr)
+	}
+	if !reflect.DeepEqual(wir.DNS.NSOnlyInDNS, []string{"ns.elsewhere.test"}) || len(wir.DNS.NSOnlyInWhois) != 0 {
+		t.Errorf("run wrote %s", stdout)
+	}
+}
+
+func TestRunCert(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		if address == "example.com:443" {
+			var d net.Dialer
+			return d.DialContext(ctx, network, srv.Listener.Addr().String())
+		}
+		return fs.Dial(ctx, network, address)
+	}
+	ec, stdout, stderr := runDialing(t, "", dial, "-j", "-cert", "example.com")
+	var wir qwis.WhoisResponse
+	if ec != 0 || json.Unmarshal([]byte(stdout), &wir) != nil || wir.Certificate == nil {
+		t.Fatalf("run = %d, %q, %q", ec, stdout, stderr)
+	}
+	if wir.Certificate.Issuer != "O=Acme Co" || wir.Certificate.Verified || len(wir.Warnings) != 1 {
+		t.Errorf("run wrote %s", stdout)
+	}
+}
+
+func TestRunInvalidArguments(t *testing.T) {
+	if ec, _, stderr := runCLI(t, "", nil, "-bogus", "example.com"); ec != 1 || !strings.Contains(stderr, "Invalid set of arguments") {
+		t.Errorf("run = %d, %q", ec, stderr)
+	}
+}
+
+func TestRunReadsStdin(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	ec, stdout, stderr := runCLI(t, "example.com\n# comment\nexample.net\n", fs, "-n", "-f", "-")
+	if ec != 0 {
+		t.Fatalf("exit code %d, stderr %q", ec, stderr)
+	}
+	if n := strings.Count(stdout, "2026-08-13T04:00:00Z"); n != 2 {
+		t.Errorf("got %d expiration lines, want 2:\n%s", n, stdout)
+	}
+}
+
+func TestRunBatchExpiration(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	ec, stdout, stderr := runCLI(t, "example.com\nexample.org\nexample.net\n", fs, "-n", "-f", "-")
+	want := "example.com\t2026-08-13T04:00:00Z\nexample.org\t-\nexample.net\t2026-08-13T04:00:00Z\n"
+	if ec != 2 || stdout != want || !strings.Contains(stderr, "example.org: ") {
+		t.Errorf("run = %d, %q, %q; want 2, %q", ec, stdout, stderr, want)
+	}
+}
+
+func TestRunBatchAvailable(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{
+		"whois.verisign-grs.com:43": exampleCom,
+		"org.whois-servers.net:43":  "NOT FOUND\r\n",
+	}}
+	ec, stdout, stderr := runCLI(t, "example.com\nexample.org\n", fs, "-available", "-f", "-")
+	if want := "example.com\tregistered\nexample.org\tavailable\n"; ec != 8 || stdout != want {
+		t.Errorf("run = %d, %q, %q; want 8, %q", ec, stdout, stderr, want)
+	}
+}
+
+func TestRunResetsOverrides(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{
+		"whois.verisign-grs.com:43": exampleCom,
+		"whois.test:43":             "Domain Name: OTHER.COM\r\nRegistrar: Other\r\n",
+	}}
+	path := filepath.Join(t.TempDir(), "servers.txt")
+	if err := os.WriteFile(path, []byte("com whois.test\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, stdout, _ := runCLI(t, "", fs, "-servers-file", path, "example.com"); !strings.Contains(stdout, "OTHER.COM") {
+		t.Fatalf("override not applied:\n%s", stdout)
+	}
+	if _, stdout, _ := runCLI(t, "", fs, "example.com"); !strings.Contains(stdout, "EXAMPLE.COM") {
+		t.Errorf("override leaked into the next run:\n%s", stdout)
+	}
+}
+
+func TestRunServerQuirks(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{
+		"rwhois.test:43": "example.com:Domain Name: EXAMPLE.COM\r\nexample.com:Registrar: Example Registrar, Inc.\r\n",
+	}}
+	dir := t.TempDir()
+	servers, quirks := filepath.Join(dir, "servers.txt"), filepath.Join(dir, "server-quirks.txt")
+	if err := os.WriteFile(servers, []byte("com rwhois.test\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(quirks, []byte("rwhois.test object-prefix\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ec, stdout, stderr := runCLI(t, "", fs, "-servers-file", servers, "-server-quirks", quirks, "example.com")
+	if ec != 0 || !strings.Contains(stdout, `"registrar": "Example Registrar, Inc."`) {
+		t.Errorf("run = %d, %q, %q", ec, stdout, stderr)
+	}
+	if _, stdout, _ = runCLI(t, "", fs, "-servers-file", servers, "example.com"); strings.Contains(stdout, `"registrar": "Example Registrar, Inc."`) {
+		t.Errorf("quirks leaked into the next run:\n%s", stdout)
+	}
+	if err := os.WriteFile(quirks, []byte("rwhois.test bogus\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if ec, _, stderr = runCLI(t, "", fs, "-server-quirks", quirks, "example.com"); ec != 1 || !strings.Contains(stderr, `unknown quirk "bogus"`) {
+		t.Errorf("unknown quirk: run = %d, %q", ec, stderr)
+	}
+}
+
+func TestRunHexDump(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	ec, stdout, stderr := runCLI(t, "", fs, "-hex-dump", "example.com")
+	if ec != 0 || !strings.Contains(stdout, `"domain_name": "EXAMPLE.COM"`) {
+		t.Fatalf("run = %d, %q", ec, stdout)
+	}
+	for _, want := range []string{
+		"00000000  44 6f 6d 61 69 6e 20 4e  61 6d 65 3a 20 45 58 41  |Domain Name: EXA|",
+		"00000010  4d 50 4c 45 2e 43 4f 4d  0d 0a 52 65 67 69 73 74  |MPLE.COM..Regist|",
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("hex dump lacks %q:\n%s", want, stderr)
+		}
+	}
+}
+
+func TestRunLocalAddr(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	if ec, _, stderr := runCLI(t, "", fs, "-local-addr", "192.0.2.10", "example.com"); ec != 0 {
+		t.Fatalf("exit code %d, stderr %q", ec, stderr)
+	}
+	la, ok := qwis.Dialer.LocalAddr.(*net.TCPAddr)
+	if !ok || !la.IP.Equal(net.ParseIP("192.0.2.10")) {
+		t.Errorf("Dialer.LocalAddr = %v, want 192.0.2.10", qwis.Dialer.LocalAddr)
+	}
+	if ec, _, stderr := runCLI(t, "", fs, "-local-addr", "not-an-ip", "example.com"); ec != 1 || !strings.Contains(stderr, "Invalid local address") {
+		t.Errorf("run = %d, %q", ec, stderr)
+	}
+}
+
+func TestRunConfidence(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	if _, stdout, _ := runCLI(t, "", fs, "example.com"); strings.Contains(stdout, "field_sources") {
+		t.Errorf("field sources printed without -confidence:\n%s", stdout)
+	}
+	_, stdout, _ := runCLI(t, "", fs, "-confidence", "example.com")
+	if !strings.Contains(stdout, `"domain_name": "exact_key"`) || !strings.Contains(stdout, `"expiration_date": "substring_key"`) {
+		t.Errorf("unexpected field sources:\n%s", stdout)
+	}
+}
+
+func TestRunTemplateFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.tmpl")
+	tmpl := "# {{.DomainName}}\n{{range .NameServers}}- {{.}}\n{{end}}Expires {{.ExpirationDate}}\n"
+	if err := os.WriteFile(path, []byte(tmpl), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	ec, stdout, stderr := runCLI(t, "", fs, "-template-file", path, "example.com")
+	if ec != 0 {
+		t.Fatalf("exit code %d, stderr %q", ec, stderr)
+	}
+	if want := "# EXAMPLE.COM\n- a.iana-servers.net\nExpires 2026-08-13T04:00:00Z\n"; stdout != want {
+		t.Errorf("output = %q, want %q", stdout, want)
+	}
+	bad := filepath.Join(dir, "bad.tmpl")
+	if err := os.WriteFile(bad, []byte("{{.DomainName"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if ec, _, stderr := runCLI(t, "", &whoistest.FakeServers{}, "-template-file", bad, "example.com"); ec != 1 || !strings.Contains(stderr, "bad.tmpl") {
+		t.Errorf("run = %d, %q", ec, stderr)
+	}
+}
+
+func TestRunFormat(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	ec, stdout, stderr := runCLI(t, "example.com\nexample.net\n", fs, "-format", "{{.DomainName}} expires {{.ExpirationDate}}", "-f", "-")
+	if ec != 0 {
+		t.Fatalf("exit code %d, stderr %q", ec, stderr)
+	}
+	if want := "EXAMPLE.COM expires 2026-08-13T04:00:00Z\n"; stdout != want+want {
+		t.Errorf("output = %q, want two %q lines", stdout, want)
+	}
+	if ec, _, _ := runCLI(t, "", fs, "-format", "{{.DomainName", "example.com"); ec != 1 {
+		t.Errorf("bad template = %d, want 1", ec)
+	}
+}
+
+func TestRunDialTimeout(t *testing.T) {
+	slowAccept := func(ctx context.Context, network, address string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	start := time.Now()
+	ec, _, stderr := runDialing(t, "", slowAccept, "-server", "whois.test", "-dial-timeout", "100ms", "example.com")
+	if ec != 2 || time.Since(start) > 5*time.Second {
+		t.Errorf("run = %d after %s, stderr %q", ec, time.Since(start), stderr)
+	}
+}
+
+func TestRunReadTimeout(t *testing.T) {
+	slowRespond := func(ctx context.Context, network, address string) (net.Conn, error) {
+		c, s := net.Pipe()
+		go func() {
+			bufio.NewReader(s).ReadString('\n')
+			time.Sleep(5 * time.Second)
+			s.Close()
+		}()
+		return c, nil
+	}
+	start := time.Now()
+	ec, _, stderr := runDialing(t, "", slowRespond, "-server", "whois.test", "-read-timeout", "100ms", "example.com")
+	if ec != 2 || time.Since(start) > 3*time.Second || !strings.Contains(stderr, "timeout") {
+		t.Errorf("run = %d after %s, stderr %q", ec, time.Since(start), stderr)
+	}
+}
+
+func TestRunMaxResponseSize(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	ec, _, stderr := runCLI(t, "", fs, "-no-cache", "-max-response-size", "100", "example.com")
+	if ec != 9 || !strings.Contains(stderr, "response too large: over 100 bytes") {
+		t.Errorf("run = %d, stderr %q", ec, stderr)
+	}
+	_, stdout, _ := runCLI(t, "", nil, "-print-config")
+	if !strings.Contains(stdout, `"read_timeout": "30s"`) || !strings.Contains(stdout, `"max_response_size": 4194304`) {
+		t.Errorf("defaults not in the configuration:\n%s", stdout)
+	}
+	if ec, _, _ := runCLI(t, "", nil, "-max-response-size", "-1", "example.com"); ec != 1 {
+		t.Errorf("negative size = %d, want 1", ec)
+	}
+}
+
+func TestRunTimeoutShorthand(t *testing.T) {
+	_, stdout, _ := runCLI(t, "", nil, "-t", "7s", "-print-config")
+	if !strings.Contains(stdout, `"dial_timeout": "7s"`) || !strings.Contains(stdout, `"read_timeout": "7s"`) {
+		t.Errorf("-t did not set both timeouts:\n%s", stdout)
+	}
+}
+
+func TestRunPrintConfig(t *testing.T) {
+	ec, stdout, stderr := runCLI(t, "", &whoistest.FakeServers{}, "-print-config", "-n", "-c", "3", "-retries", "2",
+		"-server", "whois.test:4343", "-no-cache", "-multi-domain", "error", "example.com")
+	if ec != 0 {
+		t.Fatalf("exit code %d, stderr %q", ec, stderr)
+	}
+	var c effectiveConfig
+	if err := json.Unmarshal([]byte(stdout), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Format != "expiration" || c.Concurrency != 3 || c.Retries != 2 || c.Server != "whois.test:4343" ||
+		c.MultiDomain != "error" || len(c.CacheDir) != 0 {
+		t.Errorf("config does not reflect the flags: %+v", c)
+	}
+}
+
+func TestRunMaxAge(t *testing.T) {
+	recent := time.Now().AddDate(0, 0, -2).UTC().Format(time.RFC3339)
+	fs := &whoistest.FakeServers{Responses: map[string]string{
+		"whois.verisign-grs.com:43": "Domain Name: FRESH.COM\r\nRegistrar: R\r\nUpdated Date: " + recent + "\r\n",
+		"whois.nic.org.test:43":     "Domain Name: STALE.ORG\r\nRegistrar: R\r\nUpdated Date: 2001-01-01T00:00:00Z\r\n",
+	}}
+	if ec, _, stderr := runCLI(t, "", fs, "-max-age", "30", "fresh.com"); ec != 0 || len(stderr) != 0 {
+		t.Errorf("fresh record: run = %d, %q", ec, stderr)
+	}
+	ec, _, stderr := runCLI(t, "", fs, "-max-age", "30", "-server", "whois.nic.org.test", "stale.org")
+	if ec != 10 || !strings.Contains(stderr, "Warning: stale.org: record not updated for") {
+		t.Errorf("stale record: run = %d, %q", ec, stderr)
+	}
+}
+
+func TestRunExpiringWithin(t *testing.T) {
+	expiry := func(days int) string {
+		return time.Now().AddDate(0, 0, days).UTC().Format(time.RFC3339)
+	}
+	fs := &whoistest.FakeServers{Responses: map[string]string{
+		"whois.verisign-grs.com:43": "Domain Name: SOON.COM\r\nRegistrar: R\r\nRegistry Expiry Date: " + expiry(10) + "\r\n",
+		"org.whois-servers.net:43":  "Domain Name: LATER.ORG\r\nRegistrar: R\r\nRegistry Expiry Date: " + expiry(90) + "\r\n",
+	}}
+	ec, stdout, stderr := runCLI(t, "soon.com\nlater.org\nexample.info\n", fs, "-j", "-expiring-within", "30", "-f", "-")
+	if ec != 2 || !strings.Contains(stdout, `"days_until_expiry": 9`) || strings.Contains(stdout, "later.org") || !strings.Contains(stdout, "example.info") {
+		t.Errorf("run = %d, %q, %q", ec, stdout, stderr)
+	}
+	ec, stdout, _ = runCLI(t, "soon.com\nlater.org\n", fs, "-ndjson", "-expiring-within", "30", "-f", "-")
+	if ec != 0 || strings.Count(stdout, "\n") != 1 || !strings.Contains(stdout, "soon.com") {
+		t.Errorf("streamed: run = %d, %q", ec, stdout)
+	}
+	if ec, _, stderr = runCLI(t, "", fs, "-expiring-within", "30", "soon.com"); ec != 1 || !strings.Contains(stderr, "batch") {
+		t.Errorf("single lookup: run = %d, %q", ec, stderr)
+	}
+	if ec, _, _ = runCLI(t, "", fs, "-expiring-within", "0", "-f", "-"); ec != 1 {
+		t.Errorf("zero days: run = %d", ec)
+	}
+}
+
+func TestRunEmitEmptyArray(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{
+		"whois.verisign-grs.com:43": "Domain Name: LATER.COM\r\nRegistrar: R\r\nRegistry Expiry Date: 2999-01-01T00:00:00Z\r\n",
+	}}
+	for _, args := range [][]string{
+		{"-ndjson", "-expiring-within", "30", "-f", "-"},
+		{"-ndjson", "-expiring-within", "30", "-sort-by", "domain", "-f", "-"},
+	} {
+		if ec, stdout, _ := runCLI(t, "later.com\n", fs, args...); ec != 0 || len(stdout) != 0 {
+			t.Errorf("%v: run = %d, %q, want no output", args, ec, stdout)
+		}
+		args = append([]string{"-emit-empty-array"}, args...)
+		if ec, stdout, _ := runCLI(t, "later.com\n", fs, args...); ec != 0 || stdout != "[]\n" {
+			t.Errorf("%v: run = %d, %q", args, ec, stdout)
+		}
+	}
+}
+
+func TestRunRDAPTLDs(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/dns.json":
+			fmt.Fprintf(w, `{"services":[[["dev","com"],["%s/"]]]}`, srv.URL)
+		case "/domain/example.dev", "/domain/example.com":
+			fmt.Fprintf(w, `{"ldhName":"%s","status":["active"]}`, strings.ToUpper(path.Base(r.URL.Path)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	bootstrapURL := qwis.RDAPBootstrapURL
+	qwis.RDAPBootstrapURL = srv.URL + "/dns.json"
+	defer func() { qwis.RDAPBootstrapURL = bootstrapURL }()
+	fs := &whoistest.FakeServers{Responses: map[string]string{
+		"whois.nic.google:43":       "Domain Name: example.dev\r\nRegistrar: Port 43\r\n",
+		"whois.verisign-grs.com:43": exampleCom,
+	}}
+	for _, c := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"example.dev"}, `"domain_name": "EXAMPLE.DEV"`},
+		{[]string{"-rdap-tlds", "", "example.dev"}, `"registrar": "Port 43"`},
+		{[]string{"-rdap-tlds", "com", "example.com"}, `"ok"`},
+	} {
+		ec, stdout, stderr := runCLI(t, "", fs, c.args...)
+		if ec != 0 || !strings.Contains(stdout, c.want) {
+			t.Errorf("run(%q) = %d, %q, %q; want %s", c.args, ec, stdout, stderr, c.want)
+		}
+	}
+}
+
+func TestRunFieldMap(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	for _, args := range [][]string{
+		{"-field-map", "domain_name=domain", "example.com"},
+		{"-field-map", "domain_name=domain", "-j", "example.com"},
+		{"-j", "-field-map", "domain_name=domain", "example.com"},
+		{"-field-map", "domain_name=domain", "-r", "-j", "example.com"},
+		{"-field-map", "domain_name=domain", "-j", "-f", "-"},
+		{"-field-map", "domain_name=domain", "-r", "-j", "-f", "-"},
+	} {
+		ec, stdout, stderr := runCLI(t, "example.com\n", fs, args...)
+		if ec != 0 || !strings.Contains(stdout, `"domain": "EXAMPLE.COM"`) || strings.Contains(stdout, `"domain_name"`) {
+			t.Errorf("run(%q) = %d, %q, %q", args, ec, stdout, stderr)
+		}
+	}
+	for _, args := range [][]string{
+		{"-field-map", "registrar=domain_name", "example.com"},
+		{"-field-map", "registrar=x,registrar=y", "example.com"},
+		{"-field-map", "domain_name=domain", "-raw", "example.com"},
+	} {
+		if ec, _, _ := runCLI(t, "", fs, args...); ec != 1 {
+			t.Errorf("run(%q) = %d, want 1", args, ec)
+		}
+	}
+}
+
+func TestRunICS(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{
+		"whois.verisign-grs.com:43": exampleCom,
+		"org.whois-servers.net:43":  "Domain Name: EXAMPLE.ORG\r\nRegistrar: Example Registrar, Inc.\r\n",
+	}}
+	ec, stdout, stderr := runCLI(t, "example.com\nexample.org\n", fs, "-ics", "-f", "-")
+	if ec != 0 {
+		t.Fatalf("exit code %d, stderr %q", ec, stderr)
+	}
+	if strings.Count(stdout, "BEGIN:VCALENDAR") != 1 || strings.Count(stdout, "BEGIN:VEVENT") != 1 ||
+		!strings.Contains(stdout, "DTSTART;VALUE=DATE:20260813\r\n") || !strings.Contains(stdout, "SUMMARY:example.com expires") {
+		t.Errorf("unexpected calendar:\n%s", stdout)
+	}
+	if !strings.Contains(stderr, "example.org: no parseable expiration date") {
+		t.Errorf("no warning for example.org: %q", stderr)
+	}
+}
+
+func TestRunSortBy(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{
+		"whois.verisign-grs.com:43": exampleCom,
+		"org.whois-servers.net:43":  "Domain Name: EXAMPLE.ORG\r\nRegistrar: A Registrar\r\nRegistry Expiry Date: 2025-01-01T00:00:00Z\r\n",
+		"whois.nic.io:43":           "Domain Name: EXAMPLE.IO\r\nRegistrar: Z Registrar\r\n",
+	}}
+	for by, want := range map[string]string{
+		"expiration": "example.org\t2025-01-01T00:00:00Z\nexample.com\t2026-08-13T04:00:00Z\nexample.io\t-\n",
+		"domain":     "example.com\t2026-08-13T04:00:00Z\nexample.io\t-\nexample.org\t2025-01-01T00:00:00Z\n",
+		"registrar":  "example.org\t2025-01-01T00:00:00Z\nexample.com\t2026-08-13T04:00:00Z\nexample.io\t-\n",
+	} {
+		ec, stdout, stderr := runCLI(t, "example.io\nexample.com\nexample.org\n", fs, "-n", "-sort-by", by, "-f", "-")
+		if ec != 0 || stdout != want {
+			t.Errorf("-sort-by %s = %d, %q, %q; want %q", by, ec, stdout, stderr, want)
+		}
+	}
+	if ec, _, _ := runCLI(t, "", fs, "-sort-by", "size", "example.com"); ec != 1 {
+		t.Errorf("-sort-by size = %d, want 1", ec)
+	}
+}
+
+func TestRunParallelSources(t *testing.T) {
+	var rdapDelay time.Duration
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/dns.json" {
+			fmt.Fprintf(w, `{"services":[[["com"],["%s/"]]]}`, srv.URL)
+			return
+		}
+		select {
+		case <-time.After(rdapDelay):
+			fmt.Fprint(w, `{"ldhName":"EXAMPLE.COM","status":["active"]}`)
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	bootstrapURL := qwis.RDAPBootstrapURL
+	qwis.RDAPBootstrapURL = srv.URL + "/dns.json"
+	defer func() { qwis.RDAPBootstrapURL = bootstrapURL }()
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	slowWhois := func(ctx context.Context, network, address string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	for _, c := range []struct {
+		dial      qwis.DialFunc
+		rdapDelay time.Duration
+		want      string
+	}{
+		{fs.Dial, 5 * time.Second, `"source": "whois"`},
+		{slowWhois, 0, `"source": "rdap"`},
+	} {
+		rdapDelay = c.rdapDelay
+		for _, flag := range []string{"-fastest", "-parallel-sources"} {
+			ec, stdout, stderr := runDialing(t, "", c.dial, flag, "-timeout", "10s", "example.com")
+			if ec != 0 || !strings.Contains(stdout, c.want) {
+				t.Errorf("%s: run = %d, %q, %q; want %s", flag, ec, stdout, stderr, c.want)
+			}
+		}
+	}
+	// The losing lookup is cancelled rather than waited for.
+	rdapDelay = 5 * time.Second
+	start := time.Now()
+	if ec, _, _ := runCLI(t, "", fs, "-fastest", "example.com"); ec != 0 || time.Since(start) > 3*time.Second {
+		t.Errorf("-fastest = %d after %s", ec, time.Since(start))
+	}
+	if ec, _, _ := runCLI(t, "", fs, "-fastest", "-rdap", "example.com"); ec != 1 {
+		t.Errorf("-fastest -rdap = %d, want 1", ec)
+	}
+}
+
+func TestRunCAFile(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/dns.json" {
+			fmt.Fprintf(w, `{"services":[[["com"],["%s/"]]]}`, srv.URL)
+			return
+		}
+		fmt.Fprint(w, `{"ldhName":"EXAMPLE.COM","status":["active"]}`)
+	}))
+	defer srv.Close()
+	bootstrapURL := qwis.RDAPBootstrapURL
+	qwis.RDAPBootstrapURL = srv.URL + "/dns.json"
+	defer func() { qwis.RDAPBootstrapURL = bootstrapURL }()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if ec, _, stderr := runCLI(t, "", nil, "-rdap", "example.com"); ec == 0 || !strings.Contains(stderr, "certificate") {
+		t.Errorf("unverified server accepted: %d, %q", ec, stderr)
+	}
+	ec, stdout, stderr := runCLI(t, "", nil, "-rdap", "-cafile", caFile, "example.com")
+	if ec != 0 || !strings.Contains(stdout, `"domain_name": "EXAMPLE.COM"`) {
+		t.Errorf("run = %d, %q, %q", ec, stdout, stderr)
+	}
+	if ec, _, _ = runCLI(t, "", nil, "-cafile", filepath.Join(t.TempDir(), "missing.pem"), "example.com"); ec != 1 {
+		t.Errorf("missing -cafile = %d, want 1", ec)
+	}
+}
+
+func TestRunBatchIPAndASN(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{
+		"whois.iana.org:43":         "inetnum: 192.0.2.0 - 192.0.2.255\nnetname: TEST-NET-1\naut-num: AS64496\nas-name: DOC-AS\n",
+		"whois.verisign-grs.com:43": exampleCom,
+	}}
+	ec, stdout, stderr := runCLI(t, "192.0.2.1\nAS64496\nexample.com\n", fs, "-j", "-f", "-")
+	if ec != 0 {
+		t.Fatalf("exit code %d, stderr %q", ec, stderr)
+	}
+	for _, want := range []string{`"net_name": "TEST-NET-1"`, `"as_name": "DOC-AS"`, `"registrar": "Example Registrar, Inc."`} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("missing %s in:\n%s", want, stdout)
+		}
+	}
+	ec, stdout, stderr = runCLI(t, "192.0.2.1\nexample.com\n", fs, "-n", "-f", "-")
+	if want := "192.0.2.1\t-\nexample.com\t2026-08-13T04:00:00Z\n"; ec != 0 || stdout != want {
+		t.Errorf("-n = %d, %q, %q; want %q", ec, stdout, stderr, want)
+	}
+}
+
+func TestRunRawWithCache(t *testing.T) {
+	registry := exampleCom + "Registrar WHOIS Server: whois.example-registrar.test\r\n"
+	fs := &whoistest.FakeServers{Responses: map[string]string{
+		"whois.verisign-grs.com:43":       registry,
+		"whois.example-registrar.test:43": "Domain Name: EXAMPLE.COM\r\nRegistrant Organization: Example Inc.\r\n",
+	}}
+	for _, args := range [][]string{{"-r", "example.com"}, {"-r", "-no-cache", "example.com"}} {
+		ec, stdout, stderr := runCLI(t, "", fs, args...)
+		if ec != 0 || stdout != registry {
+			t.Errorf("run(%q) = %d, %q, %q; want the registry answer only", args, ec, stdout, stderr)
+		}
+	}
+}
+
+func TestRunTable(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom +
+		"Domain Status: clientDeleteProhibited https://icann.org/epp#clientDeleteProhibited\r\n"}}
+	ec, stdout, stderr := runCLI(t, "example.com\nexample.org\n", fs, "-output", "csv", "-f", "-")
+	want := "domain,domain_name,registrar,creation_date,expiration_date,updated_date,statuses,name_servers,error\n" +
+		"example.com,EXAMPLE.COM,\"Example Registrar, Inc.\",1995-08-14T04:00:00Z,2026-08-13T04:00:00Z,," +
+		"clientTransferProhibited;clientDeleteProhibited,a.iana-servers.net,\n" +
+		"example.org,,,,,,,,"
+	if ec != 2 || !strings.HasPrefix(stdout, want) {
+		t.Errorf("run = %d, %q, %q; want 2, %q...", ec, stdout, stderr, want)
+	}
+	ec, stdout, stderr = runCLI(t, "", fs, "-output", "tsv", "-list-sep", ",", "example.com")
+	want = "example.com\tEXAMPLE.COM\tExample Registrar, Inc.\t1995-08-14T04:00:00Z\t2026-08-13T04:00:00Z\t\t" +
+		"clientTransferProhibited,clientDeleteProhibited\ta.iana-servers.net\t\n"
+	if lines := strings.SplitAfter(stdout, "\n"); ec != 0 || len(lines) != 3 || lines[1] != want {
+		t.Errorf("run = %d, %q, %q; want 0, %q", ec, stdout, stderr, want)
+	}
+}
+
+func TestRunOutputEncoders(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	ec, stdout, stderr := runCLI(t, "", fs, "-output", "yaml", "example.com")
+	if ec != 0 || !strings.HasPrefix(stdout, "---\ndomain_name: EXAMPLE.COM\n") {
+		t.Errorf("yaml = %d, %q, %q", ec, stdout, stderr)
+	}
+	ec, stdout, stderr = runCLI(t, "", fs, "-output", "xml", "example.com")
+	if ec != 0 || !strings.Contains(stdout, "<whois_response>\n  <domain_name>EXAMPLE.COM</domain_name>\n") {
+		t.Errorf("xml = %d, %q, %q", ec, stdout, stderr)
+	}
+	if ec, _, _ = runCLI(t, "", fs, "-output", "toml", "example.com"); ec != 1 {
+		t.Errorf("-output toml = %d, want 1", ec)
+	}
+}
+
+func TestRunFields(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	ec, stdout, stderr := runCLI(t, "", fs, "-fields", "domain_name,expiration_date", "example.com")
+	if want := "{\n    \"domain_name\": \"EXAMPLE.COM\",\n    \"expiration_date\": \"2026-08-13T04:00:00Z\"\n}"; ec != 0 || stdout != want {
+		t.Errorf("json = %d, %q, %q; want %q", ec, stdout, stderr, want)
+	}
+	ec, stdout, stderr = runCLI(t, "example.com\nexample.net\n", fs, "-output", "csv", "-fields", "expiration_date", "-f", "-")
+	if want := "domain,expiration_date,error\nexample.com,2026-08-13T04:00:00Z,\nexample.net,2026-08-13T04:00:00Z,\n"; ec != 0 || stdout != want {
+		t.Errorf("csv = %d, %q, %q; want %q", ec, stdout, stderr, want)
+	}
+	for _, args := range [][]string{{"-fields", "expiry", "example.com"}, {"-fields", "registrar", "-n", "example.com"}} {
+		if ec, _, _ := runCLI(t, "", fs, args...); ec != 1 {
+			t.Errorf("run(%q) = %d, want 1", args, ec)
+		}
+	}
+}
+
+func TestRunInsecure(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/dns.json" {
+			fmt.Fprintf(w, `{"services":[[["com"],["%s/"]]]}`, srv.URL)
+			return
+		}
+		fmt.Fprint(w, `{"ldhName":"EXAMPLE.COM","status":["active"]}`)
+	}))
+	defer srv.Close()
+	bootstrapURL := qwis.RDAPBootstrapURL
+	qwis.RDAPBootstrapURL = srv.URL + "/dns.json"
+	defer func() { qwis.RDAPBootstrapURL = bootstrapURL }()
+	ec, stdout, stderr := runCLI(t, "", nil, "-rdap", "-insecure", "example.com")
+	if ec != 0 || !strings.Contains(stdout, `"domain_name": "EXAMPLE.COM"`) {
+		t.Errorf("run = %d, %q, %q", ec, stdout, stderr)
+	}
+	if ec, _, _ = runCLI(t, "", nil, "-rdap", "example.com"); ec == 0 {
+		t.Error("-insecure leaked into the next run")
+	}
+}
+
+func TestRunInterface(t *testing.T) {
+	ifs, err := net.Interfaces()
+	if err != nil {
+		t.Skip(err)
+	}
+	var lo string
+	for _, ifi := range ifs {
+		if ifi.Flags&net.FlagLoopback != 0 {
+			lo = ifi.Name
+		}
+	}
+	if len(lo) == 0 {
+		t.Skip("no loopback interface")
+	}
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	if ec, _, stderr := runCLI(t, "", fs, "-interface", lo, "example.com"); ec != 0 {
+		t.Fatalf("exit code %d, stderr %q", ec, stderr)
+	}
+	if la, ok := qwis.Dialer.LocalAddr.(*net.TCPAddr); !ok || !la.IP.IsLoopback() {
+		t.Errorf("Dialer.LocalAddr = %v, want a loopback address", qwis.Dialer.LocalAddr)
+	}
+	if ec, _, _ := runCLI(t, "", fs, "-interface", "no-such-interface0", "example.com"); ec != 1 {
+		t.Errorf("unknown interface = %d, want 1", ec)
+	}
+	if ec, _, _ := runCLI(t, "", fs, "-source-ip", "192.0.2.10", "example.com"); ec != 0 {
+		t.Errorf("-source-ip = %d", ec)
+	}
+}
+
+func TestRunAddressFamily(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	for _, tc := range []struct{ arg, network string }{{"-4", "tcp4"}, {"-6", "tcp6"}} {
+		if ec, _, stderr := runCLI(t, "", fs, tc.arg, "example.com"); ec != 0 {
+			t.Fatalf("%s: exit code %d, stderr %q", tc.arg, ec, stderr)
+		}
+		if qwis.Network != tc.network {
+			t.Errorf("%s: Network = %q, want %q", tc.arg, qwis.Network, tc.network)
+		}
+	}
+	if runCLI(t, "", fs, "-4", "-6", "example.com"); qwis.Network != "tcp6" {
+		t.Errorf("-4 -6: Network = %q, want the last one", qwis.Network)
+	}
+	_, stdout, _ := runCLI(t, "", fs, "-6", "-fallback-delay", "50ms", "-print-config")
+	if !strings.Contains(stdout, `"network": "tcp6"`) || !strings.Contains(stdout, `"fallback_delay": "50ms"`) {
+		t.Errorf("unexpected config:\n%s", stdout)
+	}
+}
+
+func TestRunConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	configs := map[string]string{
+		"config.toml": "# defaults\ntimeout = \"7s\"\noutput = 'yaml'\nno-cache = true\nlist-sep = \"#\"\n\n[servers]\ncom = \"whois.example.net\"\n",
+		"config.yaml": "---\ntimeout: 7s  # defaults\noutput: yaml\nno-cache: true\nlist-sep: \"#\"\nservers:\n  com: whois.example.net\n",
+	}
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.example.net:43": exampleCom}}
+	for name, body := range configs {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		ec, stdout, stderr := runCLI(t, "", fs, "config", "show", "-config", p)
+		if ec != 0 || !strings.Contains(stdout, `"config_file": "`+p+`"`) || !strings.Contains(stdout, `"timeout": "7s"`) || !strings.Contains(stdout, `"format": "yaml"`) {
+			t.Errorf("%s: config show = %d, %q\n%s", name, ec, stderr, stdout)
+		}
+		if _, stdout, _ = runCLI(t, "", fs, "config", "show", "-config", p, "-timeout", "3s", "-output", "json"); !strings.Contains(stdout, `"timeout": "3s"`) || !strings.Contains(stdout, `"format": "json"`) {
+			t.Errorf("%s: flags do not override the config file:\n%s", name, stdout)
+		}
+		if ec, stdout, stderr = runCLI(t, "", fs, "-config", p, "example.com"); ec != 0 || !strings.HasPrefix(stdout, "---\n") {
+			t.Errorf("%s: lookup = %d, %q\n%s", name, ec, stderr, stdout)
+		}
+	}
+	bad := filepath.Join(dir, "bad.toml")
+	os.WriteFile(bad, []byte("timeout = \"7s\"\ncolour = \"red\"\n"), 0o644)
+	if ec, _, stderr := runCLI(t, "", fs, "-config", bad, "example.com"); ec != 1 || !strings.Contains(stderr, "bad.toml:2: unknown key colour") {
+		t.Errorf("bad config = %d, %q", ec, stderr)
+	}
+	if ec, _, _ := runCLI(t, "", fs, "config", "edit"); ec != 1 {
+		t.Errorf("config edit = %d, want 1", ec)
+	}
+}
+
+func TestFindConfig(t *testing.T) {
+	dir := t.TempDir()
+	userConfigDir = func() (string, error) { return dir, nil }
+	defer func() { userConfigDir = os.UserConfigDir }()
+	if p := findConfig(); len(p) != 0 {
+		t.Errorf("findConfig() = %q with no config file", p)
+	}
+	os.MkdirAll(filepath.Join(dir, "qwis"), 0o755)
+	for _, n := range []string{"config.yml", "config.toml"} {
+		os.WriteFile(filepath.Join(dir, "qwis", n), nil, 0o644)
+		if p := findConfig(); p != filepath.Join(dir, "qwis", n) {
+			t.Errorf("findConfig() = %q, want %s", p, n)
+		}
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	for _, tc := range []struct {
+		args           []string
+		opts, operands string
+		err            bool
+	}{
+		{args: []string{"example.com", "-j", "-t", "5s", "example.org"}, opts: "-j -t 5s", operands: "example.com example.org"},
+		{args: []string{"--timeout=5s", "--output", "yaml", "-f", "-"}, opts: "-timeout 5s -output yaml -f -"},
+		{args: []string{"--help", "-"}, opts: "-h", operands: "-"},
+		{args: []string{"-j", "--", "-t", "x"}, opts: "-j", operands: "-t x"},
+		{args: []string{"-timeout"}, err: true},
+		{args: []string{"--j=true"}, err: true},
+	} {
+		opts, operands, err := splitArgs(tc.args, optionsWithValue)
+		if tc.err {
+			if err == nil {
+				t.Errorf("splitArgs(%q) succeeded", tc.args)
+			}
+			continue
+		}
+		if err != nil || strings.Join(opts, " ") != tc.opts || strings.Join(operands, " ") != tc.operands {
+			t.Errorf("splitArgs(%q) = %q, %q, %v", tc.args, opts, operands, err)
+		}
+	}
+}
+
+func TestRunOptionsAnywhere(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	for _, args := range [][]string{
+		{"example.com", "-output", "yaml"},
+		{"--output=yaml", "example.com"},
+		{"lookup", "example.com", "--output", "yaml"},
+	} {
+		if ec, stdout, stderr := runCLI(t, "", fs, args...); ec != 0 || !strings.HasPrefix(stdout, "---\n") {
+			t.Errorf("run(%q) = %d, %q\n%s", args, ec, stderr, stdout)
+		}
+	}
+	if ec, _, stderr := runCLI(t, "", fs, "example.com", "-timeout"); ec != 1 || !strings.Contains(stderr, "-timeout needs a value") {
+		t.Errorf("missing value = %d, %q", ec, stderr)
+	}
+}
+
+func TestRunCommandHelp(t *testing.T) {
+	for _, tc := range []struct {
+		args    []string
+		command string
+	}{
+		{[]string{"lookup", "-h"}, "qwis [lookup]"},
+		{[]string{"servers", "list", "--help"}, "servers list"},
+		{[]string{"config", "show", "-help"}, "config show"},
+		{[]string{"history", "-h"}, "history <domain-name>"},
+		{[]string{"diff", "example.com", "-h"}, "diff <domain-name>"},
+		{[]string{"serve", "--help"}, "qwis serve"},
+		{[]string{"watch", "-h"}, "qwis watch"},
+		{[]string{"report", "--help"}, "qwis report"},
+		{[]string{"completion", "-h"}, "qwis completion"},
+	} {
+		ec, stdout, _ := runCLI(t, "", nil, tc.args...)
+		if ec != 0 || strings.Count(stdout, "qwis ") != 1 || !strings.Contains(stdout, tc.command) {
+			t.Errorf("run(%q) = %d:\n%s", tc.args, ec, stdout)
+		}
+	}
+	if _, stdout, _ := runCLI(t, "", nil, "-h"); strings.Count(stdout, "         qwis ") != 8 {
+		t.Errorf("-h does not list every command:\n%s", stdout)
+	}
+}
+
+func TestLookupExitCode(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("Whois: %w: example.test", qwis.ErrInvalidDomainName), 1},
+		{&qwis.ServerError{Server: "whois.test:43", Err: qwis.ErrServerUnavailable}, 2},
+		{context.DeadlineExceeded, 2},
+		{&net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}, 2},
+		{&net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, 2},
+		{fmt.Errorf("Whois: %w: example.test", qwis.ErrNoSuchDomain), 3},
+		{fmt.Errorf("Whois: %w", &qwis.ServerError{Server: "whois.test:43", Err: qwis.ErrRateLimited}), 4},
+		{fmt.Errorf("ParseResponse: %w", qwis.ErrParse), 5},
+		{qwis.ErrNoWhoisServer, 6},
+		{errors.New("history: database is locked"), 9},
+	} {
+		if got := lookupExitCode(tc.err); got != tc.want {
+			t.Errorf("lookupExitCode(%v) = %d, want %d", tc.err, got, tc.want)
+		}
+	}
+	if _, stdout, _ := runCLI(t, "", nil, "-h"); !strings.Contains(stdout, "Exit status:") || !strings.Contains(stdout, "11 alerts raised") {
+		t.Errorf("help does not document the exit codes:\n%s", stdout)
+	}
+}
+
+func TestRunNDJSONStream(t *testing.T) {
+	configDir, cacheDir := t.TempDir(), t.TempDir()
+	userConfigDir = func() (string, error) { return configDir, nil }
+	userCacheDir = func() (string, error) { return cacheDir, nil }
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	var stderr bytes.Buffer
+	done := make(chan int)
+	go func() {
+		ec := run([]string{"-ndjson", "-f", "-"}, inR, outW, &stderr, fs.Dial)
+		outW.Close()
+		done <- ec
+	}()
+	lines := bufio.NewScanner(outR)
+	for _, dn := range []string{"example.com", "# comment", "example.com"} {
+		io.WriteString(inW, dn+"\n")
+		if dn[0] == '#' {
+			continue
+		}
+		// The line is out while stdin is still open.
+		if !lines.Scan() {
+			t.Fatalf("no line for %s: %v", dn, lines.Err())
+		}
+		var e struct {
+			Domain   string                 `json:"domain"`
+			Response map[string]interface{} `json:"response"`
+		}
+		if err := json.Unmarshal(lines.Bytes(), &e); err != nil || e.Domain != dn || e.Response["domain_name"] != "EXAMPLE.COM" {
+			t.Errorf("line %q, %v", lines.Text(), err)
+		}
+	}
+	inW.Close()
+	if lines.Scan() {
+		t.Errorf("unexpected line %q", lines.Text())
+	}
+	if ec := <-done; ec != 0 {
+		t.Errorf("exit code %d, stderr %q", ec, stderr.String())
+	}
+}
+
+func TestRunQPS(t *testing.T) {
+	_, stdout, _ := runCLI(t, "", nil, "-qps", "5", "-qps-per-server", "0.5", "-print-config")
+	if !strings.Contains(stdout, `"qps": 5`) || !strings.Contains(stdout, `"qps_per_server": 0.5`) {
+		t.Errorf("unexpected config:\n%s", stdout)
+	}
+	for _, v := range []string{"-1", "fast", "NaN"} {
+		if ec, _, stderr := runCLI(t, "", nil, "-qps", v, "example.com"); ec != 1 || !strings.Contains(stderr, "Invalid rate") {
+			t.Errorf("-qps %s = %d, %q", v, ec, stderr)
+		}
+	}
+	if runCLI(t, "", nil, "-print-config"); qwis.RateLimit != (qwis.RateLimitPolicy{}) {
+		t.Errorf("RateLimit not reset: %+v", qwis.RateLimit)
+	}
+}
+
+func TestRunVerbose(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	_, plain, _ := runCLI(t, "", fs, "example.com")
+	for _, arg := range []string{"-v", "-verbose", "-debug"} {
+		ec, stdout, stderr := runCLI(t, "", fs, arg, "example.com")
+		if ec != 0 || stdout != plain {
+			t.Errorf("%s: exit code %d, stdout differs:\n%s", arg, ec, stdout)
+		}
+		if !strings.Contains(stderr, "msg=query server=whois.verisign-grs.com:43") {
+			t.Errorf("%s: stderr lacks the query:\n%s", arg, stderr)
+		}
+		if debug := strings.Contains(stderr, `msg="server selected"`); debug != (arg == "-debug") {
+			t.Errorf("%s: debug records = %t:\n%s", arg, debug, stderr)
+		}
+	}
+	if _, _, stderr := runCLI(t, "", fs, "example.com"); strings.Contains(stderr, "msg=") {
+		t.Errorf("logged without -verbose:\n%s", stderr)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkorotkov/qwis"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the upstream latency
+// histogram.
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// errorClasses name the exit codes of lookupExitCode in metric labels.
+var errorClasses = map[int]string{
+	1: "invalid_domain",
+	2: "unavailable",
+	3: "no_such_domain",
+	4: "rate_limited",
+	5: "parse",
+	6: "unsupported_tld",
+	9: "other",
+}
+
+// metrics are what serve exposes at /metrics in the Prometheus text format.
+// Lookups and their errors are counted by the HTTP and gRPC handlers;
+// upstream queries, cache hits and referrals by the library's hooks.
+type metrics struct {
+	sync.Mutex
+	tlds      map[string]bool
+	lookups   map[[2]string]uint64
+	errors    map[string]uint64
+	queries   uint64
+	cacheHits uint64
+	referrals uint64
+	latency   []uint64
+	latSum    float64
+	latCount  uint64
+}
+
+// newMetrics returns metrics whose tld labels are the TLDs of the whois
+// server table; lookups of others are counted under "other", so that made
+// up TLDs can't grow the label set without bound.
+func newMetrics() *metrics {
+	tlds := map[string]bool{}
+	for _, sm := range qwis.WhoisServers() {
+		tlds[qwis.TopLevelDomain(sm.TLD)] = true
+	}
+	return &metrics{
+		tlds:    tlds,
+		lookups: map[[2]string]uint64{},
+		errors:  map[string]uint64{},
+		latency: make([]uint64, len(latencyBuckets)),
+	}
+}
+
+// lookupTLD is the tld label of a lookup of q: its TLD, "other" for TLDs
+// outside m.tlds, or "ip" and "asn" for IP and AS whois.
+func (m *metrics) lookupTLD(q string) string {
+	switch {
+	case qwis.IsIPQuery(q):
+		return "ip"
+	case qwis.IsASNQuery(q):
+		return "asn"
+	}
+	if tld := strings.ToLower(qwis.TopLevelDomain(strings.TrimSuffix(q, "."))); m.tlds[tld] {
+		return tld
+	}
+	return "other"
+}
+
+func (m *metrics) observeLookup(endpoint, q string, err error) {
+	m.Lock()
+	defer m.Unlock()
+	m.lookups[[2]string{endpoint, m.lookupTLD(q)}]++
+	if err != nil {
+		m.errors[errorClasses[lookupExitCode(err)]]++
+	}
+}
+
+// hooks count the upstream queries, cache hits and referrals of lookups.
+func (m *metrics) hooks() qwis.Hooks {
+	return qwis.Hooks{
+		Query: func(_ string, elapsed time.Duration, _ error) { m.observeQuery(elapsed) },
+		CacheHit: func(string) {
+			m.Lock()
+			m.cacheHits++
+			m.Unlock()
+		},
+		Referral: func(string, string) {
+			m.Lock()
+			m.referrals++
+			m.Unlock()
+		},
+	}
+}
+
+func (m *metrics) observeQuery(elapsed time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	m.queries++
+	s := elapsed.Seconds()
+	for i, b := range latencyBuckets {
+		if s <= b {
+			m.latency[i]++
+		}
+	}
+	m.latSum += s
+	m.latCount++
+}
+
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.writeTo(w)
+}
+
+func (m *metrics) writeTo(w io.Writer) {
+	m.Lock()
+	defer m.Unlock()
+	lookups := make([][2]string, 0, len(m.lookups))
+	for k := range m.lookups {
+		lookups = append(lookups, k)
+	}
+	sort.Slice(lookups, func(i, j int) bool {
+		return lookups[i][0] < lookups[j][0] || lookups[i][0] == lookups[j][0] && lookups[i][1] < lookups[j][1]
+	})
+	fmt.Fprintln(w, "# HELP qwis_lookups_total Lookups served, by endpoint and TLD.")
+	fmt.Fprintln(w, "# TYPE qwis_lookups_total counter")
+	for _, k := range lookups {
+		fmt.Fprintf(w, "qwis_lookups_total{endpoint=%q,tld=%q} %d\n", k[0], k[1], m.lookups[k])
+	}
+	classes := make([]string, 0, len(m.errors))
+	for c := range m.errors {
+		classes = append(classes, c)
+	}
+	sort.Strings(classes)
+	fmt.Fprintln(w, "# HELP qwis_lookup_errors_total Failed lookups, by error class.")
+	fmt.Fprintln(w, "# TYPE qwis_lookup_errors_total counter")
+	for _, c := range classes {
+		fmt.Fprintf(w, "qwis_lookup_errors_total{class=%q} %d\n", c, m.errors[c])
+	}
+	fmt.Fprintln(w, "# HELP qwis_upstream_queries_total Queries sent to whois servers and RDAP hosts.")
+	fmt.Fprintln(w, "# TYPE qwis_upstream_queries_total counter")
+	fmt.Fprintf(w, "qwis_upstream_queries_total %d\n", m.queries)
+	fmt.Fprintln(w, "# HELP qwis_cache_hits_total Queries answered from the response cache.")
+	fmt.Fprintln(w, "# TYPE qwis_cache_hits_total counter")
+	fmt.Fprintf(w, "qwis_cache_hits_total %d\n", m.cacheHits)
+	fmt.Fprintln(w, "# HELP qwis_referrals_total Registrar referrals followed.")
+	fmt.Fprintln(w, "# TYPE qwis_referrals_total counter")
+	fmt.Fprintf(w, "qwis_referrals_total %d\n", m.referrals)
+	fmt.Fprintln(w, "# HELP qwis_upstream_duration_seconds Time taken by upstream queries.")
+	fmt.Fprintln(w, "# TYPE qwis_upstream_duration_seconds histogram")
+	for i, b := range latencyBuckets {
+		fmt.Fprintf(w, "qwis_upstream_duration_seconds_bucket{le=\"%g\"} %d\n", b, m.latency[i])
+	}
+	fmt.Fprintf(w, "qwis_upstream_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.latCount)
+	fmt.Fprintf(w, "qwis_upstream_duration_seconds_sum %g\n", m.latSum)
+	fmt.Fprintf(w, "qwis_upstream_duration_seconds_count %d\n", m.latCount)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// defaultAlertMessage is the text of Slack and email notifications unless
+// -message or -message-file give another.
+const defaultAlertMessage = `{{.Domain}}: {{if eq .Kind "expiring"}}expires on {{.ExpirationDate}}, in {{.DaysLeft}} days` +
+	`{{else}}changed{{range .Changes}}; {{.Field}}: {{.Old}} -> {{.New}}{{end}}{{end}}`
+
+// notifier delivers watch alerts: as the alert's JSON with the message
+// added to a generic webhook, as {"text": message} to a Slack-compatible
+// one and as an email through an SMTP relay, authenticating with
+// $QWIS_SMTP_USERNAME and $QWIS_SMTP_PASSWORD when they are set.
+type notifier struct {
+	webhook  string
+	slack    string
+	smtpAddr string
+	mailFrom string
+	mailTo   []string
+	message  *template.Template
+}
+
+func (n *notifier) enabled() bool {
+	return len(n.webhook) != 0 || len(n.slack) != 0 || len(n.smtpAddr) != 0
+}
+
+// check reports an incomplete SMTP setup.
+func (n *notifier) check() error {
+	switch {
+	case len(n.smtpAddr) == 0 && (len(n.mailFrom) != 0 || len(n.mailTo) != 0):
+		return errors.New("-mail-from and -mail-to need -smtp")
+	case len(n.smtpAddr) != 0 && (len(n.mailFrom) == 0 || len(n.mailTo) == 0):
+		return errors.New("-smtp needs -mail-from and -mail-to")
+	}
+	if _, _, err := net.SplitHostPort(n.smtpAddr); len(n.smtpAddr) != 0 && err != nil {
+		return fmt.Errorf("Invalid SMTP address: %s", n.smtpAddr)
+	}
+	return nil
+}
+
+// notify sends a to every destination set and reports those it failed.
+func (n *notifier) notify(ctx context.Context, a watchAlert) error {
+	var sb strings.Builder
+	if err := n.message.Execute(&sb, a); err != nil {
+		return err
+	}
+	a.Message = sb.String()
+	var errs []error
+	if len(n.webhook) != 0 {
+		if err := postJSON(ctx, n.webhook, a); err != nil {
+			errs = append(errs, fmt.Errorf("webhook: %w", err))
+		}
+	}
+	if len(n.slack) != 0 {
+		if err := postJSON(ctx, n.slack, map[string]string{"text": a.Message}); err != nil {
+			errs = append(errs, fmt.Errorf("slack: %w", err))
+		}
+	}
+	if len(n.smtpAddr) != 0 {
+		if err := n.sendMail(a); err != nil {
+			errs = append(errs, fmt.Errorf("smtp: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (n *notifier) sendMail(a watchAlert) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\nTo: %s\r\nSubject: qwis: %s %s\r\nDate: %s\r\n", n.mailFrom,
+		strings.Join(n.mailTo, ", "), a.Domain, a.Kind, a.Time.Format(time.RFC1123Z))
+	fmt.Fprint(&msg, "MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprint(&msg, strings.ReplaceAll(strings.TrimSuffix(a.Message, "\n"), "\n", "\r\n")+"\r\n")
+	var auth smtp.Auth
+	if user := os.Getenv("QWIS_SMTP_USERNAME"); len(user) != 0 {
+		host, _, _ := net.SplitHostPort(n.smtpAddr)
+		auth = smtp.PlainAuth("", user, os.Getenv("QWIS_SMTP_PASSWORD"), host)
+	}
+	return smtp.SendMail(n.smtpAddr, auth, n.mailFrom, n.mailTo, msg.Bytes())
+}
+
+func postJSON(ctx context.Context, url string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("returned %s", resp.Status)
+	}
+	return nil
+}
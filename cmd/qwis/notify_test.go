@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/pkorotkov/qwis"
+	"github.com/pkorotkov/qwis/internal/whoistest"
+)
+
+// fakeSMTP accepts one message at a time, without extensions, and sends
+// the data of every message it gets on the returned channel.
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	msgs := make(chan string, 4)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r, data := bufio.NewReader(c), ""
+			c.Write([]byte("220 fake ESMTP\r\n"))
+			for inData := false; ; {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					break
+				}
+				switch {
+				case inData && l == ".\r\n":
+					inData = false
+					msgs <- data
+					c.Write([]byte("250 queued\r\n"))
+				case inData:
+					data += l
+				case strings.HasPrefix(l, "DATA"):
+					inData = true
+					c.Write([]byte("354 go ahead\r\n"))
+				case strings.HasPrefix(l, "QUIT"):
+					c.Write([]byte("221 bye\r\n"))
+					c.Close()
+				default:
+					c.Write([]byte("250 ok\r\n"))
+				}
+			}
+			c.Close()
+		}
+	}()
+	return ln.Addr().String(), msgs
+}
+
+func TestRunWatchNotify(t *testing.T) {
+	expires := time.Now().Add(10 * 24 * time.Hour).UTC().Format(time.RFC3339)
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": "Domain Name: EXAMPLE.COM\r\nRegistry Expiry Date: " + expires + "\r\n"}}
+	var texts, messages []string
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p map[string]string
+		json.NewDecoder(r.Body).Decode(&p)
+		texts = append(texts, p["text"])
+	}))
+	defer slack.Close()
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a watchAlert
+		json.NewDecoder(r.Body).Decode(&a)
+		messages = append(messages, a.Message)
+	}))
+	defer hook.Close()
+	smtpAddr, mails := fakeSMTP(t)
+	ec, stdout, stderr := runCLI(t, "", fs, "watch", "-count", "1", "-webhook", hook.URL, "-slack-webhook", slack.URL,
+		"-smtp", smtpAddr, "-mail-from", "qwis@example.net", "-mail-to", "ops@example.net, oncall@example.net",
+		"-message", "{{.Domain}} {{.Kind}} in {{.DaysLeft}} days", "example.com")
+	if ec != 11 || strings.Contains(stdout, "message") {
+		t.Fatalf("run = %d, %q, %q", ec, stdout, stderr)
+	}
+	want := "example.com expiring in 9 days"
+	if len(texts) != 1 || texts[0] != want {
+		t.Errorf("slack got %q, want %q", texts, want)
+	}
+	if len(messages) != 1 || messages[0] != want {
+		t.Errorf("webhook got %q, want %q", messages, want)
+	}
+	select {
+	case m := <-mails:
+		for _, h := range []string{"To: ops@example.net, oncall@example.net\r\n", "Subject: qwis: example.com expiring\r\n", "\r\n\r\n" + want + "\r\n"} {
+			if !strings.Contains(m, h) {
+				t.Errorf("mail lacks %q:\n%s", h, m)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("no mail sent")
+	}
+}
+
+func TestRunWatchNotifyUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{"-smtp", "127.0.0.1:25", "-mail-to", "ops@example.net"},
+		{"-mail-from", "qwis@example.net"},
+		{"-smtp", "mail.example.net", "-mail-from", "qwis@example.net", "-mail-to", "ops@example.net"},
+		{"-message", "{{.Domain"},
+	} {
+		if ec, _, _ := runCLI(t, "", nil, append(append([]string{"watch"}, args...), "example.com")...); ec != 1 {
+			t.Errorf("watch %q = %d, want 1", args, ec)
+		}
+	}
+}
+
+func TestDefaultAlertMessage(t *testing.T) {
+	days := 3
+	msg := template.Must(template.New("message").Parse(defaultAlertMessage))
+	for _, tc := range []struct {
+		a    watchAlert
+		want string
+	}{
+		{watchAlert{Domain: "example.com", Kind: "expiring", ExpirationDate: "2026-01-01", DaysLeft: &days},
+			"example.com: expires on 2026-01-01, in 3 days"},
+		{watchAlert{Domain: "example.com", Kind: "changed", Changes: []qwis.FieldChange{{Field: "registrar", Old: "A", New: "B"}}},
+			"example.com: changed; registrar: A -> B"},
+	} {
+		var sb strings.Builder
+		if err := msg.Execute(&sb, tc.a); err != nil || sb.String() != tc.want {
+			t.Errorf("default message = %q, %v, want %q", sb.String(), err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkorotkov/qwis"
+)
+
+const replHelp = `:raw             write the answers of the servers
+:json            write JSON
+:fields [f,...]  write only these fields as JSON; without fields, all of them
+:help            show this list
+:quit            leave (so does end of input)
+Anything else is looked up, a query per word.`
+
+// repl is the state of an interactive session: lines read are looked up
+// with lookup, each within timeout if it is set, and written with write,
+// which the commands of replHelp change.
+type repl struct {
+	lookup  qwis.LookupFunc
+	format  string
+	write   func(*qwis.WhoisResponse, io.Writer) error
+	timeout time.Duration
+	stdout  io.Writer
+	stderr  io.Writer
+	ec      int
+}
+
+// lineWriter remembers whether what was written to it ends a line.
+type lineWriter struct {
+	w       io.Writer
+	midLine bool
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	if len(p) != 0 {
+		lw.midLine = p[len(p)-1] != '\n'
+	}
+	return lw.w.Write(p)
+}
+
+// command runs the colon command line, reporting whether the session goes
+// on.
+func (r *repl) command(line string) bool {
+	name, arg, _ := strings.Cut(line, " ")
+	switch arg = strings.TrimSpace(arg); name {
+	case ":raw":
+		r.format, r.write = "raw", (*qwis.WhoisResponse).WriteAsRawText
+	case ":json":
+		r.format, r.write = "json", (*qwis.WhoisResponse).WriteAsJSON
+	case ":fields":
+		if len(arg) == 0 {
+			r.format, r.write = "json", (*qwis.WhoisResponse).WriteAsJSON
+			break
+		}
+		fields, err := fieldsArg(arg)
+		if err != nil {
+			printErrorMessage(r.stderr, err.Error(), 1)
+			break
+		}
+		r.format, r.write = "json", func(wir *qwis.WhoisResponse, w io.Writer) error {
+			v, err := wir.SelectFields(fields, nil)
+			if err != nil {
+				return err
+			}
+			return qwis.WriteIndentedJSON(w, v)
+		}
+	case ":help":
+		fmt.Fprintln(r.stdout, replHelp)
+	case ":quit", ":q", ":exit":
+		return false
+	default:
+		printErrorMessage(r.stderr, fmt.Sprintf("Unknown command: %s; :help lists them", name), 1)
+	}
+	return true
+}
+
+func (r *repl) query(q string) {
+	ctx := context.Background()
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+	if qwis.IsIPQuery(q) || qwis.IsASNQuery(q) {
+		format := "json"
+		if r.format == "raw" {
+			format = "raw"
+		}
+		r.ec = runResourceLookup(ctx, q, format, r.stdout, r.stderr)
+		return
+	}
+	wir, err := r.lookup(ctx, q)
+	if r.format == "available" && errors.Is(err, qwis.ErrNoSuchDomain) {
+		fmt.Fprintln(r.stdout, "available")
+		return
+	}
+	if err != nil {
+		r.ec = printErrorMessage(r.stderr, err.Error(), lookupExitCode(err))
+		return
+	}
+	if err = r.write(wir, r.stdout); err != nil {
+		r.ec = printErrorMessage(r.stderr, err.Error(), 7)
+	}
+}
+
+// run reads queries and commands from stdin until it ends or :quit,
+// prompting for each line on stdout on a line of its own. Connections are kept open and servers
+// discovered are remembered between queries. It returns the exit code of
+// the last lookup that failed, if any.
+func (r *repl) run(stdin io.Reader) int {
+	qwis.ReuseConnections = true
+	lw := &lineWriter{w: r.stdout}
+	r.stdout = lw
+	s := bufio.NewScanner(stdin)
+	for {
+		if lw.midLine {
+			fmt.Fprintln(lw)
+		}
+		fmt.Fprint(lw, "qwis> ")
+		if !s.Scan() {
+			fmt.Fprintln(r.stdout)
+			break
+		}
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, ":") {
+			if !r.command(line) {
+				break
+			}
+			continue
+		}
+		for _, q := range strings.Fields(line) {
+			r.query(q)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return printErrorMessage(r.stderr, err.Error(), 9)
+	}
+	return r.ec
+}
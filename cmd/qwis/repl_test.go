@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkorotkov/qwis/internal/whoistest"
+)
+
+func TestRunInteractive(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{
+		"whois.iana.org:43":         "domain:       COM\nwhois:        whois.verisign-grs.com\n",
+		"whois.verisign-grs.com:43": exampleCom,
+	}}
+	var dials atomic.Int32
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		dials.Add(1)
+		return fs.Dial(ctx, network, address)
+	}
+	stdin := "example.com\n\n:raw\nexample.com\n:fields registrar,expiration_date\nexample.com\n:fields bogus\n:nope\n:json\nexample.com\n:quit\nexample.org\n"
+	ec, stdout, stderr := runDialing(t, stdin, dial, "-i")
+	if ec != 0 {
+		t.Fatalf("-i = %d: %s", ec, stderr)
+	}
+	outputs := strings.Split(stdout, "qwis> ")
+	if len(outputs) != 12 {
+		t.Fatalf("got %d prompts:\n%s", len(outputs)-1, stdout)
+	}
+	if !strings.Contains(outputs[1], `"domain_name": "EXAMPLE.COM"`) {
+		t.Errorf("JSON expected first:\n%s", outputs[1])
+	}
+	if !strings.HasPrefix(outputs[4], "Domain Name: EXAMPLE.COM") {
+		t.Errorf(":raw does not write the answer:\n%s", outputs[4])
+	}
+	if want := "{\n    \"expiration_date\": \"2026-08-13T04:00:00Z\",\n    \"registrar\": \"Example Registrar, Inc.\"\n}\n"; outputs[6] != want {
+		t.Errorf(":fields wrote\n%s\nwant\n%s", outputs[6], want)
+	}
+	if !strings.Contains(outputs[10], `"name_servers"`) {
+		t.Errorf(":json does not write every field:\n%s", outputs[10])
+	}
+	if !strings.Contains(stderr, `Invalid field: "bogus"`) || !strings.Contains(stderr, "Unknown command: :nope") {
+		t.Errorf("stderr:\n%s", stderr)
+	}
+	if strings.Contains(stdout, "EXAMPLE.ORG") {
+		t.Errorf("looked up past :quit:\n%s", stdout)
+	}
+	// IANA is asked for the server of com once, and the answer is cached.
+	if n := dials.Load(); n != 2 {
+		t.Errorf("dialed %d times for the same domain, want 2", n)
+	}
+}
+
+func TestRunInteractiveErrors(t *testing.T) {
+	ec, _, stderr := runCLI(t, "example.com\n", nil, "-i")
+	if ec != 2 || !strings.Contains(stderr, "Error:") {
+		t.Errorf("-i with a failing lookup = %d: %s", ec, stderr)
+	}
+	if ec, _, _ := runCLI(t, "", nil, "-i", "example.com"); ec != 1 {
+		t.Errorf("-i with a domain name = %d, want 1", ec)
+	}
+}
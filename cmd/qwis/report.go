@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkorotkov/qwis"
+)
+
+// reportHeader are the columns of qwis report.
+var reportHeader = []string{"domain", "registrar", "creation_date", "expiration_date", "days_until_expiry", "statuses", "error"}
+
+var reportOptions = map[string]bool{
+	"-input":          true,
+	"-f":              true,
+	"-output":         true,
+	"-o":              true,
+	"-c":              true,
+	"-timeout":        true,
+	"-list-sep":       true,
+	"-qps":            true,
+	"-qps-per-server": true,
+}
+
+// reportRow is the line of r in the report made at now.
+func reportRow(r qwis.BatchResult, listSep string, now time.Time) []string {
+	row := make([]string, len(reportHeader))
+	row[0] = r.Domain
+	switch {
+	case r.Err != nil:
+		row[6] = r.Err.Error()
+	case r.Response != nil:
+		wir := r.Response
+		row[1], row[2], row[3] = wir.Registrar, wir.CreationDate, wir.ExpirationDate
+		if wir.SetDaysUntilExpiry(now); wir.DaysUntilExpiry != nil {
+			row[4] = strconv.Itoa(*wir.DaysUntilExpiry)
+		}
+		row[5] = strings.Join(wir.Statuses, listSep)
+	default:
+		row[6] = "not a domain name"
+	}
+	return row
+}
+
+// utf8BOM is written before a report going to a file with -bom, for the
+// tools, such as Excel, that only read it as UTF-8 with one.
+const utf8BOM = "\ufeff"
+
+// runReport looks the domains up and writes a CSV report of them, failed
+// lookups included, to -output or stdout. It exits with the code of the
+// last failed lookup, if any.
+func runReport(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var (
+		inputFile   string
+		outputFile  string
+		bom         bool
+		listSep     = ";"
+		timeout     time.Duration
+		concurrency = 8
+	)
+	args, operands, err := splitArgs(args, reportOptions)
+	if err != nil {
+		return printErrorMessage(stderr, err.Error(), 1)
+	}
+	if helpRequested(args, reportOptions) {
+		return printHelpMessage(stdout, "report")
+	}
+	for ; len(args) > 0; args = args[1:] {
+		a, v := args[0], ""
+		if reportOptions[a] {
+			args = args[1:]
+			v = args[0]
+		}
+		var err error
+		switch a {
+		case "-input", "-f":
+			inputFile = v
+		case "-output", "-o":
+			outputFile = v
+		case "-c":
+			if concurrency, err = strconv.Atoi(v); err == nil && concurrency < 1 {
+				err = fmt.Errorf("Invalid concurrency: %s", v)
+			}
+		case "-timeout":
+			timeout, err = durationArg(v)
+		case "-bom":
+			bom = true
+		case "-list-sep":
+			listSep = v
+		case "-qps":
+			qwis.RateLimit.QPS, err = qpsArg(v)
+		case "-qps-per-server":
+			qwis.RateLimit.PerServerQPS, err = qpsArg(v)
+		case "-verbose", "-v", "-debug":
+			qwis.Logger = newLogger(stderr, a == "-debug")
+		default:
+			err = fmt.Errorf("Invalid set of arguments")
+		}
+		if err != nil {
+			return printErrorMessage(stderr, err.Error(), 1)
+		}
+	}
+	domains := operands
+	if len(inputFile) != 0 {
+		fd, err := readDomains(inputFile, stdin)
+		if err != nil {
+			return printErrorMessage(stderr, err.Error(), 1)
+		}
+		domains = append(domains, fd...)
+	}
+	if len(domains) == 0 {
+		return printErrorMessage(stderr, "Invalid set of arguments", 1)
+	}
+	w, closeOutput := stdout, func() error { return nil }
+	if len(outputFile) != 0 && outputFile != "-" {
+		f, err := os.Create(outputFile)
+		if err != nil {
+			return printErrorMessage(stderr, err.Error(), 7)
+		}
+		w, closeOutput = f, f.Close
+		if bom {
+			if _, err := io.WriteString(w, utf8BOM); err != nil {
+				closeOutput()
+				return printErrorMessage(stderr, err.Error(), 7)
+			}
+		}
+	}
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	defer qwis.CloseIdleConnections()
+	results := qwis.WhoisBatch(ctx, domains, concurrency)
+	now := qwis.Now()
+	cw := csv.NewWriter(w)
+	cw.Write(reportHeader)
+	ec := 0
+	for _, r := range results {
+		if r.Err != nil {
+			ec = lookupExitCode(r.Err)
+		}
+		cw.Write(reportRow(r, listSep, now))
+	}
+	cw.Flush()
+	if err := errors.Join(cw.Error(), closeOutput()); err != nil {
+		return printErrorMessage(stderr, err.Error(), 7)
+	}
+	return ec
+}
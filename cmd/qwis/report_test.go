@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkorotkov/qwis/internal/whoistest"
+)
+
+func TestRunReport(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	dir := t.TempDir()
+	input, output := filepath.Join(dir, "domains.txt"), filepath.Join(dir, "report.csv")
+	if err := os.WriteFile(input, []byte("example.com\n# skipped\nexample.org\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ec, stdout, stderr := runCLI(t, "", fs, "report", "--input", input, "--output", output, "-c", "2")
+	if ec != 2 || len(stdout) != 0 {
+		t.Fatalf("report = %d, %q, %q", ec, stdout, stderr)
+	}
+	b, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(strings.NewReader(string(b))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || strings.Join(rows[0], ",") != strings.Join(reportHeader, ",") {
+		t.Fatalf("report:\n%s", b)
+	}
+	expiry, _ := time.Parse(time.RFC3339, "2026-08-13T04:00:00Z")
+	days := strconv.Itoa(int(time.Until(expiry).Hours() / 24))
+	want := []string{"example.com", "Example Registrar, Inc.", "1995-08-14T04:00:00Z", "2026-08-13T04:00:00Z", days, "clientTransferProhibited", ""}
+	if strings.Join(rows[1], "|") != strings.Join(want, "|") {
+		t.Errorf("row = %q, want %q", rows[1], want)
+	}
+	if rows[2][0] != "example.org" || !strings.Contains(rows[2][6], "server unavailable") || len(rows[2][1]) != 0 {
+		t.Errorf("failed row = %q", rows[2])
+	}
+	ec, stdout, _ = runCLI(t, "example.com\n", fs, "report", "-input", "-")
+	if ec != 0 || !strings.HasPrefix(stdout, "domain,registrar,") || strings.Count(stdout, "\n") != 2 {
+		t.Errorf("report to stdout = %d, %q", ec, stdout)
+	}
+	if ec, _, _ = runCLI(t, "", fs, "report"); ec != 1 {
+		t.Errorf("report without domains = %d, want 1", ec)
+	}
+}
+
+func TestRunReportBOM(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	output := filepath.Join(t.TempDir(), "report.csv")
+	for _, bom := range []bool{false, true} {
+		args := []string{"report", "-output", output, "example.com"}
+		if bom {
+			args = append(args, "-bom")
+		}
+		if ec, _, stderr := runCLI(t, "", fs, args...); ec != 0 {
+			t.Fatalf("%v = %d: %s", args, ec, stderr)
+		}
+		b, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []byte{0xef, 0xbb, 0xbf}; bytes.HasPrefix(b, want) != bom || !bytes.Contains(b, []byte("domain,registrar,")) {
+			t.Errorf("-bom %t: report starts with % x", bom, b[:8])
+		}
+		if bom && !bytes.HasPrefix(b[3:], []byte("domain,")) {
+			t.Errorf("-bom: header does not follow the BOM: %q", b)
+		}
+	}
+	ec, stdout, _ := runCLI(t, "", fs, "report", "-bom", "example.com")
+	if ec != 0 || !strings.HasPrefix(stdout, "domain,") {
+		t.Errorf("-bom to stdout = %d, %q", ec, stdout)
+	}
+}
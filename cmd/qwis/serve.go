@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkorotkov/qwis"
+	"google.golang.org/grpc"
+)
+
+type rateLimiter struct {
+	sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(perMinute),
+		buckets: map[string]*bucket{},
+	}
+}
+
+func (rl *rateLimiter) allow(client string, now time.Time) bool {
+	rl.Lock()
+	defer rl.Unlock()
+	if len(rl.buckets) > 4096 {
+		for c, b := range rl.buckets {
+			if now.Sub(b.last) > time.Duration(rl.burst/rl.rate)*time.Second {
+				delete(rl.buckets, c)
+			}
+		}
+	}
+	b, ok := rl.buckets[client]
+	if !ok {
+		b = &bucket{tokens: rl.burst, last: now}
+		rl.buckets[client] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * rl.rate
+	if b.tokens > rl.burst {
+		b.tokens = rl.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func writeJSONError(w http.ResponseWriter, code int, m string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": m})
+}
+
+func lookupStatus(err error) int {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, qwis.ErrNoSuchDomain):
+		return http.StatusNotFound
+	case errors.Is(err, qwis.ErrUnsupportedTLD), errors.Is(err, qwis.ErrInvalidDomainName):
+		return http.StatusBadRequest
+	case errors.Is(err, qwis.ErrRateLimited), errors.Is(err, qwis.ErrServerUnavailable):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadGateway
+}
+
+// serveLookup looks q up as an IP address or CIDR block, an AS number or a
+// domain name.
+func serveLookup(ctx context.Context, q string) (interface{}, error) {
+	switch {
+	case qwis.IsIPQuery(q):
+		return qwis.IPWhoisContext(ctx, q)
+	case qwis.IsASNQuery(q):
+		return qwis.ASWhoisContext(ctx, q)
+	}
+	wir, err := qwis.WhoisContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	wir.SetDaysUntilExpiry(qwis.Now())
+	return wir, nil
+}
+
+func newServeMux(timeout time.Duration, rl *rateLimiter, m *metrics) http.Handler {
+	handle := func(endpoint string, lookup func(ctx context.Context, q string) (interface{}, error)) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			client, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				client = r.RemoteAddr
+			}
+			if rl != nil && !rl.allow(client, time.Now()) {
+				writeJSONError(w, http.StatusTooManyRequests, "rate limit exceeded")
+				return
+			}
+			ctx := r.Context()
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+			q := r.PathValue("query")
+			res, err := lookup(ctx, q)
+			m.observeLookup(endpoint, q, err)
+			if err != nil {
+				writeJSONError(w, lookupStatus(err), err.Error())
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			qwis.WriteIndentedJSON(w, res)
+		}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/whois/{query}", handle("whois", serveLookup))
+	mux.HandleFunc("GET /v1/rdap/{query}", handle("rdap", func(ctx context.Context, q string) (interface{}, error) {
+		return qwis.RDAPContext(ctx, q)
+	}))
+	mux.Handle("GET /metrics", m)
+	return mux
+}
+
+var serveOptions = map[string]bool{
+	"-listen":         true,
+	"-grpc-listen":    true,
+	"-rate":           true,
+	"-timeout":        true,
+	"-cache-ttl":      true,
+	"-qps":            true,
+	"-qps-per-server": true,
+}
+
+func runServe(args []string, stdout, stderr io.Writer) int {
+	var (
+		listen   = "127.0.0.1:8043"
+		grpcAddr string
+		rate     = 60
+		timeout  = 30 * time.Second
+		cacheTTL = 10 * time.Minute
+	)
+	args, operands, err := splitArgs(args, serveOptions)
+	if err != nil {
+		return printErrorMessage(stderr, err.Error(), 1)
+	}
+	if helpRequested(args, serveOptions) {
+		return printHelpMessage(stdout, "serve")
+	}
+	if len(operands) != 0 {
+		return printErrorMessage(stderr, "Invalid set of arguments", 1)
+	}
+	for ; len(args) > 0; args = args[1:] {
+		a, v := args[0], ""
+		if serveOptions[a] {
+			args = args[1:]
+			v = args[0]
+		}
+		var err error
+		switch a {
+		case "-listen":
+			listen = v
+		case "-grpc-listen":
+			grpcAddr = v
+		case "-rate":
+			if rate, err = strconv.Atoi(v); err == nil && rate < 0 {
+				err = fmt.Errorf("Invalid rate: %s", v)
+			}
+		case "-timeout":
+			timeout, err = durationArg(v)
+		case "-cache-ttl":
+			cacheTTL, err = durationArg(v)
+		case "-qps":
+			qwis.RateLimit.QPS, err = qpsArg(v)
+		case "-qps-per-server":
+			qwis.RateLimit.PerServerQPS, err = qpsArg(v)
+		case "-verbose", "-v", "-debug":
+			qwis.Logger = newLogger(stderr, a == "-debug")
+		default:
+			err = fmt.Errorf("Invalid set of arguments")
+		}
+		if err != nil {
+			return printErrorMessage(stderr, err.Error(), 1)
+		}
+	}
+	if cacheTTL > 0 {
+		qwis.ResponseCache = qwis.NewMemoryCache(cacheTTL)
+	}
+	var rl *rateLimiter
+	if rate > 0 {
+		rl = newRateLimiter(rate)
+	}
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return printErrorMessage(stderr, err.Error(), 1)
+	}
+	var gln net.Listener
+	if len(grpcAddr) != 0 {
+		if gln, err = net.Listen("tcp", grpcAddr); err != nil {
+			ln.Close()
+			return printErrorMessage(stderr, err.Error(), 1)
+		}
+	}
+	m := newMetrics()
+	qwis.LookupHooks = m.hooks()
+	srv := &http.Server{Handler: newServeMux(timeout, rl, m), ReadHeaderTimeout: 10 * time.Second}
+	var gs *grpc.Server
+	if gln != nil {
+		gs = newGRPCServer(timeout, rl, m)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	done := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), timeout+5*time.Second)
+		defer cancel()
+		if gs != nil {
+			gs.GracefulStop()
+		}
+		done <- srv.Shutdown(sctx)
+	}()
+	fmt.Fprintf(stdout, "Listening on %s\n", ln.Addr())
+	if gs != nil {
+		fmt.Fprintf(stdout, "Serving gRPC on %s\n", gln.Addr())
+		go gs.Serve(gln)
+	}
+	if err = srv.Serve(ln); err != http.ErrServerClosed {
+		return printErrorMessage(stderr, err.Error(), 9)
+	}
+	if err = <-done; err != nil {
+		return printErrorMessage(stderr, err.Error(), 9)
+	}
+	return 0
+}
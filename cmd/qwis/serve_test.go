@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkorotkov/qwis"
+	"github.com/pkorotkov/qwis/internal/whoistest"
+)
+
+func TestServeMetrics(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": exampleCom}}
+	dial, cache, hooks := qwis.Dial, qwis.ResponseCache, qwis.LookupHooks
+	t.Cleanup(func() {
+		qwis.Dial, qwis.ResponseCache, qwis.LookupHooks = dial, cache, hooks
+		qwis.ResetWhoisServers()
+	})
+	qwis.ResetWhoisServers()
+	qwis.Dial, qwis.ResponseCache = fs.Dial, qwis.NewMemoryCache(time.Minute)
+	m := newMetrics()
+	qwis.LookupHooks = m.hooks()
+	srv := httptest.NewServer(newServeMux(5*time.Second, nil, m))
+	defer srv.Close()
+	get := func(path string) (int, string) {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	for _, q := range []string{"example.com", "example.com", "example.org", "example..com", "example.made-up"} {
+		get("/v1/whois/" + q)
+	}
+	code, body := get("/metrics")
+	if code != http.StatusOK {
+		t.Fatalf("/metrics = %d", code)
+	}
+	for _, want := range []string{
+		`qwis_lookups_total{endpoint="whois",tld="com"} 3`,
+		`qwis_lookups_total{endpoint="whois",tld="org"} 1`,
+		`qwis_lookups_total{endpoint="whois",tld="other"} 1`,
+		`qwis_lookup_errors_total{class="unavailable"} 2`,
+		`qwis_lookup_errors_total{class="invalid_domain"} 1`,
+		"qwis_cache_hits_total 1\n",
+		"qwis_upstream_queries_total ",
+		"# TYPE qwis_upstream_duration_seconds histogram\n",
+		`qwis_upstream_duration_seconds_bucket{le="+Inf"} `,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics lacks %q:\n%s", want, body)
+		}
+	}
+}
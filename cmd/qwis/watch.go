@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+
+	"github.com/pkorotkov/qwis"
+)
+
+// watchedFields are the changes between two rounds that raise an alert;
+// other fields, such as the dates, move on every renewal.
+var watchedFields = map[string]bool{
+	"registrar":    true,
+	"statuses":     true,
+	"name_servers": true,
+}
+
+type watchAlert struct {
+	Domain         string             `json:"domain"`
+	Kind           string             `json:"kind"`
+	ExpirationDate string             `json:"expiration_date,omitempty"`
+	DaysLeft       *int               `json:"days_left,omitempty"`
+	Changes        []qwis.FieldChange `json:"changes,omitempty"`
+	Time           time.Time          `json:"time"`
+	Message        string             `json:"message,omitempty"`
+}
+
+// watchAlerts compares a round's response for a domain with the previous
+// one, which is nil in the first round.
+func watchAlerts(dn string, prev, cur *qwis.WhoisResponse, threshold time.Duration, now time.Time) []watchAlert {
+	var alerts []watchAlert
+	if !cur.ExpirationTime.IsZero() && cur.ExpirationTime.Sub(now) <= threshold {
+		days := int(cur.ExpirationTime.Sub(now).Hours() / 24)
+		alerts = append(alerts, watchAlert{Domain: dn, Kind: "expiring", ExpirationDate: cur.ExpirationDate, DaysLeft: &days, Time: now})
+	}
+	if prev != nil {
+		var changes []qwis.FieldChange
+		for _, c := range qwis.CompareResponses(prev, cur) {
+			if watchedFields[c.Field] {
+				changes = append(changes, c)
+			}
+		}
+		if len(changes) != 0 {
+			alerts = append(alerts, watchAlert{Domain: dn, Kind: "changed", Changes: changes, Time: now})
+		}
+	}
+	return alerts
+}
+
+var watchOptions = map[string]bool{
+	"-f":              true,
+	"-interval":       true,
+	"-threshold":      true,
+	"-count":          true,
+	"-c":              true,
+	"-webhook":        true,
+	"-slack-webhook":  true,
+	"-smtp":           true,
+	"-mail-from":      true,
+	"-mail-to":        true,
+	"-message":        true,
+	"-message-file":   true,
+	"-qps":            true,
+	"-qps-per-server": true,
+}
+
+func runWatch(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var (
+		inputFile   string
+		n           notifier
+		message     = defaultAlertMessage
+		interval    = 24 * time.Hour
+		threshold   = 30
+		count       int
+		concurrency = 8
+	)
+	args, operands, err := splitArgs(args, watchOptions)
+	if err != nil {
+		return printErrorMessage(stderr, err.Error(), 1)
+	}
+	if helpRequested(args, watchOptions) {
+		return printHelpMessage(stdout, "watch")
+	}
+	for ; len(args) > 0; args = args[1:] {
+		a, v := args[0], ""
+		if watchOptions[a] {
+			args = args[1:]
+			v = args[0]
+		}
+		var err error
+		switch a {
+		case "-f":
+			inputFile = v
+		case "-interval":
+			if interval, err = durationArg(v); err == nil && interval <= 0 {
+				err = fmt.Errorf("Invalid interval: %s", v)
+			}
+		case "-threshold":
+			if threshold, err = strconv.Atoi(v); err == nil && threshold < 0 {
+				err = fmt.Errorf("Invalid threshold: %s", v)
+			}
+		case "-count":
+			if count, err = strconv.Atoi(v); err == nil && count < 0 {
+				err = fmt.Errorf("Invalid count: %s", v)
+			}
+		case "-c":
+			if concurrency, err = strconv.Atoi(v); err == nil && concurrency < 1 {
+				err = fmt.Errorf("Invalid concurrency: %s", v)
+			}
+		case "-webhook":
+			n.webhook = v
+		case "-slack-webhook":
+			n.slack = v
+		case "-smtp":
+			n.smtpAddr = v
+		case "-mail-from":
+			n.mailFrom = v
+		case "-mail-to":
+			n.mailTo = nil
+			for _, to := range strings.Split(v, ",") {
+				if to = strings.TrimSpace(to); len(to) != 0 {
+					n.mailTo = append(n.mailTo, to)
+				}
+			}
+		case "-message":
+			message = v
+		case "-message-file":
+			var b []byte
+			if b, err = os.ReadFile(v); err == nil {
+				message = string(b)
+			}
+		case "-qps":
+			qwis.RateLimit.QPS, err = qpsArg(v)
+		case "-qps-per-server":
+			qwis.RateLimit.PerServerQPS, err = qpsArg(v)
+		case "-verbose", "-v", "-debug":
+			qwis.Logger = newLogger(stderr, a == "-debug")
+		default:
+			err = fmt.Errorf("Invalid set of arguments")
+		}
+		if err != nil {
+			return printErrorMessage(stderr, err.Error(), 1)
+		}
+	}
+	if err = n.check(); err != nil {
+		return printErrorMessage(stderr, err.Error(), 1)
+	}
+	if n.message, err = template.New("message").Parse(message); err != nil {
+		return printErrorMessage(stderr, err.Error(), 1)
+	}
+	domains := operands
+	if len(inputFile) != 0 {
+		fd, err := readDomains(inputFile, stdin)
+		if err != nil {
+			return printErrorMessage(stderr, err.Error(), 1)
+		}
+		domains = append(domains, fd...)
+	}
+	if len(domains) == 0 {
+		return printErrorMessage(stderr, "Invalid set of arguments", 1)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var (
+		enc     = json.NewEncoder(stdout)
+		last    = map[string]*qwis.WhoisResponse{}
+		alerted bool
+	)
+	for round := 0; count == 0 || round < count; round++ {
+		if round > 0 {
+			t := time.NewTimer(interval)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return 0
+			case <-t.C:
+			}
+		}
+		now := qwis.Now()
+		for _, r := range qwis.WhoisBatch(ctx, domains, concurrency) {
+			if r.Err != nil {
+				fmt.Fprintf(stderr, "Warning: %s: %s\n", r.Domain, r.Err)
+				continue
+			}
+			if r.Response == nil {
+				continue
+			}
+			for _, a := range watchAlerts(r.Domain, last[r.Domain], r.Response, time.Duration(threshold)*24*time.Hour, now) {
+				alerted = true
+				if err := enc.Encode(a); err != nil {
+					return printErrorMessage(stderr, err.Error(), 7)
+				}
+				if n.enabled() {
+					if err := n.notify(ctx, a); err != nil {
+						fmt.Fprintf(stderr, "Warning: %s: alert not delivered: %s\n", r.Domain, err)
+					}
+				}
+			}
+			last[r.Domain] = r.Response
+		}
+		if ctx.Err() != nil {
+			return 0
+		}
+	}
+	if alerted {
+		return 11
+	}
+	return 0
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkorotkov/qwis/internal/whoistest"
+)
+
+func TestRunWatch(t *testing.T) {
+	expires := time.Now().Add(10 * 24 * time.Hour).UTC().Format(time.RFC3339)
+	var (
+		mu    sync.Mutex
+		round int
+	)
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		if address != "whois.verisign-grs.com:43" {
+			return nil, errors.New("connection refused")
+		}
+		mu.Lock()
+		round++
+		ns := "NS1.EXAMPLE.NET"
+		if round > 1 {
+			ns = "NS1.ELSEWHERE.NET"
+		}
+		mu.Unlock()
+		c, s := net.Pipe()
+		go func() {
+			bufio.NewReader(s).ReadString('\n')
+			fmt.Fprintf(s, "Domain Name: EXAMPLE.COM\r\nRegistrar: Example Registrar, Inc.\r\n"+
+				"Registry Expiry Date: %s\r\nName Server: %s\r\n", expires, ns)
+			s.Close()
+		}()
+		return c, nil
+	}
+	var hooked []watchAlert
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a watchAlert
+		json.NewDecoder(r.Body).Decode(&a)
+		mu.Lock()
+		hooked = append(hooked, a)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+	ec, stdout, stderr := runDialing(t, "", dial, "watch", "-count", "2", "-interval", "1ms", "-webhook", hook.URL, "example.com")
+	if ec != 11 {
+		t.Fatalf("exit code %d, stderr %q", ec, stderr)
+	}
+	var kinds []string
+	dec := json.NewDecoder(strings.NewReader(stdout))
+	for {
+		var a watchAlert
+		if err := dec.Decode(&a); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		kinds = append(kinds, a.Kind)
+		if a.Kind == "changed" && (len(a.Changes) != 1 || a.Changes[0].Field != "name_servers") {
+			t.Errorf("changes = %+v", a.Changes)
+		}
+		if a.Kind == "expiring" && (a.DaysLeft == nil || *a.DaysLeft != 9) {
+			t.Errorf("days left = %v", a.DaysLeft)
+		}
+	}
+	if want := "expiring expiring changed"; strings.Join(kinds, " ") != want {
+		t.Errorf("alerts %q, want %s", kinds, want)
+	}
+	if len(hooked) != 3 {
+		t.Errorf("webhook got %d alerts, want 3", len(hooked))
+	}
+}
+
+func TestRunWatchQuiet(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": "Domain Name: EXAMPLE.COM\r\nRegistry Expiry Date: 2999-01-01T00:00:00Z\r\n"}}
+	if ec, stdout, stderr := runCLI(t, "", fs, "watch", "-count", "1", "example.com"); ec != 0 || len(stdout) != 0 {
+		t.Errorf("run = %d, %q, %q", ec, stdout, stderr)
+	}
+}
@@ -0,0 +1,79 @@
+package qwis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FieldChange is a field whose value differs between two responses.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+func normalizedSet(vs []string) string {
+	ns := make([]string, 0, len(vs))
+	for _, v := range vs {
+		ns = append(ns, strings.ToLower(strings.TrimSuffix(strings.TrimSpace(v), ".")))
+	}
+	sort.Strings(ns)
+	return strings.Join(ns, ", ")
+}
+
+// CompareResponses reports the registrar, date, status and name server
+// values that differ from one response to another. Dates are compared as
+// instants when both sides parsed, lists as case-insensitive sets.
+func CompareResponses(from, to *WhoisResponse) []FieldChange {
+	var changes []FieldChange
+	compare := func(field, f, t string, equal bool) {
+		if !equal {
+			changes = append(changes, FieldChange{field, f, t})
+		}
+	}
+	compare("registrar", from.Registrar, to.Registrar,
+		strings.EqualFold(strings.TrimSpace(from.Registrar), strings.TrimSpace(to.Registrar)))
+	for _, d := range []struct {
+		field  string
+		fs, ts string
+		ft, tt time.Time
+	}{
+		{"creation_date", from.CreationDate, to.CreationDate, from.CreationTime, to.CreationTime},
+		{"expiration_date", from.ExpirationDate, to.ExpirationDate, from.ExpirationTime, to.ExpirationTime},
+		{"updated_date", from.UpdatedDate, to.UpdatedDate, from.UpdatedTime, to.UpdatedTime},
+	} {
+		equal := d.fs == d.ts
+		if !d.ft.IsZero() && !d.tt.IsZero() {
+			equal = d.ft.Equal(d.tt)
+		}
+		compare(d.field, d.fs, d.ts, equal)
+	}
+	fs, ts := normalizedSet(from.Statuses), normalizedSet(to.Statuses)
+	compare("statuses", fs, ts, fs == ts)
+	fs, ts = normalizedSet(from.NameServers), normalizedSet(to.NameServers)
+	compare("name_servers", fs, ts, fs == ts)
+	return changes
+}
+
+// CrossCheckContext looks domainName up over both whois and RDAP and returns
+// the whois response with Discrepancies listing where RDAP disagrees (Old
+// holding the whois value, New the RDAP one). The two are not merged.
+func CrossCheckContext(ctx context.Context, domainName string) (*WhoisResponse, error) {
+	wir, err := WhoisContext(ctx, domainName)
+	if err != nil {
+		return nil, err
+	}
+	rir, err := RDAPContext(ctx, domainName)
+	if err != nil {
+		return nil, fmt.Errorf("CrossCheck: %w", err)
+	}
+	wir.Discrepancies = CompareResponses(wir, rir)
+	return wir, nil
+}
+
+func CrossCheck(domainName string) (*WhoisResponse, error) {
+	return CrossCheckContext(context.Background(), domainName)
+}
@@ -0,0 +1,37 @@
+package qwis
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/pkorotkov/qwis/internal/whoistest"
+)
+
+func TestCrossCheck(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{
+		"whois.verisign-grs.com:43": "Domain Name: EXAMPLE.COM\r\n" +
+			"Registrar: Whois Registrar, Inc.\r\n" +
+			"Creation Date: 1995-08-14T04:00:00Z\r\n" +
+			"Domain Status: clientTransferProhibited https://icann.org/epp#clientTransferProhibited\r\n",
+	}}
+	useDial(t, fs.Dial)
+	FollowReferrals = false
+	t.Cleanup(func() { FollowReferrals = true })
+	useRDAPServer(t, "com", map[string]string{
+		"/rdap/domain/example.com": `{"ldhName":"EXAMPLE.COM","status":["client transfer prohibited"],` +
+			`"events":[{"eventAction":"registration","eventDate":"1995-08-14T04:00:00Z"}],` +
+			`"entities":[{"roles":["registrar"],"vcardArray":["vcard",[["fn",{},"text","RDAP Registrar, Inc."]]]}]}`,
+	})
+	wir, err := CrossCheckContext(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FieldChange{{"registrar", "Whois Registrar, Inc.", "RDAP Registrar, Inc."}}
+	if !reflect.DeepEqual(wir.Discrepancies, want) {
+		t.Errorf("Discrepancies = %+v, want %+v", wir.Discrepancies, want)
+	}
+	if wir.Registrar != "Whois Registrar, Inc." {
+		t.Errorf("Registrar = %q; RDAP data must not be merged", wir.Registrar)
+	}
+}
@@ -0,0 +1,109 @@
+package qwis
+
+import (
+	"strings"
+	"time"
+)
+
+var KeepRawDates = false
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05-07",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"02-Jan-2006 15:04:05 MST",
+	"02-Jan-2006",
+	"2-Jan-2006",
+	"02 Jan 2006",
+	"January 2 2006",
+	"Mon Jan 2 15:04:05 MST 2006",
+	"2006.01.02 15:04:05",
+	"2006.01.02",
+	"02.01.2006 15:04:05",
+	"02.01.2006",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+	"20060102",
+}
+
+var tldDateLayouts = map[string][]string{
+	"jp": {"2006/01/02 15:04:05 (MST)", "2006/01/02"},
+	"kr": {"2006. 01. 02."},
+	"br": {"20060102"},
+	"fr": {"02/01/2006"},
+	"pt": {"02/01/2006 15:04:05", "02/01/2006"},
+	"at": {"20060102 15:04:05"},
+	"be": {"Mon Jan 2 2006"},
+	"cn": {"2006-01-02 15:04:05"},
+}
+
+// tldLocations holds the zone of registries whose own date layouts are in
+// local time rather than UTC.
+var tldLocations = map[string]*time.Location{
+	"jp": time.FixedZone("JST", 9*60*60),
+	"cn": time.FixedZone("CST", 8*60*60),
+}
+
+func ParseDate(s, tld string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	// .br and a few others append a ticket reference ("19960101 #1234").
+	if i := strings.Index(s, " #"); i > 0 {
+		s = s[:i]
+	}
+	if len(s) == 0 {
+		return time.Time{}, false
+	}
+	tld = strings.ToLower(tld)
+	loc := tldLocations[tld]
+	if loc == nil {
+		loc = time.UTC
+	}
+	for _, l := range tldDateLayouts[tld] {
+		if t, err := time.ParseInLocation(l, s, loc); err == nil {
+			return t.UTC(), true
+		}
+	}
+	for _, l := range dateLayouts {
+		if t, err := time.Parse(l, s); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (wir *WhoisResponse) normalizeDates(tld string) {
+	for _, d := range []struct {
+		s *string
+		t *time.Time
+	}{
+		{&wir.CreationDate, &wir.CreationTime},
+		{&wir.ExpirationDate, &wir.ExpirationTime},
+		{&wir.UpdatedDate, &wir.UpdatedTime},
+		{&wir.PendingDeleteDate, &wir.PendingDeleteTime},
+	} {
+		t, ok := ParseDate(*d.s, tld)
+		if !ok {
+			continue
+		}
+		*d.t = t
+		if !KeepRawDates {
+			*d.s = t.Format(time.RFC3339)
+		}
+	}
+}
+
+// SetDaysUntilExpiry sets DaysUntilExpiry to the whole days from now to
+// the expiration date, negative once it has passed. It is left nil when the
+// expiration date did not parse.
+func (wir *WhoisResponse) SetDaysUntilExpiry(now time.Time) {
+	if wir.ExpirationTime.IsZero() {
+		return
+	}
+	days := int(wir.ExpirationTime.Sub(now).Hours() / 24)
+	wir.DaysUntilExpiry = &days
+}
@@ -0,0 +1,120 @@
+package qwis
+
+import (
+	"context"
+	"strings"
+)
+
+// Infrastructure is a domain's registration together with those of the
+// domains its name servers are under and of its registrar.
+type Infrastructure struct {
+	Domain      *WhoisResponse     `json:"domain"`
+	NameServers []NameServerDomain `json:"name_servers,omitempty"`
+	Registrar   *RegistrarInfo     `json:"registrar,omitempty"`
+}
+
+// NameServerDomain is the registration of the domain some of the name
+// servers are under. It is not looked up again for name servers in
+// bailiwick, those under the domain itself.
+type NameServerDomain struct {
+	Domain      string         `json:"domain"`
+	Hosts       []string       `json:"hosts"`
+	InBailiwick bool           `json:"in_bailiwick,omitempty"`
+	Response    *WhoisResponse `json:"response,omitempty"`
+	Error       string         `json:"error,omitempty"`
+}
+
+// RegistrarInfo is the registrar of a domain with its abuse contacts and
+// the registration of its own domain, taken from the abuse email address or
+// else the registrar's whois server.
+type RegistrarInfo struct {
+	Name        string         `json:"name,omitempty"`
+	IANAID      string         `json:"iana_id,omitempty"`
+	WhoisServer string         `json:"whois_server,omitempty"`
+	AbuseEmail  string         `json:"abuse_email,omitempty"`
+	AbusePhone  string         `json:"abuse_phone,omitempty"`
+	Domain      string         `json:"domain,omitempty"`
+	Response    *WhoisResponse `json:"response,omitempty"`
+	Error       string         `json:"error,omitempty"`
+}
+
+// DeepLookup looks domainName up with lookup and then, with up to
+// concurrency lookups at a time, the domains of its name servers and of its
+// registrar. Only the first lookup failing fails DeepLookup; the others
+// leave their error in the result.
+func DeepLookup(ctx context.Context, domainName string, concurrency int, lookup LookupFunc) (*Infrastructure, error) {
+	wir, err := lookup(ctx, domainName)
+	if err != nil {
+		return nil, err
+	}
+	inf := &Infrastructure{Domain: wir}
+	own, _ := RegistrableDomain(domainName)
+	var queries []string
+	queried := map[string]bool{}
+	query := func(dn string) {
+		if len(dn) != 0 && dn != own && !queried[dn] {
+			queried[dn] = true
+			queries = append(queries, dn)
+		}
+	}
+	index := map[string]int{}
+	for _, ns := range wir.NameServers {
+		dn, err := RegistrableDomain(ns)
+		if err != nil {
+			continue
+		}
+		i, ok := index[dn]
+		if !ok {
+			i = len(inf.NameServers)
+			index[dn] = i
+			inf.NameServers = append(inf.NameServers, NameServerDomain{Domain: dn, InBailiwick: dn == own})
+			query(dn)
+		}
+		inf.NameServers[i].Hosts = append(inf.NameServers[i].Hosts, ns)
+	}
+	if r := registrarInfo(wir); r != nil {
+		inf.Registrar = r
+		query(r.Domain)
+	}
+	results := map[string]BatchResult{}
+	for _, r := range BatchLookup(ctx, queries, concurrency, lookup) {
+		results[r.Domain] = r
+	}
+	errText := func(err error) string {
+		if err != nil {
+			return err.Error()
+		}
+		return ""
+	}
+	for i := range inf.NameServers {
+		if r, ok := results[inf.NameServers[i].Domain]; ok {
+			inf.NameServers[i].Response, inf.NameServers[i].Error = r.Response, errText(r.Err)
+		}
+	}
+	if inf.Registrar != nil {
+		if r, ok := results[inf.Registrar.Domain]; ok {
+			inf.Registrar.Response, inf.Registrar.Error = r.Response, errText(r.Err)
+		}
+	}
+	return inf, nil
+}
+
+func registrarInfo(wir *WhoisResponse) *RegistrarInfo {
+	r := &RegistrarInfo{
+		Name:        wir.Registrar,
+		IANAID:      wir.RegistrarIANAID,
+		WhoisServer: wir.RegistrarWhoisServer,
+		AbuseEmail:  wir.AbuseEmail,
+		AbusePhone:  wir.AbusePhone,
+	}
+	if *r == (RegistrarInfo{}) {
+		return nil
+	}
+	for _, host := range []string{r.AbuseEmail[strings.LastIndexByte(r.AbuseEmail, '@')+1:], serverHost(referralAddress(r.WhoisServer))} {
+		if dn, err := RegistrableDomain(host); len(host) != 0 && err == nil {
+			r.Domain = dn
+			break
+		}
+	}
+	return r
+}
@@ -0,0 +1,51 @@
+package qwis
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/pkorotkov/qwis/internal/whoistest"
+)
+
+func TestDeepLookup(t *testing.T) {
+	srv, err := whoistest.NewServer(map[string][]byte{
+		"example.com": []byte("Domain Name: EXAMPLE.COM\r\n" +
+			"Registrar: Example Registrar, Inc.\r\n" +
+			"Registrar IANA ID: 9999\r\n" +
+			"Registrar Abuse Contact Email: abuse@mail.registrar.org\r\n" +
+			"Name Server: NS1.EXAMPLE.COM\r\n" +
+			"Name Server: A.IANA-SERVERS.NET\r\n" +
+			"Name Server: B.IANA-SERVERS.NET\r\n"),
+		"iana-servers.net": []byte("Domain Name: IANA-SERVERS.NET\r\nRegistrar: IANA Registrar\r\n"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	ResetWhoisServers()
+	defer ResetWhoisServers()
+	c := NewClient(WithDialer(srv.Dial), WithReferralChasing(false))
+	inf, err := DeepLookup(context.Background(), "www.example.com", 2, c.Whois)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inf.Domain.DomainName != "EXAMPLE.COM" || len(inf.NameServers) != 2 {
+		t.Fatalf("DeepLookup = %+v", inf)
+	}
+	if ns := inf.NameServers[0]; ns.Domain != "example.com" || !ns.InBailiwick || ns.Response != nil {
+		t.Errorf("in-bailiwick name servers = %+v", ns)
+	}
+	ns := inf.NameServers[1]
+	if ns.Domain != "iana-servers.net" || !reflect.DeepEqual(ns.Hosts, []string{"a.iana-servers.net", "b.iana-servers.net"}) ||
+		ns.Response == nil || ns.Response.Registrar != "IANA Registrar" {
+		t.Errorf("iana-servers.net name servers = %+v", ns)
+	}
+	r := inf.Registrar
+	if r == nil || r.Name != "Example Registrar, Inc." || r.IANAID != "9999" || r.Domain != "registrar.org" || r.Response != nil || len(r.Error) == 0 {
+		t.Errorf("Registrar = %+v", r)
+	}
+	if _, err = DeepLookup(context.Background(), "missing.com", 2, c.Whois); err == nil {
+		t.Error("DeepLookup of a missing domain succeeded")
+	}
+}
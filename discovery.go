@@ -0,0 +1,222 @@
+package qwis
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed servers.txt
+var embeddedServers []byte
+
+var fallbackWhoisServers = mustParseServerTable(embeddedServers)
+
+var serverOverrides struct {
+	sync.Mutex
+	m map[string]string
+}
+
+var discoveredServers struct {
+	sync.Mutex
+	m map[string]string
+}
+
+// NoWhoisServerTTL is how long a TLD IANA lists without a whois server is
+// remembered as such before IANA is asked again.
+var NoWhoisServerTTL = time.Hour
+
+var noWhoisServer struct {
+	sync.Mutex
+	m map[string]time.Time
+}
+
+func parseServerTable(r io.Reader) (map[string]string, error) {
+	m := map[string]string{}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		l := strings.TrimSpace(sc.Text())
+		if len(l) == 0 || strings.HasPrefix(l, "#") {
+			continue
+		}
+		fs := strings.Fields(l)
+		if len(fs) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"tld server\"", n)
+		}
+		m[strings.ToLower(strings.TrimPrefix(fs[0], "."))] = fs[1]
+	}
+	return m, sc.Err()
+}
+
+func mustParseServerTable(b []byte) map[string]string {
+	m, err := parseServerTable(bytes.NewReader(b))
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// SetWhoisServer overrides the whois server used for a TLD. An empty server
+// removes the override.
+func SetWhoisServer(tld, server string) {
+	tld = strings.ToLower(strings.TrimPrefix(tld, "."))
+	serverOverrides.Lock()
+	defer serverOverrides.Unlock()
+	if len(server) == 0 {
+		delete(serverOverrides.m, tld)
+		return
+	}
+	if serverOverrides.m == nil {
+		serverOverrides.m = map[string]string{}
+	}
+	serverOverrides.m[tld] = server
+}
+
+// ResetWhoisServers drops every override and forgets the servers discovered
+// via IANA so far.
+func ResetWhoisServers() {
+	serverOverrides.Lock()
+	serverOverrides.m = nil
+	serverOverrides.Unlock()
+	discoveredServers.Lock()
+	discoveredServers.m = nil
+	discoveredServers.Unlock()
+	noWhoisServer.Lock()
+	noWhoisServer.m = nil
+	noWhoisServer.Unlock()
+}
+
+// LoadWhoisServers reads "tld server" lines and installs them as overrides.
+func LoadWhoisServers(r io.Reader) error {
+	m, err := parseServerTable(r)
+	if err != nil {
+		return fmt.Errorf("LoadWhoisServers: %s", err)
+	}
+	for tld, server := range m {
+		SetWhoisServer(tld, server)
+	}
+	return nil
+}
+
+type ServerMapping struct {
+	TLD    string `json:"tld"`
+	Server string `json:"server"`
+	Source string `json:"source"`
+}
+
+// WhoisServers returns the active TLD to server mapping sorted by TLD:
+// the embedded table, servers discovered via IANA so far and overrides.
+func WhoisServers() []ServerMapping {
+	active := map[string]ServerMapping{}
+	for tld, server := range fallbackWhoisServers {
+		active[tld] = ServerMapping{tld, server, "embedded"}
+	}
+	discoveredServers.Lock()
+	for tld, server := range discoveredServers.m {
+		active[tld] = ServerMapping{tld, server, "iana"}
+	}
+	discoveredServers.Unlock()
+	serverOverrides.Lock()
+	for tld, server := range serverOverrides.m {
+		active[tld] = ServerMapping{tld, server, "override"}
+	}
+	serverOverrides.Unlock()
+	ms := make([]ServerMapping, 0, len(active))
+	for _, m := range active {
+		ms = append(ms, m)
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].TLD < ms[j].TLD })
+	return ms
+}
+
+func ianaWhoisServer(ctx context.Context, tld string) (string, error) {
+	res, err := queryAndRead(ctx, referralAddress(IANAWhoisServer), append([]byte(tld), crlf...))
+	if err != nil {
+		return "", err
+	}
+	var server string
+	keyValues(res, func(k, v string) {
+		if k == "whois" && len(server) == 0 {
+			server = v
+		}
+	})
+	return server, nil
+}
+
+func knownWithoutWhoisServer(ctx context.Context, tld string) bool {
+	noWhoisServer.Lock()
+	defer noWhoisServer.Unlock()
+	expires, ok := noWhoisServer.m[tld]
+	if ok && clock(ctx)().After(expires) {
+		delete(noWhoisServer.m, tld)
+		return false
+	}
+	return ok
+}
+
+// WhoisServer returns the whois server for a TLD: an override if one is set,
+// otherwise the server published by IANA, falling back to the embedded table
+// when IANA can't be reached or lists none. TLDs without any server fail
+// with ErrNoWhoisServer; IANA isn't asked about them again for
+// NoWhoisServerTTL.
+//
+// A public suffix below a TLD, such as ac.uk, has the server set for it or
+// embedded, or else that of the suffix above it.
+func WhoisServer(ctx context.Context, tld string) (string, error) {
+	tld = strings.ToLower(strings.TrimPrefix(tld, "."))
+	serverOverrides.Lock()
+	server, ok := serverOverrides.m[tld]
+	serverOverrides.Unlock()
+	if ok {
+		return server, nil
+	}
+	if i := strings.IndexByte(tld, '.'); i >= 0 {
+		if server, ok = fallbackWhoisServers[tld]; ok {
+			return server, nil
+		}
+		return WhoisServer(ctx, tld[i+1:])
+	}
+	discoveredServers.Lock()
+	server, ok = discoveredServers.m[tld]
+	discoveredServers.Unlock()
+	if ok {
+		return server, nil
+	}
+	var ianaErr error
+	if !knownWithoutWhoisServer(ctx, tld) {
+		server, err := ianaWhoisServer(ctx, tld)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return "", err
+		case len(server) != 0:
+			discoveredServers.Lock()
+			if discoveredServers.m == nil {
+				discoveredServers.m = map[string]string{}
+			}
+			discoveredServers.m[tld] = server
+			discoveredServers.Unlock()
+			return server, nil
+		case err == nil:
+			noWhoisServer.Lock()
+			if noWhoisServer.m == nil {
+				noWhoisServer.m = map[string]time.Time{}
+			}
+			noWhoisServer.m[tld] = clock(ctx)().Add(NoWhoisServerTTL)
+			noWhoisServer.Unlock()
+		}
+		ianaErr = err
+	}
+	if server, ok = fallbackWhoisServers[tld]; ok {
+		return server, nil
+	}
+	if ianaErr != nil {
+		return "", ianaErr
+	}
+	return "", fmt.Errorf("WhoisServer: %w for .%s", ErrNoWhoisServer, tld)
+}
@@ -0,0 +1,101 @@
+package qwis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkorotkov/qwis/internal/whoistest"
+)
+
+func TestWhoisServerNegativeCache(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{
+		"whois.iana.org:43": "domain:       ZZ\r\nstatus:       ACTIVE\r\n",
+	}}
+	useDial(t, fs.Dial)
+	for i := 0; i < 3; i++ {
+		_, err := WhoisServer(context.Background(), "zz")
+		if !errors.Is(err, ErrNoWhoisServer) || !errors.Is(err, ErrUnsupportedTLD) {
+			t.Fatalf("WhoisServer(zz) error = %v, want ErrNoWhoisServer", err)
+		}
+	}
+	if len(fs.Dialed) != 1 {
+		t.Errorf("IANA queried %d times, want once", len(fs.Dialed))
+	}
+	noWhoisServer.m["zz"] = time.Now().Add(-time.Second)
+	WhoisServer(context.Background(), "zz")
+	if len(fs.Dialed) != 2 {
+		t.Errorf("IANA queried %d times after the entry expired, want twice", len(fs.Dialed))
+	}
+}
+
+func TestWhoisServerSources(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{
+		"whois.iana.org:43": "domain:       COM\r\nwhois:        whois.iana-listed.test\r\n",
+	}}
+	useDial(t, fs.Dial)
+	if server, err := WhoisServer(context.Background(), "com"); err != nil || server != "whois.iana-listed.test" {
+		t.Fatalf("WhoisServer(com) = %q, %v", server, err)
+	}
+	// IANA lists no whois server for .de here; the embedded entry answers
+	// and stays labeled as such.
+	fs.Responses["whois.iana.org:43"] = "domain:       DE\r\n"
+	if server, err := WhoisServer(context.Background(), "de"); err != nil || server != "whois.denic.de" {
+		t.Fatalf("WhoisServer(de) = %q, %v", server, err)
+	}
+	sources := map[string]string{}
+	for _, m := range WhoisServers() {
+		sources[m.TLD] = m.Source
+	}
+	if sources["com"] != "iana" || sources["de"] != "embedded" {
+		t.Errorf("sources = com:%s de:%s, want com:iana de:embedded", sources["com"], sources["de"])
+	}
+}
+
+func TestEffectiveTLD(t *testing.T) {
+	for dn, want := range map[string]string{
+		"www.example.co.uk": "co.uk",
+		"example.com.au":    "com.au",
+		"Example.COM.":      "com",
+		"user.github.io":    "io",
+		"example.zzz":       "zzz",
+	} {
+		if got := EffectiveTLD(dn); got != want {
+			t.Errorf("EffectiveTLD(%q) = %q, want %q", dn, got, want)
+		}
+	}
+}
+
+func TestWhoisServerPublicSuffix(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{
+		"whois.iana.org:43": "domain:       UK\r\nwhois:        whois.nic.uk\r\n",
+		"whois.nic.uk:43":   "Domain name:\r\n    example.co.uk\r\n",
+		"whois.ja.net:43":   "Domain:\r\n    example.ac.uk\r\n",
+	}}
+	useDial(t, fs.Dial)
+	ctx := context.Background()
+	for dn, want := range map[string]string{
+		"example.co.uk":     "whois.nic.uk:43",
+		"www.example.ac.uk": "whois.ja.net:43",
+	} {
+		fs.Dialed = nil
+		if _, err := WhoisRawContext(ctx, dn); err != nil || fs.Dialed[len(fs.Dialed)-1] != want {
+			t.Errorf("%s: %v after dialing %q, want %s", dn, err, fs.Dialed, want)
+		}
+	}
+	SetWhoisServer("co.uk", "whois.co-uk.test")
+	if server, err := WhoisServer(ctx, "co.uk"); err != nil || server != "whois.co-uk.test" {
+		t.Errorf("WhoisServer(co.uk) with an override = %q, %v", server, err)
+	}
+	if _, err := WhoisRawContext(ctx, "co.uk"); err == nil || !strings.Contains(err.Error(), "public suffix") {
+		t.Errorf("WhoisRawContext(co.uk) error = %v", err)
+	}
+	if got := failoverServers("ac.uk", "whois.ja.net:43"); len(got) != 1 {
+		t.Errorf("failoverServers(ac.uk) = %q", got)
+	}
+	if got := failoverServers("co.uk", "whois.co-uk.test:43"); len(got) != 3 || got[2] != "uk.whois-servers.net" {
+		t.Errorf("failoverServers(co.uk) = %q", got)
+	}
+}
@@ -0,0 +1,114 @@
+package qwis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// DNSResolver answers the DNS queries of LookupDNS; *net.Resolver is one.
+type DNSResolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+	LookupNS(ctx context.Context, name string) ([]*net.NS, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+}
+
+// Resolver is the DNSResolver of LookupDNS.
+var Resolver DNSResolver = net.DefaultResolver
+
+// DNSRecords are the live DNS records of a domain. NSOnlyInWhois and
+// NSOnlyInDNS list the name servers the registration and the NS records
+// disagree about.
+type DNSRecords struct {
+	A             []string `json:"a,omitempty"`
+	AAAA          []string `json:"aaaa,omitempty"`
+	NS            []string `json:"ns,omitempty"`
+	MX            []string `json:"mx,omitempty"`
+	NSOnlyInWhois []string `json:"ns_only_in_whois,omitempty"`
+	NSOnlyInDNS   []string `json:"ns_only_in_dns,omitempty"`
+}
+
+func dnsName(s string) string {
+	return strings.ToLower(strings.TrimSuffix(s, "."))
+}
+
+// LookupDNS resolves the A, AAAA, NS and MX records of domainName. Record
+// types the domain has none of are left empty; other failures are joined
+// in the error, with the records that could be resolved returned anyway.
+func LookupDNS(ctx context.Context, domainName string) (*DNSRecords, error) {
+	var (
+		rec  DNSRecords
+		errs []error
+	)
+	failed := func(what string, err error) bool {
+		var de *net.DNSError
+		if err != nil && !(errors.As(err, &de) && de.IsNotFound) {
+			errs = append(errs, fmt.Errorf("%s: %w", what, err))
+		}
+		return err != nil
+	}
+	ips, err := Resolver.LookupNetIP(ctx, "ip", domainName)
+	if !failed("A/AAAA", err) {
+		for _, ip := range ips {
+			if ip = ip.Unmap(); ip.Is4() {
+				rec.A = append(rec.A, ip.String())
+			} else {
+				rec.AAAA = append(rec.AAAA, ip.String())
+			}
+		}
+	}
+	nss, err := Resolver.LookupNS(ctx, domainName)
+	if !failed("NS", err) {
+		for _, ns := range nss {
+			rec.NS = append(rec.NS, dnsName(ns.Host))
+		}
+		sort.Strings(rec.NS)
+	}
+	mxs, err := Resolver.LookupMX(ctx, domainName)
+	if !failed("MX", err) {
+		for _, mx := range mxs {
+			rec.MX = append(rec.MX, fmt.Sprintf("%d %s", mx.Pref, dnsName(mx.Host)))
+		}
+	}
+	if len(errs) != 0 {
+		return &rec, fmt.Errorf("LookupDNS: %w", errors.Join(errs...))
+	}
+	return &rec, nil
+}
+
+// CheckDNS sets wir.DNS to the records of domainName and compares its NS
+// records with the name servers of the registration, warning when they
+// differ.
+func (wir *WhoisResponse) CheckDNS(ctx context.Context, domainName string) {
+	rec, err := LookupDNS(ctx, domainName)
+	wir.DNS = rec
+	if err != nil {
+		wir.Warnings = append(wir.Warnings, err.Error())
+	}
+	if len(wir.NameServers) == 0 || len(rec.NS) == 0 && err != nil {
+		return
+	}
+	inDNS := map[string]bool{}
+	for _, ns := range rec.NS {
+		inDNS[ns] = true
+	}
+	inWhois := map[string]bool{}
+	for _, ns := range wir.NameServers {
+		ns = dnsName(ns)
+		if inWhois[ns] = true; !inDNS[ns] {
+			rec.NSOnlyInWhois = append(rec.NSOnlyInWhois, ns)
+		}
+	}
+	for _, ns := range rec.NS {
+		if !inWhois[ns] {
+			rec.NSOnlyInDNS = append(rec.NSOnlyInDNS, ns)
+		}
+	}
+	if len(rec.NSOnlyInWhois) != 0 || len(rec.NSOnlyInDNS) != 0 {
+		wir.Warnings = append(wir.Warnings, "name servers in whois and DNS differ")
+	}
+}
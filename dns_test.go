@@ -0,0 +1,95 @@
+package qwis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeResolver answers from its maps and fails names it has no entry for
+// as not found.
+type fakeResolver struct {
+	ips map[string][]netip.Addr
+	ns  map[string][]*net.NS
+	mx  map[string][]*net.MX
+	err error
+}
+
+func (r fakeResolver) notFound(name string) error {
+	if r.err != nil {
+		return r.err
+	}
+	return &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r fakeResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	if ips, ok := r.ips[host]; ok {
+		return ips, nil
+	}
+	return nil, r.notFound(host)
+}
+
+func (r fakeResolver) LookupNS(ctx context.Context, name string) ([]*net.NS, error) {
+	if ns, ok := r.ns[name]; ok {
+		return ns, nil
+	}
+	return nil, r.notFound(name)
+}
+
+func (r fakeResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	if mx, ok := r.mx[name]; ok {
+		return mx, nil
+	}
+	return nil, r.notFound(name)
+}
+
+// useResolver has LookupDNS ask r for the duration of the test.
+func useResolver(t *testing.T, r DNSResolver) {
+	t.Helper()
+	old := Resolver
+	Resolver = r
+	t.Cleanup(func() { Resolver = old })
+}
+
+func TestCheckDNS(t *testing.T) {
+	useResolver(t, fakeResolver{
+		ips: map[string][]netip.Addr{"example.com": {netip.MustParseAddr("::ffff:192.0.2.1"), netip.MustParseAddr("2001:db8::1")}},
+		ns:  map[string][]*net.NS{"example.com": {{Host: "B.IANA-SERVERS.NET."}, {Host: "ns.other.test."}}},
+	})
+	wir := &WhoisResponse{NameServers: []string{"a.iana-servers.net", "b.iana-servers.net"}}
+	wir.CheckDNS(context.Background(), "example.com")
+	want := &DNSRecords{
+		A:             []string{"192.0.2.1"},
+		AAAA:          []string{"2001:db8::1"},
+		NS:            []string{"b.iana-servers.net", "ns.other.test"},
+		NSOnlyInWhois: []string{"a.iana-servers.net"},
+		NSOnlyInDNS:   []string{"ns.other.test"},
+	}
+	if !reflect.DeepEqual(wir.DNS, want) || !reflect.DeepEqual(wir.Warnings, []string{"name servers in whois and DNS differ"}) {
+		t.Errorf("DNS = %+v, warnings %q", wir.DNS, wir.Warnings)
+	}
+
+	wir = &WhoisResponse{NameServers: []string{"b.iana-servers.net", "ns.other.test"}}
+	if wir.CheckDNS(context.Background(), "example.com"); len(wir.Warnings) != 0 {
+		t.Errorf("matching name servers warned %q", wir.Warnings)
+	}
+}
+
+func TestLookupDNSFailure(t *testing.T) {
+	useResolver(t, fakeResolver{
+		mx:  map[string][]*net.MX{"example.com": {{Host: "MX.Example.com.", Pref: 10}}},
+		err: errors.New("i/o timeout"),
+	})
+	rec, err := LookupDNS(context.Background(), "example.com")
+	if err == nil || !strings.Contains(err.Error(), "NS: i/o timeout") || !reflect.DeepEqual(rec.MX, []string{"10 mx.example.com"}) {
+		t.Errorf("LookupDNS = %+v, %v", rec, err)
+	}
+	wir := &WhoisResponse{NameServers: []string{"a.iana-servers.net"}}
+	if wir.CheckDNS(context.Background(), "example.com"); len(wir.DNS.NSOnlyInWhois) != 0 || len(wir.Warnings) != 1 {
+		t.Errorf("failed lookup: DNS = %+v, warnings %q", wir.DNS, wir.Warnings)
+	}
+}
@@ -0,0 +1,244 @@
+package qwis
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Encoder writes a response in one output format.
+type Encoder interface {
+	Encode(w io.Writer, wir *WhoisResponse) error
+}
+
+// EncoderFunc adapts a function to the Encoder interface.
+type EncoderFunc func(w io.Writer, wir *WhoisResponse) error
+
+func (f EncoderFunc) Encode(w io.Writer, wir *WhoisResponse) error {
+	return f(w, wir)
+}
+
+// Encoders are the output formats WriteAs knows by name; add to it to
+// support another one.
+var Encoders = map[string]Encoder{
+	"json": EncoderFunc(func(w io.Writer, wir *WhoisResponse) error { return wir.WriteAsJSON(w) }),
+	"yaml": EncoderFunc(func(w io.Writer, wir *WhoisResponse) error { return wir.WriteAsYAML(w) }),
+	"xml":  EncoderFunc(func(w io.Writer, wir *WhoisResponse) error { return wir.WriteAsXML(w) }),
+}
+
+// WriteAs writes wir with the encoder registered for format.
+func (wir *WhoisResponse) WriteAs(w io.Writer, format string) error {
+	e, ok := Encoders[format]
+	if !ok {
+		return fmt.Errorf("WriteAs: unknown format %q", format)
+	}
+	return e.Encode(w, wir)
+}
+
+// WriteAsYAML writes wir as a YAML document with the same keys as its JSON.
+func (wir *WhoisResponse) WriteAsYAML(w io.Writer) error {
+	v, err := orderedJSON(wir)
+	if err != nil {
+		return fmt.Errorf("WriteAsYAML: %w", err)
+	}
+	var b bytes.Buffer
+	b.WriteString("---\n")
+	writeYAML(&b, v, 0)
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+// WriteAsXML writes wir as a <whois_response> element whose children are
+// named after its JSON keys; list values repeat an <item> element.
+func (wir *WhoisResponse) WriteAsXML(w io.Writer) error {
+	v, err := orderedJSON(wir)
+	if err != nil {
+		return fmt.Errorf("WriteAsXML: %w", err)
+	}
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	writeXML(&b, "whois_response", v, 0)
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+// object is a JSON object that keeps its keys in the order they came in.
+type object struct {
+	keys   []string
+	values []interface{}
+}
+
+// orderedJSON round-trips v through its JSON form so that other encoders
+// share its field names, omissions and custom marshalers.
+func orderedJSON(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return decodeOrdered(dec)
+}
+
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	t, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case json.Delim('{'):
+		o := &object{}
+		for dec.More() {
+			k, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			o.keys, o.values = append(o.keys, k.(string)), append(o.values, v)
+		}
+		_, err = dec.Token()
+		return o, err
+	case json.Delim('['):
+		a := []interface{}{}
+		for dec.More() {
+			v, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		_, err = dec.Token()
+		return a, err
+	}
+	return t, nil
+}
+
+func writeYAML(b *bytes.Buffer, v interface{}, indent int) {
+	pad := strings.Repeat(" ", indent)
+	switch v := v.(type) {
+	case *object:
+		for i, k := range v.keys {
+			b.WriteString(pad + yamlScalar(k) + ":")
+			writeYAMLValue(b, v.values[i], indent)
+		}
+	case []interface{}:
+		for _, e := range v {
+			if o, ok := e.(*object); ok && len(o.keys) != 0 {
+				// The first key of an object in a list shares the "- " line.
+				var item bytes.Buffer
+				writeYAML(&item, o, indent+2)
+				b.WriteString(pad + "- " + item.String()[indent+2:])
+				continue
+			}
+			b.WriteString(pad + "-")
+			writeYAMLValue(b, e, indent)
+		}
+	}
+}
+
+func writeYAMLValue(b *bytes.Buffer, v interface{}, indent int) {
+	switch e := v.(type) {
+	case *object:
+		if len(e.keys) == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+		b.WriteString("\n")
+		writeYAML(b, e, indent+2)
+	case []interface{}:
+		if len(e) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+		b.WriteString("\n")
+		writeYAML(b, e, indent+2)
+	default:
+		b.WriteString(" " + yamlScalar(e) + "\n")
+	}
+}
+
+// yamlScalar writes strings plain unless YAML would read them as anything
+// other than the same string, and double-quotes them otherwise.
+func yamlScalar(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	case string:
+		if plainYAML(v) {
+			return v
+		}
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
+
+func plainYAML(s string) bool {
+	if len(s) == 0 || strings.TrimSpace(s) != s || strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`~") {
+		return false
+	}
+	switch strings.ToLower(s) {
+	case "null", "true", "false", "yes", "no", "on", "off", "y", "n":
+		return false
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return false
+	}
+	for _, r := range s {
+		if r < ' ' || r == ':' || r == '#' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func writeXML(b *bytes.Buffer, name string, v interface{}, indent int) {
+	pad := strings.Repeat("  ", indent)
+	switch v := v.(type) {
+	case *object:
+		b.WriteString(pad + "<" + name + ">\n")
+		for i, k := range v.keys {
+			writeXML(b, xmlName(k), v.values[i], indent+1)
+		}
+		b.WriteString(pad + "</" + name + ">\n")
+	case []interface{}:
+		b.WriteString(pad + "<" + name + ">\n")
+		for _, e := range v {
+			writeXML(b, "item", e, indent+1)
+		}
+		b.WriteString(pad + "</" + name + ">\n")
+	case nil:
+		b.WriteString(pad + "<" + name + "/>\n")
+	default:
+		b.WriteString(pad + "<" + name + ">")
+		xml.EscapeText(b, []byte(fmt.Sprint(v)))
+		b.WriteString("</" + name + ">\n")
+	}
+}
+
+// xmlName makes a JSON key, such as one of FieldSources, usable
+// as an element name.
+func xmlName(k string) string {
+	n := []byte(k)
+	for i, c := range n {
+		ok := c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && (c == '-' || c == '.' || c >= '0' && c <= '9')
+		if !ok {
+			n[i] = '_'
+		}
+	}
+	if len(n) == 0 {
+		return "_"
+	}
+	return string(n)
+}
@@ -0,0 +1,67 @@
+package qwis
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWriteAsYAML(t *testing.T) {
+	wir := &WhoisResponse{
+		DomainName:    "example.com",
+		Registrar:     "Example: Registrar",
+		Statuses:      []string{"clientTransferProhibited", "true"},
+		Discrepancies: []FieldChange{{"registrar", "A", "B"}},
+	}
+	var out bytes.Buffer
+	if err := wir.WriteAs(&out, "yaml"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"---\ndomain_name: example.com\n",
+		"registrar: \"Example: Registrar\"\n",
+		"statuses:\n  - clientTransferProhibited\n  - \"true\"\n",
+		"discrepancies:\n  - field: registrar\n    old: A\n    new: B\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestWriteAsXML(t *testing.T) {
+	wir := &WhoisResponse{DomainName: "example.com", Registrar: "A & B", NameServers: []string{"ns1.example.com", "ns2.example.com"}}
+	var out bytes.Buffer
+	if err := wir.WriteAs(&out, "xml"); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		XMLName     xml.Name `xml:"whois_response"`
+		DomainName  string   `xml:"domain_name"`
+		Registrar   string   `xml:"registrar"`
+		NameServers []string `xml:"name_servers>item"`
+	}
+	if err := xml.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatalf("%v:\n%s", err, out.String())
+	}
+	if doc.DomainName != "example.com" || doc.Registrar != "A & B" || len(doc.NameServers) != 2 {
+		t.Errorf("decoded %+v from:\n%s", doc, out.String())
+	}
+}
+
+func TestEncoders(t *testing.T) {
+	Encoders["domain"] = EncoderFunc(func(w io.Writer, wir *WhoisResponse) error {
+		_, err := io.WriteString(w, wir.DomainName)
+		return err
+	})
+	defer delete(Encoders, "domain")
+	var out bytes.Buffer
+	if err := (&WhoisResponse{DomainName: "example.com"}).WriteAs(&out, "domain"); err != nil || out.String() != "example.com" {
+		t.Errorf("WriteAs = %q, %v", out.String(), err)
+	}
+	if err := (&WhoisResponse{}).WriteAs(&out, "toml"); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
@@ -0,0 +1,60 @@
+package qwis
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNoSuchDomain      = errors.New("no such domain")
+	ErrServerUnavailable = errors.New("server unavailable")
+	ErrRateLimited       = errors.New("rate limited")
+	ErrUnsupportedTLD    = errors.New("unsupported TLD")
+	ErrParse             = errors.New("malformed response")
+	ErrInvalidDomainName = errors.New("invalid domain name")
+	ErrResponseTooLarge  = errors.New("response too large")
+
+	// ErrNoWhoisServer is returned for TLDs IANA lists without a whois
+	// server; it wraps ErrUnsupportedTLD.
+	ErrNoWhoisServer = fmt.Errorf("%w: no whois server", ErrUnsupportedTLD)
+)
+
+// ServerError is a failure attributable to the server that was queried.
+type ServerError struct {
+	Server string
+	Err    error
+}
+
+func (e *ServerError) Error() string {
+	return e.Server + ": " + e.Err.Error()
+}
+
+func (e *ServerError) Unwrap() error {
+	return e.Err
+}
+
+var rateLimitPhrases = [][]byte{
+	[]byte("rate limit exceeded"),
+	[]byte("query rate limit"),
+	[]byte("too many requests"),
+	[]byte("quota exceeded"),
+	[]byte("exceeded the maximum allowable number"),
+	[]byte("queries exceeded"),
+	[]byte("queried interval is too short"),
+	[]byte("%error:201: access denied"),
+}
+
+func isRateLimited(res []byte) bool {
+	// Refusals are short; a full record may mention limits in its legalese.
+	if len(res) > 2048 {
+		return false
+	}
+	res = bytes.ToLower(res)
+	for _, p := range rateLimitPhrases {
+		if bytes.Contains(res, p) {
+			return true
+		}
+	}
+	return false
+}
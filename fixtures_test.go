@@ -0,0 +1,105 @@
+package qwis
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/pkorotkov/qwis/internal/whoistest"
+)
+
+var (
+	recordFixtures = flag.Bool("record", false, "refresh testdata/whois/*.txt from the live registries, then the .json files")
+	updateFixtures = flag.Bool("update", false, "rewrite testdata/whois/*.json from the .txt files")
+)
+
+// fixtureDomain is the domain name a recorded answer is for.
+func fixtureDomain(t *testing.T, raw []byte, tld string) string {
+	t.Helper()
+	wir, err := ParseResponseWithTLD(raw, tld)
+	if err != nil || len(wir.DomainName) == 0 {
+		t.Fatalf("%s.txt names no domain: %v", tld, err)
+	}
+	return wir.DomainName
+}
+
+// recordWhoisFixtures replaces every testdata/whois/<tld>.txt with the
+// live registry's current answer for the domain it is about. A new TLD is
+// added with a file holding just "Domain Name: example.<tld>".
+func recordWhoisFixtures(t *testing.T, dir string, responses map[string][]byte) {
+	for tld, raw := range responses {
+		dn := fixtureDomain(t, raw, tld)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		res, err := WhoisRawContext(ctx, dn)
+		cancel()
+		if err != nil {
+			t.Errorf("%s: %v", dn, err)
+			continue
+		}
+		if err = os.WriteFile(filepath.Join(dir, tld+".txt"), res, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		responses[tld] = res
+	}
+}
+
+// TestFixtures looks the domain of every answer in testdata/whois up
+// through a whoistest.Server serving them and compares the responses with
+// the .json file recorded next to each.
+func TestFixtures(t *testing.T) {
+	dir := filepath.Join("testdata", "whois")
+	responses, err := whoistest.LoadFixtures(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *recordFixtures {
+		recordWhoisFixtures(t, dir, responses)
+	}
+	srv, err := whoistest.NewServer(responses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	ResetWhoisServers()
+	defer ResetWhoisServers()
+	c := NewClient(WithDialer(srv.Dial), WithReferralChasing(false))
+	tlds := make([]string, 0, len(responses))
+	for tld := range responses {
+		tlds = append(tlds, tld)
+	}
+	sort.Strings(tlds)
+	for _, tld := range tlds {
+		t.Run(tld, func(t *testing.T) {
+			dn := fixtureDomain(t, responses[tld], tld)
+			wir, err := c.Whois(context.Background(), dn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.MarshalIndent(wir, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+			golden := filepath.Join(dir, tld+".json")
+			if *updateFixtures || *recordFixtures {
+				if err = os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v; run go test -run TestFixtures -update to create it", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("response differs from %s; rerun with -update if that is intended:\n%s", golden, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+module github.com/pkorotkov/qwis
+
+go 1.22
+
+require golang.org/x/net v0.30.0
+
+require (
+	go.etcd.io/bbolt v1.3.11
+	golang.org/x/text v0.19.0
+	google.golang.org/grpc v1.67.1
+	google.golang.org/protobuf v1.34.2
+)
+
+require (
+	golang.org/x/sys v0.26.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240814211410-ddb44dafa142 // indirect
+)
@@ -0,0 +1,32 @@
+package qwis
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// WriteICalendar writes an iCalendar document with an all-day event on the
+// expiration date of every response that has one; responses without a
+// parsed ExpirationTime are left out.
+func WriteICalendar(w io.Writer, responses []*WhoisResponse) error {
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	lines := []string{"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//qwis//qwis//EN"}
+	for _, wir := range responses {
+		if wir.ExpirationTime.IsZero() {
+			continue
+		}
+		dn := strings.ToLower(wir.DomainName)
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:"+dn+"-expiration@qwis",
+			"DTSTAMP:"+stamp,
+			"DTSTART;VALUE=DATE:"+wir.ExpirationTime.UTC().Format("20060102"),
+			"SUMMARY:"+dn+" expires",
+			"END:VEVENT")
+	}
+	lines = append(lines, "END:VCALENDAR")
+	_, err := fmt.Fprint(w, strings.Join(lines, "\r\n")+"\r\n")
+	return err
+}
@@ -0,0 +1,33 @@
+package qwis
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteICalendar(t *testing.T) {
+	wir, err := ParseResponse([]byte(verisignResponse))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err = WriteICalendar(&out, []*WhoisResponse{wir, {DomainName: "undated.com"}}); err != nil {
+		t.Fatal(err)
+	}
+	ics := out.String()
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"BEGIN:VEVENT\r\n",
+		"DTSTART;VALUE=DATE:20280914\r\n",
+		"SUMMARY:google.com expires\r\n",
+		"END:VEVENT\r\nEND:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(ics, want) {
+			t.Errorf("missing %q in:\n%s", want, ics)
+		}
+	}
+	if n := strings.Count(ics, "BEGIN:VEVENT"); n != 1 || strings.Contains(ics, "undated.com") {
+		t.Errorf("got %d events, want only google.com:\n%s", n, ics)
+	}
+}
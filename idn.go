@@ -0,0 +1,45 @@
+package qwis
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// ToASCII converts an internationalized domain name to its punycode (xn--)
+// form. Plain ASCII names are returned unchanged.
+func ToASCII(domainName string) (string, error) {
+	if isASCII(domainName) {
+		return domainName, nil
+	}
+	a, err := idna.Lookup.ToASCII(domainName)
+	if err != nil {
+		return "", fmt.Errorf("ToASCII: %w %q: %v", ErrInvalidDomainName, domainName, err)
+	}
+	return a, nil
+}
+
+func (wir *WhoisResponse) fillDomainForms() {
+	if len(wir.DomainName) == 0 || isASCII(wir.DomainName) && !strings.Contains(strings.ToLower(wir.DomainName), "xn--") {
+		return
+	}
+	a, err := idna.Lookup.ToASCII(wir.DomainName)
+	if err != nil {
+		return
+	}
+	u, err := idna.Lookup.ToUnicode(a)
+	if err != nil {
+		return
+	}
+	wir.ASCIIDomainName, wir.UnicodeDomainName = a, u
+}
@@ -0,0 +1,38 @@
+package whoistest
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+// FakeServers answers queries with the response registered for the dialed
+// address and refuses connections to any other address. It records the
+// addresses dialed.
+type FakeServers struct {
+	sync.Mutex
+	Responses map[string]string
+	Dialed    []string
+}
+
+// Dial is a dial function, such as qwis.Dial, that connects to the fake
+// server at address over a pipe.
+func (fs *FakeServers) Dial(ctx context.Context, network, address string) (net.Conn, error) {
+	fs.Lock()
+	fs.Dialed = append(fs.Dialed, address)
+	resp, ok := fs.Responses[address]
+	fs.Unlock()
+	if !ok {
+		return nil, errors.New("connection refused")
+	}
+	c, s := net.Pipe()
+	go func() {
+		bufio.NewReader(s).ReadString('\n')
+		io.WriteString(s, resp)
+		s.Close()
+	}()
+	return c, nil
+}
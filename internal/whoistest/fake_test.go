@@ -0,0 +1,26 @@
+package whoistest
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestFakeServers(t *testing.T) {
+	fs := &FakeServers{Responses: map[string]string{"whois.example.test:43": "Example\r\n"}}
+	c, err := fs.Dial(context.Background(), "tcp", "whois.example.test:43")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(c, "example.test\r\n")
+	if got, _ := io.ReadAll(c); string(got) != "Example\r\n" {
+		t.Errorf("answer = %q", got)
+	}
+	if _, err = fs.Dial(context.Background(), "tcp", "whois.other.test:43"); err == nil {
+		t.Error("dialed an address without a response")
+	}
+	if want := []string{"whois.example.test:43", "whois.other.test:43"}; !reflect.DeepEqual(fs.Dialed, want) {
+		t.Errorf("Dialed = %q, want %q", fs.Dialed, want)
+	}
+}
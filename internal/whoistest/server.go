@@ -0,0 +1,139 @@
+// Package whoistest provides a whois server on the loopback interface that
+// answers from recorded responses, and FakeServers, which answers over
+// pipes without listening at all, for tests that should not reach the live
+// registries.
+package whoistest
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Server answers each query with the response stored under the queried
+// domain name or, failing that, under the longest suffix of it that has
+// one, such as "co.uk" or "uk". Queries it has no response for are answered
+// with "No match".
+type Server struct {
+	ln        net.Listener
+	responses map[string][]byte
+	wg        sync.WaitGroup
+
+	mu      sync.Mutex
+	queries []string
+}
+
+// NewServer starts a Server with responses, keyed by lowercase domain names
+// or suffixes without a leading dot.
+func NewServer(responses map[string][]byte) (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{ln: ln, responses: responses}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// LoadFixtures reads the files named <suffix>.txt in dir, such as
+// testdata/whois/uk.txt, into responses for NewServer.
+func LoadFixtures(dir string) (map[string][]byte, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return nil, err
+	}
+	responses := map[string][]byte{}
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		responses[strings.TrimSuffix(filepath.Base(p), ".txt")] = b
+	}
+	return responses, nil
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer c.Close()
+			q, err := bufio.NewReader(c).ReadString('\n')
+			if err != nil {
+				return
+			}
+			q = strings.TrimRight(q, "\r\n")
+			s.mu.Lock()
+			s.queries = append(s.queries, q)
+			s.mu.Unlock()
+			if res, ok := s.response(q); ok {
+				c.Write(res)
+			} else {
+				c.Write([]byte("No match for \"" + q + "\".\r\n"))
+			}
+		}()
+	}
+}
+
+// response looks up the domain name in q, the last of its words with a dot,
+// such as "example.de" in "-T dn,ace example.de", "example.jp" in
+// "example.jp/e" or "example.net" in "=example.net".
+func (s *Server) response(q string) ([]byte, bool) {
+	words := strings.Fields(strings.ToLower(q))
+	name := ""
+	for _, w := range words {
+		if strings.Contains(w, ".") {
+			name = strings.TrimPrefix(strings.TrimSuffix(strings.TrimSuffix(w, "/e"), "."), "=")
+		}
+	}
+	if len(name) == 0 && len(words) != 0 {
+		name = words[len(words)-1]
+	}
+	for {
+		if res, ok := s.responses[name]; ok {
+			return res, true
+		}
+		i := strings.IndexByte(name, '.')
+		if i < 0 {
+			return nil, false
+		}
+		name = name[i+1:]
+	}
+}
+
+// Addr is the host:port the server listens on.
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Dial connects to the server whatever address it is given, so that it
+// stands in for every whois server when used as a client's dial function.
+func (s *Server) Dial(ctx context.Context, network, address string) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", s.Addr())
+}
+
+// Queries returns the query lines received so far.
+func (s *Server) Queries() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.queries...)
+}
+
+// Close stops the server and waits for the connections in progress.
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	s.wg.Wait()
+	return err
+}
@@ -0,0 +1,40 @@
+package whoistest
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	s, err := NewServer(map[string][]byte{
+		"uk":            []byte("Nominet\r\n"),
+		"example.co.uk": []byte("Example\r\n"),
+		"de":            []byte("DENIC\r\n"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for _, tc := range []struct{ query, want string }{
+		{"example.co.uk", "Example\r\n"},
+		{"other.co.uk", "Nominet\r\n"},
+		{"-T dn,ace example.de", "DENIC\r\n"},
+		{"=Example.CO.UK", "Example\r\n"},
+		{"example.jp/e", "No match for \"example.jp/e\".\r\n"},
+	} {
+		c, err := s.Dial(context.Background(), "tcp", "whois.example.test:43")
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(c, tc.query+"\r\n")
+		got, err := io.ReadAll(c)
+		c.Close()
+		if err != nil || string(got) != tc.want {
+			t.Errorf("%q answered %q, %v, want %q", tc.query, got, err, tc.want)
+		}
+	}
+	if q := s.Queries(); len(q) != 5 || q[2] != "-T dn,ace example.de" {
+		t.Errorf("Queries() = %q", q)
+	}
+}
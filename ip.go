@@ -0,0 +1,197 @@
+package qwis
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+var IANAWhoisServer = "whois.iana.org"
+
+var rirQueryPrefixes = map[string]string{
+	"whois.arin.net": "n + ",
+}
+
+const maxIPReferrals = 3
+
+type IPWhoisResponse struct {
+	rawText       []byte
+	Query         string   `json:"query"`
+	WhoisServer   string   `json:"whois_server"`
+	NetRange      string   `json:"net_range"`
+	MatchedObject string   `json:"matched_object,omitempty"`
+	CIDR          []string `json:"cidr,omitempty"`
+	NetName       string   `json:"net_name"`
+	OriginAS      []string `json:"origin_as,omitempty"`
+	Organization  string   `json:"organization"`
+	Country       string   `json:"country,omitempty"`
+	AbuseEmail    string   `json:"abuse_email,omitempty"`
+	AbusePhone    string   `json:"abuse_phone,omitempty"`
+}
+
+func IsIPQuery(s string) bool {
+	if _, err := netip.ParseAddr(s); err == nil {
+		return true
+	}
+	_, err := netip.ParsePrefix(s)
+	return err == nil
+}
+
+func keyValues(raw []byte, f func(key, value string)) {
+	var keyBuf []byte
+	for s := (lineScanner{rest: raw}); s.scan(); {
+		l := s.line
+		k, v, ok := bytes.Cut(l, colon)
+		if !ok || bytes.HasPrefix(l, []byte("%")) || bytes.HasPrefix(l, []byte("#")) {
+			continue
+		}
+		if v = bytes.TrimSpace(v); len(v) != 0 {
+			keyBuf = appendLower(keyBuf[:0], bytes.TrimSpace(k))
+			f(string(keyBuf), string(v))
+		}
+	}
+}
+
+func referredServer(raw []byte) string {
+	var server string
+	keyValues(raw, func(k, v string) {
+		if len(server) == 0 && (k == "refer" || k == "referralserver" || k == "whois") {
+			server = v
+		}
+	})
+	if strings.HasPrefix(server, "rwhois://") {
+		return ""
+	}
+	if i := strings.Index(server, "://"); i >= 0 {
+		server = server[i+3:]
+	}
+	return strings.TrimSuffix(server, "/")
+}
+
+func serverHost(server string) string {
+	host, _, err := net.SplitHostPort(server)
+	if err != nil {
+		host = server
+	}
+	return strings.ToLower(host)
+}
+
+func ipQuery(server, q string) []byte {
+	return append([]byte(rirQueryPrefixes[serverHost(server)]+q), crlf...)
+}
+
+func queryWithReferrals(ctx context.Context, q string, query func(server, q string) []byte) ([]byte, string, error) {
+	server := IANAWhoisServer
+	if s := fixedServer(ctx); len(s) != 0 {
+		server = s
+	}
+	var res []byte
+	for i := 0; i <= maxIPReferrals; i++ {
+		next, err := queryAndRead(ctx, referralAddress(server), query(server, q))
+		if err != nil {
+			return nil, "", err
+		}
+		res = next
+		ref := referredServer(res)
+		if len(ref) == 0 || strings.EqualFold(ref, server) {
+			break
+		}
+		server = ref
+	}
+	return res, server, nil
+}
+
+var abuseContactFor = []byte("% abuse contact for ")
+
+func ParseIPResponse(raw []byte) *IPWhoisResponse {
+	r := &IPWhoisResponse{rawText: raw}
+	first := func(dst *string, v string) {
+		if len(*dst) == 0 {
+			*dst = v
+		}
+	}
+	var descr string
+	keyValues(raw, func(k, v string) {
+		switch k {
+		case "netrange", "inetnum", "inet6num":
+			first(&r.NetRange, v)
+		case "cidr":
+			if len(r.CIDR) == 0 {
+				for _, c := range strings.Split(v, ",") {
+					r.CIDR = append(r.CIDR, strings.TrimSpace(c))
+				}
+			}
+		case "netname":
+			first(&r.NetName, v)
+		case "originas", "origin":
+			r.OriginAS = append(r.OriginAS, strings.ToUpper(v))
+		case "orgname", "org-name", "owner":
+			first(&r.Organization, v)
+		case "descr":
+			first(&descr, v)
+		case "country":
+			first(&r.Country, strings.ToUpper(v))
+		case "orgabuseemail", "abuse-mailbox":
+			first(&r.AbuseEmail, v)
+		case "orgabusephone":
+			first(&r.AbusePhone, v)
+		}
+	})
+	first(&r.Organization, descr)
+	for s := (lineScanner{rest: raw}); s.scan(); {
+		l := bytes.TrimSpace(s.line)
+		if mo, ok := matchedObject(l); ok {
+			first(&r.MatchedObject, mo)
+			continue
+		}
+		if len(r.AbuseEmail) != 0 || len(l) < len(abuseContactFor) || !bytes.EqualFold(l[:len(abuseContactFor)], abuseContactFor) {
+			continue
+		}
+		if fs := bytes.Split(l, []byte("'")); len(fs) >= 4 {
+			r.AbuseEmail = string(fs[3])
+		}
+	}
+	return r
+}
+
+func IPWhoisRawContext(ctx context.Context, q string) ([]byte, error) {
+	if !IsIPQuery(q) {
+		return nil, fmt.Errorf("Whois: %s is not an IP address or CIDR block", q)
+	}
+	res, _, err := queryWithReferrals(ctx, q, ipQuery)
+	return res, err
+}
+
+func IPWhoisContext(ctx context.Context, q string) (*IPWhoisResponse, error) {
+	if !IsIPQuery(q) {
+		return nil, fmt.Errorf("Whois: %s is not an IP address or CIDR block", q)
+	}
+	res, server, err := queryWithReferrals(ctx, q, ipQuery)
+	if err != nil {
+		return nil, err
+	}
+	r := ParseIPResponse(res)
+	r.Query, r.WhoisServer = q, server
+	return r, nil
+}
+
+func IPWhois(q string) (*IPWhoisResponse, error) {
+	return IPWhoisContext(context.Background(), q)
+}
+
+func (r *IPWhoisResponse) Raw() []byte {
+	return r.rawText
+}
+
+func (r *IPWhoisResponse) WriteAsJSON(w io.Writer) error {
+	return WriteIndentedJSON(w, r)
+}
+
+func (r *IPWhoisResponse) WriteAsRawText(w io.Writer) (err error) {
+	_, err = w.Write(r.rawText)
+	return
+}
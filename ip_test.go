@@ -0,0 +1,30 @@
+package qwis
+
+import "testing"
+
+const ripeResponse = `% This is the RIPE Database query service.
+
+% Information related to '193.0.0.0 - 193.0.7.255'
+
+% Abuse contact for '193.0.0.0 - 193.0.7.255' is 'abuse@ripe.net'
+
+inetnum:        193.0.0.0 - 193.0.7.255
+netname:        RIPE-NCC
+descr:          RIPE Network Coordination Centre
+country:        NL
+
+% Information related to '193.0.0.0/21AS3333'
+
+route:          193.0.0.0/21
+origin:         AS3333
+`
+
+func TestParseIPResponseMatchedObject(t *testing.T) {
+	r := ParseIPResponse([]byte(ripeResponse))
+	if r.MatchedObject != "193.0.0.0 - 193.0.7.255" {
+		t.Errorf("MatchedObject = %q", r.MatchedObject)
+	}
+	if r.NetRange != "193.0.0.0 - 193.0.7.255" || r.AbuseEmail != "abuse@ripe.net" || r.Organization != "RIPE Network Coordination Centre" {
+		t.Errorf("net range %q, abuse %q, organization %q", r.NetRange, r.AbuseEmail, r.Organization)
+	}
+}
@@ -0,0 +1,43 @@
+package qwis
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// lineScanner iterates over the lines of an answer in place, as bufio.Scanner
+// does over a reader: line is the current one, without its "\n". Like
+// bytes.Split, it yields a last, empty line after a final "\n".
+type lineScanner struct {
+	rest, line []byte
+	done       bool
+}
+
+func (s *lineScanner) scan() bool {
+	if s.done {
+		return false
+	}
+	if i := bytes.IndexByte(s.rest, '\n'); i >= 0 {
+		s.line, s.rest = s.rest[:i], s.rest[i+1:]
+	} else {
+		s.line, s.rest, s.done = s.rest, nil, true
+	}
+	return true
+}
+
+// appendLower appends b lowercased to dst, without allocating for ASCII b
+// once dst has the room.
+func appendLower(dst, b []byte) []byte {
+	for _, c := range b {
+		if c >= utf8.RuneSelf {
+			return append(dst, bytes.ToLower(b)...)
+		}
+	}
+	for _, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		dst = append(dst, c)
+	}
+	return dst
+}
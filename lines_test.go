@@ -0,0 +1,67 @@
+package qwis
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLineScanner(t *testing.T) {
+	for _, raw := range []string{"", "\n", "a", "a\n", "a\r\nb", "a\n\nb\n", verisignResponse} {
+		var got [][]byte
+		for s := (lineScanner{rest: []byte(raw)}); s.scan(); {
+			got = append(got, s.line)
+		}
+		want := bytes.Split([]byte(raw), lf)
+		if len(got) != len(want) {
+			t.Errorf("%q: %d lines, want %d", raw, len(got), len(want))
+			continue
+		}
+		for i := range want {
+			if !bytes.Equal(got[i], want[i]) {
+				t.Errorf("%q: line %d = %q, want %q", raw, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestAppendLower(t *testing.T) {
+	for _, s := range []string{"", "Domain Name", "registrar", "ÉTAT", "Nom de Domaine"} {
+		if got := appendLower([]byte("x"), []byte(s)); string(got) != "x"+strings.ToLower(s) {
+			t.Errorf("appendLower(%q) = %q", s, got)
+		}
+	}
+	buf := make([]byte, 0, 64)
+	if n := testing.AllocsPerRun(100, func() { buf = appendLower(buf[:0], []byte("Registry Expiry Date")) }); n != 0 {
+		t.Errorf("appendLower allocated %v times", n)
+	}
+}
+
+func TestReadResponsePooled(t *testing.T) {
+	resp := strings.Repeat("Domain Name: EXAMPLE.COM\r\n", 1000)
+	res, err := readResponse(io.NopCloser(strings.NewReader(resp)))
+	if err != nil || string(res) != resp {
+		t.Fatalf("readResponse = %d bytes, %v", len(res), err)
+	}
+	// The answer must not share the pooled buffer the next one is read into.
+	if _, err = readResponse(io.NopCloser(strings.NewReader(strings.Repeat("x", len(resp))))); err != nil {
+		t.Fatal(err)
+	}
+	if string(res) != resp {
+		t.Error("an answer was overwritten by the next one")
+	}
+	if res, err = readResponse(io.NopCloser(strings.NewReader(""))); res != nil || err != nil {
+		t.Errorf("empty answer = %q, %v", res, err)
+	}
+}
+
+func BenchmarkReadResponse(b *testing.B) {
+	resp := strings.Repeat("Domain Name: EXAMPLE.COM\r\n", 200)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := readResponse(io.NopCloser(strings.NewReader(resp))); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package qwis
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Logger, when set, is told what lookups do: Info records for every query
+// sent and its outcome, referral followed, retry and failover, and Debug
+// ones for server selection, connections and cache hits.
+var Logger *slog.Logger
+
+func logEvent(ctx context.Context, level slog.Level, msg string, args ...any) {
+	if l := logger(ctx); l != nil {
+		l.Log(ctx, level, msg, args...)
+	}
+}
+
+// Hooks are called, those that are set, as lookups proceed, for counting
+// them without reading log records. Query follows every whois query and RDAP
+// request sent, with the server, host:port or RDAP host, the time it took
+// and the error it failed with; CacheHit is called instead when the cache
+// answers it. Referral is called before a registrar's server is asked.
+type Hooks struct {
+	Query    func(server string, elapsed time.Duration, err error)
+	CacheHit func(server string)
+	Referral func(domainName, server string)
+}
+
+// LookupHooks are the Hooks of the package functions.
+var LookupHooks Hooks
+
+func hookQuery(ctx context.Context, server string, elapsed time.Duration, err error) {
+	if f := lookupHooks(ctx).Query; f != nil {
+		f(server, elapsed, err)
+	}
+}
+
+func hookCacheHit(ctx context.Context, server string) {
+	if f := lookupHooks(ctx).CacheHit; f != nil {
+		f(server)
+	}
+}
+
+func hookReferral(ctx context.Context, domainName, server string) {
+	if f := lookupHooks(ctx).Referral; f != nil {
+		f(domainName, server)
+	}
+}
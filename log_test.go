@@ -0,0 +1,82 @@
+package qwis
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkorotkov/qwis/internal/whoistest"
+)
+
+func TestLogger(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{
+		"whois.verisign-grs.com:43":       "Domain Name: EXAMPLE.COM\r\nRegistrar WHOIS Server: whois.example-registrar.test\r\n",
+		"whois.example-registrar.test:43": "Domain Name: EXAMPLE.COM\r\nRegistrar: Example Registrar\r\n",
+	}}
+	failed := false
+	useDial(t, func(ctx context.Context, network, address string) (net.Conn, error) {
+		if !failed && address == "whois.verisign-grs.com:43" {
+			failed = true
+			return nil, errors.New("connection refused")
+		}
+		return fs.Dial(ctx, network, address)
+	})
+	Retry = RetryPolicy{Attempts: 2, Backoff: time.Millisecond}
+	var log bytes.Buffer
+	Logger = slog.New(slog.NewTextHandler(&log, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	defer func() { Logger = nil }()
+	if _, err := Whois("example.com"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`msg="server selected" domain=example.com server=whois.verisign-grs.com`,
+		`msg=dial server=whois.verisign-grs.com:43 network=tcp`,
+		`msg="query failed" server=whois.verisign-grs.com:43`,
+		`msg=retry attempt=2`,
+		`msg=query server=whois.verisign-grs.com:43 sent=14 received=80`,
+		`msg=referral domain=example.com server=whois.example-registrar.test:43`,
+		`msg=query server=whois.example-registrar.test:43`,
+	} {
+		if !strings.Contains(log.String(), want) {
+			t.Errorf("log lacks %q:\n%s", want, log.String())
+		}
+	}
+}
+
+func TestHooks(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{
+		"whois.verisign-grs.com:43":       "Domain Name: EXAMPLE.COM\r\nRegistrar WHOIS Server: whois.example-registrar.test\r\n",
+		"whois.example-registrar.test:43": "Domain Name: EXAMPLE.COM\r\nRegistrar: Example Registrar\r\n",
+	}}
+	var events []string
+	c := NewClient(WithDialer(fs.Dial), WithCache(NewMemoryCache(time.Minute)), WithHooks(Hooks{
+		Query: func(server string, _ time.Duration, _ error) {
+			if server != IANAWhoisServer+":43" {
+				events = append(events, "query "+server)
+			}
+		},
+		CacheHit: func(server string) { events = append(events, "cache hit "+server) },
+		Referral: func(domainName, server string) { events = append(events, "referral "+domainName+" "+server) },
+	}))
+	for i := 0; i < 2; i++ {
+		if _, err := c.Whois(context.Background(), "example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{
+		"query whois.verisign-grs.com:43",
+		"referral example.com whois.example-registrar.test:43",
+		"query whois.example-registrar.test:43",
+		"cache hit whois.verisign-grs.com:43",
+		"referral example.com whois.example-registrar.test:43",
+		"cache hit whois.example-registrar.test:43",
+	}
+	if strings.Join(events, "\n") != strings.Join(want, "\n") {
+		t.Errorf("hooks called for\n%s\nwant\n%s", strings.Join(events, "\n"), strings.Join(want, "\n"))
+	}
+}
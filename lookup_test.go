@@ -0,0 +1,109 @@
+package qwis
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// linearFields is the key dispatch as it was before exactKeyFields: exact
+// keys compared one by one in this order, then the substring predicates.
+var linearFields = []struct {
+	field responseField
+	keys  []string
+}{
+	{domainNameField, []string{"domain", "domain name"}},
+	{registrarField, []string{"registrar", "sponsoring registrar"}},
+	{statusField, []string{"status", "domain status"}},
+	{dnssecField, []string{"dnssec"}},
+	{expirationDateField, []string{"expiry", "paid-till"}},
+	{updatedDateField, []string{"last-modified", "last modified", "changed"}},
+	{registrarWhoisServerField, []string{"registrar whois server", "whois server", "referralserver"}},
+	{nameServerField, []string{"name server", "nameserver", "nameservers", "nserver"}},
+	{registrarIANAIDField, []string{"registrar iana id", "sponsoring registrar iana id"}},
+	{registrantOrganizationField, []string{"registrant organization", "registrant organisation"}},
+	{registrantCountryField, []string{"registrant country", "registrant country/economy"}},
+	{adminOrganizationField, []string{"admin organization", "admin organisation"}},
+	{adminCountryField, []string{"admin country"}},
+	{techOrganizationField, []string{"tech organization", "tech organisation"}},
+	{techCountryField, []string{"tech country"}},
+	{billingOrganizationField, []string{"billing organization", "billing organisation"}},
+	{abuseEmailField, []string{"registrar abuse contact email", "abuse contact email", "abuse-mailbox"}},
+	{abusePhoneField, []string{"registrar abuse contact phone", "abuse contact phone"}},
+}
+
+func linearLookupField(l []byte) (responseField, string) {
+	for _, lf := range linearFields {
+		for _, k := range lf.keys {
+			if bytes.Equal(l, []byte(k)) {
+				return lf.field, sourceExactKey
+			}
+		}
+	}
+	switch {
+	case isCreationDate(l):
+		return creationDateField, sourceSubstringKey
+	case isExperationDate(l):
+		return expirationDateField, sourceSubstringKey
+	case isUpdatedDate(l):
+		return updatedDateField, sourceSubstringKey
+	case isPendingDeleteDate(l):
+		return pendingDeleteDateField, sourceSubstringKey
+	}
+	return noField, ""
+}
+
+func responseKeys(raw string) [][]byte {
+	var keys [][]byte
+	for _, l := range strings.Split(raw, "\n") {
+		if i := strings.IndexByte(l, ':'); i >= 0 {
+			keys = append(keys, bytes.ToLower(bytes.TrimSpace([]byte(l[:i]))))
+		}
+	}
+	return keys
+}
+
+func TestLookupFieldMatchesLinear(t *testing.T) {
+	keys := responseKeys(verisignResponse + denicResponse)
+	for k := range exactKeyFields {
+		keys = append(keys, []byte(k))
+	}
+	for _, k := range []string{"creation date", "registry expiry date", "updated date", "pending delete date",
+		"registrar registration expiration date", "domain expiration", "last updated", "created", "", "x"} {
+		keys = append(keys, []byte(k))
+	}
+	for _, k := range keys {
+		f, src := lookupField(k)
+		if lf, lsrc := linearLookupField(k); f != lf || src != lsrc {
+			t.Errorf("lookupField(%q) = %d, %q; linear dispatch gives %d, %q", k, f, src, lf, lsrc)
+		}
+	}
+}
+
+func BenchmarkLookupField(b *testing.B) {
+	keys := responseKeys(verisignResponse)
+	for _, bc := range []struct {
+		name   string
+		lookup func([]byte) (responseField, string)
+	}{
+		{"map", lookupField},
+		{"linear", linearLookupField},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, k := range keys {
+					bc.lookup(k)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkParseResponse(b *testing.B) {
+	raw := []byte(verisignResponse)
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseResponse(raw); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
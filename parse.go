@@ -0,0 +1,405 @@
+package qwis
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+var (
+	lf    = []byte("\n")
+	colon = []byte(":")
+)
+
+const (
+	MultiDomainKeepFirst = "keep-first"
+	MultiDomainKeepLast  = "keep-last"
+	MultiDomainError     = "error"
+)
+
+var MultiDomain = MultiDomainKeepFirst
+
+type responseField int
+
+const (
+	noField responseField = iota
+	domainNameField
+	registrarField
+	statusField
+	dnssecField
+	creationDateField
+	expirationDateField
+	updatedDateField
+	pendingDeleteDateField
+	registrarWhoisServerField
+	nameServerField
+	registrarIANAIDField
+	registrantOrganizationField
+	registrantCountryField
+	adminOrganizationField
+	adminCountryField
+	techOrganizationField
+	techCountryField
+	billingOrganizationField
+	abuseEmailField
+	abusePhoneField
+)
+
+var exactKeyFields = map[string]responseField{
+	"domain":                        domainNameField,
+	"domain name":                   domainNameField,
+	"registrar":                     registrarField,
+	"sponsoring registrar":          registrarField,
+	"status":                        statusField,
+	"domain status":                 statusField,
+	"dnssec":                        dnssecField,
+	"expiry":                        expirationDateField,
+	"paid-till":                     expirationDateField,
+	"last-modified":                 updatedDateField,
+	"last modified":                 updatedDateField,
+	"changed":                       updatedDateField,
+	"registrar whois server":        registrarWhoisServerField,
+	"whois server":                  registrarWhoisServerField,
+	"referralserver":                registrarWhoisServerField,
+	"name server":                   nameServerField,
+	"nameserver":                    nameServerField,
+	"nameservers":                   nameServerField,
+	"nserver":                       nameServerField,
+	"registrar iana id":             registrarIANAIDField,
+	"sponsoring registrar iana id":  registrarIANAIDField,
+	"registrant organization":       registrantOrganizationField,
+	"registrant organisation":       registrantOrganizationField,
+	"registrant country":            registrantCountryField,
+	"registrant country/economy":    registrantCountryField,
+	"admin organization":            adminOrganizationField,
+	"admin organisation":            adminOrganizationField,
+	"admin country":                 adminCountryField,
+	"tech organization":             techOrganizationField,
+	"tech organisation":             techOrganizationField,
+	"tech country":                  techCountryField,
+	"billing organization":          billingOrganizationField,
+	"billing organisation":          billingOrganizationField,
+	"registrar abuse contact email": abuseEmailField,
+	"abuse contact email":           abuseEmailField,
+	"abuse-mailbox":                 abuseEmailField,
+	"registrar abuse contact phone": abusePhoneField,
+	"abuse contact phone":           abusePhoneField,
+}
+
+func isCreationDate(l []byte) bool {
+	return bytes.Contains(l, []byte("created")) ||
+		bytes.Contains(l, []byte("creation"))
+}
+
+func isExperationDate(l []byte) bool {
+	return bytes.Contains(l, []byte("expiry date")) ||
+		bytes.Contains(l, []byte("expire date")) ||
+		bytes.Contains(l, []byte("expiration"))
+}
+
+func isUpdatedDate(l []byte) bool {
+	return bytes.Contains(l, []byte("updated"))
+}
+
+func isPendingDeleteDate(l []byte) bool {
+	return bytes.Contains(l, []byte("pending delete")) ||
+		bytes.Contains(l, []byte("pending-delete")) ||
+		bytes.Contains(l, []byte("pendingdelete")) ||
+		bytes.Contains(l, []byte("redemption"))
+}
+
+func lookupField(l []byte) (responseField, string) {
+	if f, ok := exactKeyFields[string(l)]; ok {
+		return f, sourceExactKey
+	}
+	switch {
+	case isCreationDate(l):
+		return creationDateField, sourceSubstringKey
+	case isExperationDate(l):
+		return expirationDateField, sourceSubstringKey
+	case isUpdatedDate(l):
+		return updatedDateField, sourceSubstringKey
+	case isPendingDeleteDate(l):
+		return pendingDeleteDateField, sourceSubstringKey
+	}
+	return noField, ""
+}
+
+var informationRelatedTo = []byte("% information related to ")
+
+func matchedObject(l []byte) (string, bool) {
+	l = bytes.TrimSpace(l)
+	if len(l) <= len(informationRelatedTo) || !bytes.EqualFold(l[:len(informationRelatedTo)], informationRelatedTo) {
+		return "", false
+	}
+	return string(bytes.Trim(l[len(informationRelatedTo):], "'\" ")), true
+}
+
+func updatedDateValue(v string) string {
+	if fs := strings.Fields(v); len(fs) > 1 && strings.Contains(fs[0], "@") {
+		return strings.Join(fs[1:], " ")
+	}
+	return v
+}
+
+// addExtra keeps a key/value line no field was parsed from, leaving out
+// comments and the halves of lines split inside a URL.
+func (wir *WhoisResponse) addExtra(k, v string) {
+	if len(k) == 0 || len(v) == 0 || strings.ContainsAny(k[:1], "%#>*") || strings.HasPrefix(v, "//") {
+		return
+	}
+	if wir.Extra == nil {
+		wir.Extra = map[string][]string{}
+	}
+	wir.Extra[k] = append(wir.Extra[k], v)
+}
+
+// logicalLines returns the lines of raw with continuation lines, those
+// with no key of their own indented deeper than the key line above them,
+// folded in: as lines of their own for name servers and statuses, so that
+// "nserver:" groups become one value per line, and onto the key's value
+// for any other key.
+func logicalLines(raw []byte) [][]byte {
+	var (
+		lines  = make([][]byte, 0, bytes.Count(raw, lf)+1)
+		key    string
+		indent = -1
+		list   bool
+		s      = lineScanner{rest: raw}
+	)
+	for s.scan() {
+		l := s.line
+		t := bytes.TrimSpace(l)
+		if len(t) == 0 {
+			indent = -1
+			lines = append(lines, l)
+			continue
+		}
+		in := len(l) - len(bytes.TrimLeft(l, " \t"))
+		var (
+			k     string
+			keyed bool
+		)
+		// Only lines with a colon can have a key; the others are left
+		// unconverted.
+		if bytes.IndexByte(t, ':') >= 0 {
+			k, _, keyed = splitLayoutLine(string(t))
+		}
+		switch {
+		case !keyed && indent >= 0 && in > indent && list:
+			lines = append(lines, append([]byte(key+": "), t...))
+		case !keyed && indent >= 0 && in > indent:
+			last := bytes.TrimRight(lines[len(lines)-1], " \t\r")
+			lines[len(lines)-1] = append(append(append([]byte(nil), last...), ' '), t...)
+		case keyed:
+			f, _ := lookupField([]byte(k))
+			key, indent, list = k, in, f == nameServerField || f == statusField
+			lines = append(lines, l)
+		default:
+			indent = -1
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+func buildResponse(rawWhoisResponse []byte) (*WhoisResponse, error) {
+	r := &WhoisResponse{}
+	r.rawText = rawWhoisResponse
+	rtlns := logicalLines(rawWhoisResponse)
+	var keyBuf []byte
+	for _, rtln := range rtlns {
+		if mo, ok := matchedObject(rtln); ok {
+			if len(r.MatchedObject) == 0 {
+				r.MatchedObject = mo
+			}
+			continue
+		}
+		k, v, ok := bytes.Cut(rtln, colon)
+		if !ok {
+			continue
+		}
+		keyBuf = appendLower(keyBuf[:0], bytes.TrimSpace(k))
+		key := keyBuf
+		rhs := string(bytes.TrimSpace(v))
+		f, src := lookupField(key)
+		if f == noField {
+			r.addExtra(string(key), rhs)
+			continue
+		}
+		set := func(dst *string, name, v string) {
+			*dst = v
+			r.setSource(name, src)
+		}
+		switch f {
+		case domainNameField:
+			if len(r.DomainName) != 0 {
+				continue
+			}
+			set(&r.DomainName, "domain_name", rhs)
+		case registrarField:
+			set(&r.Registrar, "registrar", rhs)
+		case statusField:
+			r.setSource("statuses", src)
+			text, url := splitStatusURL(rhs)
+			for _, st := range strings.Split(text, ",") {
+				if st = strings.TrimSpace(st); len(st) != 0 {
+					r.Statuses = append(r.Statuses, st)
+					r.StatusCodes = append(r.StatusCodes, Status{Text: st, URL: url})
+				}
+			}
+		case nameServerField:
+			if ns := strings.Fields(rhs); len(ns) != 0 {
+				r.NameServers = append(r.NameServers, strings.ToLower(strings.TrimSuffix(ns[0], ".")))
+				r.setSource("name_servers", src)
+			}
+		case dnssecField:
+			set(&r.DNSSEC, "dnssec", rhs)
+		case creationDateField:
+			set(&r.CreationDate, "creation_date", rhs)
+		case expirationDateField:
+			set(&r.ExpirationDate, "expiration_date", rhs)
+		case updatedDateField:
+			set(&r.UpdatedDate, "updated_date", updatedDateValue(rhs))
+		case pendingDeleteDateField:
+			set(&r.PendingDeleteDate, "pending_delete_date", rhs)
+		case registrarWhoisServerField:
+			set(&r.RegistrarWhoisServer, "registrar_whois_server", rhs)
+		case registrarIANAIDField:
+			set(&r.RegistrarIANAID, "registrar_iana_id", rhs)
+		case registrantOrganizationField:
+			set(&r.RegistrantOrganization, "registrant_organization", rhs)
+		case registrantCountryField:
+			set(&r.RegistrantCountry, "registrant_country", rhs)
+		case adminOrganizationField:
+			set(&r.AdminOrganization, "admin_organization", rhs)
+		case adminCountryField:
+			set(&r.AdminCountry, "admin_country", rhs)
+		case techOrganizationField:
+			set(&r.TechOrganization, "tech_organization", rhs)
+		case techCountryField:
+			set(&r.TechCountry, "tech_country", rhs)
+		case billingOrganizationField:
+			set(&r.BillingOrganization, "billing_organization", rhs)
+		case abuseEmailField:
+			set(&r.AbuseEmail, "abuse_email", rhs)
+		case abusePhoneField:
+			set(&r.AbusePhone, "abuse_phone", rhs)
+		}
+	}
+	return r, nil
+}
+
+// ParserFunc turns a registry's raw answer into a response.
+type ParserFunc func(raw []byte) (*WhoisResponse, error)
+
+var tldParsers = map[string]ParserFunc{
+	"de": parseDE,
+	"jp": parseJP,
+}
+
+// RegisterParser makes ParseResponseWithTLD read the answers for tld with
+// p instead of the generic parser, which stays the default for TLDs without
+// one. It is not safe to call concurrently with lookups.
+func RegisterParser(tld string, p ParserFunc) {
+	tldParsers[strings.ToLower(strings.TrimPrefix(tld, "."))] = p
+}
+
+func ParseResponse(raw []byte) (*WhoisResponse, error) {
+	return ParseResponseWithTLD(raw, "")
+}
+
+// ParseResponseWithTLD parses raw, transcoded to UTF-8 by ToUTF8, with the
+// parser registered for tld. An
+// answer holding records for more than one domain, as thin registries send
+// for name collisions, is parsed record by record: MultiDomain picks the
+// primary one and the others are returned in its Related field.
+func ParseResponseWithTLD(raw []byte, tld string) (*WhoisResponse, error) {
+	return parseResponse(raw, tld, MultiDomain)
+}
+
+// ParseServerResponse is ParseResponseWithTLD for the answer server gave for
+// domainName, first rewritten for the quirks set with SetServerQuirks.
+func ParseServerResponse(raw []byte, server, domainName string) (*WhoisResponse, error) {
+	return parseServerResponse(raw, server, domainName, MultiDomain)
+}
+
+func parseServerResponse(raw []byte, server, domainName, policy string) (*WhoisResponse, error) {
+	return parseResponse(unquirk(raw, server, domainName), TopLevelDomain(domainName), policy)
+}
+
+// parseResponse is ParseResponseWithTLD picking the primary record by
+// policy, one of the MultiDomain values, for lookups run for a Client.
+func parseResponse(raw []byte, tld, policy string) (*WhoisResponse, error) {
+	tld = strings.ToLower(strings.TrimPrefix(tld, "."))
+	raw = ToUTF8(raw, tld)
+	parse, ok := tldParsers[tld]
+	if !ok {
+		parse = buildResponse
+	}
+	records := splitRecords(raw)
+	if len(records) > 1 && policy == MultiDomainError {
+		return nil, fmt.Errorf("ParseResponseWithTLD: %w: multiple domain list is not accepted", ErrParse)
+	}
+	parsed := make([]*WhoisResponse, len(records))
+	for i, rec := range records {
+		r, err := parse(rec)
+		if err != nil {
+			return nil, err
+		}
+		r.normalizeDates(tld)
+		r.fillDomainForms()
+		r.stripRedacted()
+		r.fillStatusCodes()
+		parsed[i] = r
+	}
+	primary := 0
+	if policy == MultiDomainKeepLast {
+		primary = len(parsed) - 1
+	}
+	r := parsed[primary]
+	if len(parsed) > 1 {
+		r.rawText = raw
+		r.Related = append(append(r.Related, parsed[:primary]...), parsed[primary+1:]...)
+	}
+	return r, nil
+}
+
+// splitRecords splits raw before each line naming a domain other than the
+// one of the record it is in, at the blank line that precedes it if there
+// is one since the record's own domain line.
+func splitRecords(raw []byte) [][]byte {
+	var (
+		records     [][]byte
+		start, cut  int
+		domain      string
+		sinceDomain bool
+		keyBuf      []byte
+	)
+	for off := 0; off < len(raw); {
+		end := bytes.IndexByte(raw[off:], '\n') + 1
+		if end == 0 {
+			end = len(raw) - off
+		}
+		l := raw[off : off+end]
+		if len(bytes.TrimSpace(l)) == 0 {
+			if sinceDomain {
+				cut, sinceDomain = off, false
+			}
+		} else if k, v, ok := bytes.Cut(l, colon); ok {
+			keyBuf = appendLower(keyBuf[:0], bytes.TrimSpace(k))
+			if f, _ := lookupField(keyBuf); f == domainNameField && len(bytes.TrimSpace(v)) != 0 {
+				dn := string(bytes.TrimSpace(v))
+				if len(domain) != 0 && !strings.EqualFold(domain, dn) {
+					if cut <= start {
+						cut = off
+					}
+					records, start = append(records, raw[start:cut]), cut
+				}
+				domain, sinceDomain = dn, true
+			}
+		}
+		off += end
+	}
+	return append(records, raw[start:])
+}
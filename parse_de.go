@@ -0,0 +1,70 @@
+package qwis
+
+import (
+	"bytes"
+	"strings"
+)
+
+// parseDE reads DENIC answers: the domain's own keys come first, followed
+// by "[Holder]", "[Tech-C]" and similar contact sections whose keys (such as
+// "Changed") must not be taken for the domain's.
+func parseDE(raw []byte) (*WhoisResponse, error) {
+	head, sections := raw, []byte(nil)
+	if i := bytes.Index(raw, []byte("\n[")); i >= 0 {
+		head, sections = raw[:i+1], raw[i+1:]
+	}
+	r, err := buildResponse(head)
+	if err != nil {
+		return nil, err
+	}
+	r.rawText = raw
+	dnskey := false
+	keyValues(head, func(k, v string) {
+		dnskey = dnskey || k == "dnskey"
+	})
+	if len(r.DomainName) != 0 {
+		r.DNSSEC = "unsigned"
+		if dnskey {
+			r.DNSSEC = "signedDelegation"
+		}
+		r.setSource("dnssec", sourceTLDParser)
+	}
+	for _, s := range bytes.Split(sections, []byte("\n[")) {
+		l := bytes.IndexByte(s, '\n')
+		if l < 0 {
+			continue
+		}
+		var (
+			org, country *string
+			prefix       string
+		)
+		switch strings.ToLower(string(bytes.Trim(s[:l], "[] \r"))) {
+		case "holder":
+			org, country, prefix = &r.RegistrantOrganization, &r.RegistrantCountry, "registrant"
+		case "admin-c":
+			org, country, prefix = &r.AdminOrganization, &r.AdminCountry, "admin"
+		case "tech-c":
+			org, country, prefix = &r.TechOrganization, &r.TechCountry, "tech"
+		default:
+			continue
+		}
+		var name string
+		keyValues(s[l:], func(k, v string) {
+			switch k {
+			case "organisation":
+				*org = v
+				r.setSource(prefix+"_organization", sourceTLDParser)
+			case "name":
+				name = v
+			case "countrycode":
+				*country = strings.ToUpper(v)
+				r.setSource(prefix+"_country", sourceTLDParser)
+			}
+		})
+		if len(*org) == 0 && len(name) != 0 {
+			*org = name
+			r.setSource(prefix+"_organization", sourceTLDParser)
+		}
+	}
+	return r, nil
+}
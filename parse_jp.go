@@ -0,0 +1,70 @@
+package qwis
+
+import (
+	"bytes"
+	"strings"
+)
+
+// jpKeys maps JPRS "[Key]" labels, as sent in English (the "/e" query
+// suffix), to keys buildResponse knows.
+var jpKeys = map[string]string{
+	"domain name":     "domain name",
+	"registrant":      "registrant organization",
+	"organization":    "registrant organization",
+	"name server":     "name server",
+	"signing key":     "dnssec",
+	"status":          "status",
+	"state":           "status",
+	"created on":      "creation date",
+	"registered date": "creation date",
+	"expires on":      "expiry date",
+	"last updated":    "updated date",
+	"last update":     "updated date",
+}
+
+// parseJP reads JPRS answers, whose lines look like "a. [Domain Name]
+// EXAMPLE.JP" or "[Created on] 2001/09/11" rather than "key: value".
+func parseJP(raw []byte) (*WhoisResponse, error) {
+	var (
+		kv    []byte
+		extra [][2]string
+	)
+	for _, l := range bytes.Split(raw, lf) {
+		l = bytes.TrimSpace(l)
+		// Drop the "a. " item label of domain information lines.
+		if i := bytes.Index(l, []byte(". [")); i > 0 && i <= 2 {
+			l = l[i+2:]
+		}
+		end := bytes.IndexByte(l, ']')
+		if len(l) == 0 || l[0] != '[' || end < 0 {
+			continue
+		}
+		key, ok := jpKeys[strings.ToLower(string(l[1:end]))]
+		v := string(bytes.TrimSpace(l[end+1:]))
+		if len(v) == 0 {
+			continue
+		}
+		if !ok {
+			extra = append(extra, [2]string{strings.ToLower(string(l[1:end])), v})
+			continue
+		}
+		if key == "status" {
+			// "Connected (2025/09/30)"
+			v = strings.TrimSpace(strings.Split(v, "(")[0])
+		}
+		if key == "dnssec" {
+			v = "signedDelegation"
+		}
+		kv = append(kv, key+": "+v+"\n"...)
+	}
+	r, err := buildResponse(kv)
+	if err != nil {
+		return nil, err
+	}
+	r.rawText = raw
+	r.tagFields(sourceTLDParser, func(int) bool { return true })
+	for _, e := range extra {
+		r.addExtra(e[0], e[1])
+	}
+	return r, nil
+}
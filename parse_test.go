@@ -0,0 +1,322 @@
+package qwis
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const verisignResponse = `   Domain Name: GOOGLE.COM
+   Registry Domain ID: 2138514_DOMAIN_COM-VRSN
+   Registrar WHOIS Server: whois.markmonitor.com
+   Registrar URL: http://www.markmonitor.com
+   Updated Date: 2019-09-09T15:39:04Z
+   Creation Date: 1997-09-15T04:00:00Z
+   Registry Expiry Date: 2028-09-14T04:00:00Z
+   Registrar: MarkMonitor Inc.
+   Registrar IANA ID: 292
+   Registrar Abuse Contact Email: abusecomplaints@markmonitor.com
+   Registrar Abuse Contact Phone: +1.2086851750
+   Domain Status: clientDeleteProhibited https://icann.org/epp#clientDeleteProhibited
+   Domain Status: clientTransferProhibited https://icann.org/epp#clientTransferProhibited
+   Domain Status: serverTransferProhibited https://icann.org/epp#serverTransferProhibited
+   Name Server: NS1.GOOGLE.COM
+   Name Server: NS2.GOOGLE.COM
+   DNSSEC: unsigned
+   URL of the ICANN Whois Inaccuracy Complaint Form: https://www.icann.org/wicf/
+>>> Last update of whois database: 2024-01-01T00:00:00Z <<<
+`
+
+func TestParseCommaSeparatedStatuses(t *testing.T) {
+	wir, err := ParseResponse([]byte("Domain Name: example.org\nStatus: active, clientTransferProhibited https://icann.org/epp#clientTransferProhibited\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"active", "clientTransferProhibited"}; !reflect.DeepEqual(wir.Statuses, want) {
+		t.Errorf("Statuses = %q, want %q", wir.Statuses, want)
+	}
+}
+
+const denicResponse = `Domain: denic.de
+Nserver: ns1.denic.de
+Nserver: ns2.denic.de
+Dnskey: 257 3 8 AwEAAb/xrM2MD+xm84YNYby6TxkMaC6PtzF2bB9WBB7ux7iqzhViob4GKvQ6L7CkXjyAxfKbTzrdvXoAPpsAPW4pkThReDAVp3QxvUKrkBM8/uWRF3wpaUoPsAHm1dbcL9aiW3lqlLMZjDEwDfU6lxLcPg9d14fq4dc44FvPx6aYcymkgJoYvR6P1wECpxqlEAR2K1cvMtqCqvVESBQV/EUtWiALNuwR2PbhwtBWJd+e5BdFI7pabNMvMC3a2ZPZaoqNMtmkr5ByNCto0K4PZKxdWclHFtnsFNQjwsmF6jhfg0jZ1DvGC3bg7AyVikDu4KXVp8WzZbPw0vlB/3Xi/RnxLQs=
+Status: connect
+Changed: 2018-03-12T21:44:25+01:00
+
+[Tech-C]
+Type: ROLE
+Name: Business Services
+Organisation: DENIC eG
+Address: Kaiserstrasse 75-77
+PostalCode: 60329
+City: Frankfurt am Main
+CountryCode: DE
+Phone: +49.69.27235.0
+Email: dbs@denic.de
+Changed: 2016-11-16T14:40:25+01:00
+`
+
+func TestParseResponseWithTLDDE(t *testing.T) {
+	wir, err := ParseResponseWithTLD([]byte(denicResponse), "de")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wir.DomainName != "denic.de" || wir.UpdatedDate != "2018-03-12T20:44:25Z" {
+		t.Errorf("domain %q, updated %q", wir.DomainName, wir.UpdatedDate)
+	}
+	if want := []string{"ns1.denic.de", "ns2.denic.de"}; !reflect.DeepEqual(wir.NameServers, want) {
+		t.Errorf("NameServers = %q, want %q", wir.NameServers, want)
+	}
+	if wir.DNSSEC != "signedDelegation" || wir.TechOrganization != "DENIC eG" || wir.TechCountry != "DE" {
+		t.Errorf("dnssec %q, tech %q/%q", wir.DNSSEC, wir.TechOrganization, wir.TechCountry)
+	}
+	// The generic parser takes the tech contact's Changed line for the
+	// domain's.
+	generic, err := ParseResponse([]byte(denicResponse))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if generic.UpdatedDate == wir.UpdatedDate {
+		t.Errorf("generic parse unexpectedly matches the .de parser: %q", generic.UpdatedDate)
+	}
+}
+
+func TestTLDParserFieldSources(t *testing.T) {
+	recordFieldSources(t)
+	wir, err := ParseResponseWithTLD([]byte(denicResponse), ".DE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wir.FieldSources["dnssec"] != sourceTLDParser || wir.FieldSources["domain_name"] != sourceExactKey {
+		t.Errorf("FieldSources = %v", wir.FieldSources)
+	}
+}
+
+func TestMultiDomain(t *testing.T) {
+	raw := []byte("Domain Name: example.com\nDomain Name: EXAMPLE.COM\nDomain Name: example.net\n")
+	defer func(m string) { MultiDomain = m }(MultiDomain)
+	for _, tc := range []struct {
+		mode, want string
+		err        bool
+	}{
+		{MultiDomainKeepFirst, "example.com", false},
+		{MultiDomainKeepLast, "example.net", false},
+		{MultiDomainError, "", true},
+	} {
+		MultiDomain = tc.mode
+		wir, err := ParseResponse(raw)
+		switch {
+		case tc.err:
+			if !errors.Is(err, ErrParse) {
+				t.Errorf("%s: err = %v, want ErrParse", tc.mode, err)
+			}
+		case err != nil || wir.DomainName != tc.want:
+			t.Errorf("%s: DomainName = %v, %v; want %q", tc.mode, wir, err, tc.want)
+		}
+	}
+	// The same domain repeated in different case is not a conflict.
+	MultiDomain = MultiDomainError
+	if wir, err := ParseResponse(raw[:len(raw)-len("Domain Name: example.net\n")]); err != nil || wir.DomainName != "example.com" {
+		t.Errorf("repeated domain: %v, %v", wir, err)
+	}
+}
+
+func TestParseChangedWithEmail(t *testing.T) {
+	for _, keep := range []bool{false, true} {
+		KeepRawDates = keep
+		wir, err := ParseResponse([]byte("domain: example.net\nchanged: admin@example.net 20200101\n"))
+		KeepRawDates = false
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "2020-01-01T00:00:00Z"
+		if keep {
+			want = "20200101"
+		}
+		if wir.UpdatedDate != want || !wir.UpdatedTime.Equal(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("KeepRawDates=%v: UpdatedDate %q, UpdatedTime %v; want %q", keep, wir.UpdatedDate, wir.UpdatedTime, want)
+		}
+	}
+}
+
+func TestParsePendingDeleteDate(t *testing.T) {
+	for _, l := range []string{"Pending Delete Date: 2024-03-01 12:00:00", "Redemption Date: 01-Mar-2024 12:00:00 UTC"} {
+		wir, err := ParseResponse([]byte("Domain Name: example.com\n" + l + "\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wir.PendingDeleteDate != "2024-03-01T12:00:00Z" || !wir.PendingDeleteTime.Equal(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)) {
+			t.Errorf("%q: PendingDeleteDate %q, PendingDeleteTime %v", l, wir.PendingDeleteDate, wir.PendingDeleteTime)
+		}
+	}
+	wir, err := ParseResponse([]byte(verisignResponse))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wirj, _ := json.Marshal(wir)
+	if len(wir.PendingDeleteDate) != 0 || bytes.Contains(wirj, []byte("pending_delete_date")) {
+		t.Errorf("pending delete date present without a source line: %s", wirj)
+	}
+}
+
+func TestSetDaysUntilExpiry(t *testing.T) {
+	wir, err := ParseResponse([]byte(verisignResponse))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wir.SetDaysUntilExpiry(time.Date(2028, 9, 4, 12, 0, 0, 0, time.UTC))
+	if wir.DaysUntilExpiry == nil || *wir.DaysUntilExpiry != 9 {
+		t.Errorf("DaysUntilExpiry = %v, want 9", wir.DaysUntilExpiry)
+	}
+	wir.SetDaysUntilExpiry(time.Date(2028, 9, 24, 12, 0, 0, 0, time.UTC))
+	if *wir.DaysUntilExpiry != -10 {
+		t.Errorf("DaysUntilExpiry = %d, want -10", *wir.DaysUntilExpiry)
+	}
+	wir = &WhoisResponse{ExpirationDate: "someday"}
+	if wir.SetDaysUntilExpiry(time.Now()); wir.DaysUntilExpiry != nil {
+		t.Errorf("DaysUntilExpiry = %d without a parsed date", *wir.DaysUntilExpiry)
+	}
+}
+
+const jprsResponse = `[ JPRS database provides information on network administration. ]
+
+Domain Information:
+[Domain Name]                   EXAMPLE.JP
+
+[Registrant]                    Example Japan K.K.
+
+[Name Server]                   ns1.example.jp
+[Name Server]                   ns2.example.jp
+[Signing Key]
+
+[Created on]                    2001/09/11
+[Expires on]                    2025/09/30
+[Status]                        Active
+[Last Updated]                  2024/10/01 01:05:04 (JST)
+`
+
+const jprsCoJPResponse = `Domain Information:
+a. [Domain Name]                EXAMPLE.CO.JP
+g. [Organization]               Example Co., Ltd.
+p. [Name Server]                ns1.example.co.jp
+[State]                         Connected (2025/09/30)
+[Registered Date]               2001/09/11
+[Last Update]                   2024/10/01 01:05:04 (JST)
+`
+
+func TestParseResponseWithTLDJP(t *testing.T) {
+	wir, err := ParseResponseWithTLD([]byte(jprsResponse), "jp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wir.DomainName != "EXAMPLE.JP" || wir.RegistrantOrganization != "Example Japan K.K." || len(wir.NameServers) != 2 {
+		t.Errorf("domain %q, registrant %q, name servers %q", wir.DomainName, wir.RegistrantOrganization, wir.NameServers)
+	}
+	// JPRS dates are in JST, nine hours ahead of UTC.
+	if wir.CreationDate != "2001-09-10T15:00:00Z" || wir.ExpirationDate != "2025-09-29T15:00:00Z" || wir.UpdatedDate != "2024-09-30T16:05:04Z" {
+		t.Errorf("created %q, expires %q, updated %q", wir.CreationDate, wir.ExpirationDate, wir.UpdatedDate)
+	}
+	if !reflect.DeepEqual(wir.Statuses, []string{"Active"}) || wir.DNSSEC != "" {
+		t.Errorf("statuses %q, dnssec %q", wir.Statuses, wir.DNSSEC)
+	}
+	wir, err = ParseResponseWithTLD([]byte(jprsCoJPResponse), "jp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wir.DomainName != "EXAMPLE.CO.JP" || wir.RegistrantOrganization != "Example Co., Ltd." ||
+		!reflect.DeepEqual(wir.Statuses, []string{"Connected"}) || wir.CreationDate != "2001-09-10T15:00:00Z" {
+		t.Errorf("domain %q, organization %q, statuses %q, created %q", wir.DomainName, wir.RegistrantOrganization, wir.Statuses, wir.CreationDate)
+	}
+}
+
+func TestParseExtra(t *testing.T) {
+	wir, err := ParseResponse([]byte(verisignResponse))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"2138514_DOMAIN_COM-VRSN"}; !reflect.DeepEqual(wir.Extra["registry domain id"], want) {
+		t.Errorf("Extra[registry domain id] = %q, want %q", wir.Extra["registry domain id"], want)
+	}
+	if want := []string{"https://www.icann.org/wicf/"}; !reflect.DeepEqual(wir.Extra["url of the icann whois inaccuracy complaint form"], want) {
+		t.Errorf("Extra = %q", wir.Extra)
+	}
+	for _, k := range []string{"registrar", "domain status", ">>> last update of whois database"} {
+		if _, ok := wir.Extra[k]; ok {
+			t.Errorf("Extra has %q", k)
+		}
+	}
+	wir, err = ParseResponseWithTLD([]byte(jprsResponse+"[Registrant Contact]             EX123JP\n"), "jp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"EX123JP"}; !reflect.DeepEqual(wir.Extra["registrant contact"], want) {
+		t.Errorf("jp Extra = %q", wir.Extra)
+	}
+}
+
+func TestParseRelatedRecords(t *testing.T) {
+	raw := []byte("Domain Name: EXAMPLE.COM\nRegistrar: First Registrar\nCreation Date: 1995-08-14T04:00:00Z\n\n" +
+		"Domain Name: EXAMPLE.COM.EVIL.TEST\nRegistrar: Second Registrar\n\n" +
+		"Domain Name: EXAMPLE.COM.OTHER.TEST\nRegistrar: Third Registrar\n")
+	defer func(m string) { MultiDomain = m }(MultiDomain)
+	for _, tc := range []struct {
+		mode, primary, registrar string
+		related                  []string
+	}{
+		{MultiDomainKeepFirst, "EXAMPLE.COM", "First Registrar", []string{"EXAMPLE.COM.EVIL.TEST", "EXAMPLE.COM.OTHER.TEST"}},
+		{MultiDomainKeepLast, "EXAMPLE.COM.OTHER.TEST", "Third Registrar", []string{"EXAMPLE.COM", "EXAMPLE.COM.EVIL.TEST"}},
+	} {
+		MultiDomain = tc.mode
+		wir, err := ParseResponse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var related []string
+		for _, r := range wir.Related {
+			related = append(related, r.DomainName)
+		}
+		if wir.DomainName != tc.primary || wir.Registrar != tc.registrar || !reflect.DeepEqual(related, tc.related) {
+			t.Errorf("%s: primary %q (%q), related %q", tc.mode, wir.DomainName, wir.Registrar, related)
+		}
+		if !bytes.Equal(wir.rawText, raw) {
+			t.Errorf("%s: primary raw text is not the whole answer", tc.mode)
+		}
+	}
+	MultiDomain = MultiDomainKeepFirst
+	wir, _ := ParseResponse(raw)
+	if r := wir.Related[0]; r.Registrar != "Second Registrar" || len(r.CreationDate) != 0 {
+		t.Errorf("related record %+v mixes in the primary's fields", r)
+	}
+}
+
+func TestParseContinuationLines(t *testing.T) {
+	wir, err := ParseResponse([]byte("Domain Name: example.test\n" +
+		"Registrant Organization: Example\n" +
+		"    Holdings Ltd\n" +
+		"Name Server:\n" +
+		"    ns1.example.test\n" +
+		"    ns2.example.test 192.0.2.2\n" +
+		"nserver: ns3.example.test\n" +
+		"         ns4.example.test\n" +
+		"Registrar: Example Registrar\n" +
+		"\n" +
+		"    after a blank line\n" +
+		"Registrar URL: https://registrar.example\n" +
+		"    https://registrar.example/whois\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wir.RegistrantOrganization != "Example Holdings Ltd" || wir.Registrar != "Example Registrar" {
+		t.Errorf("organization %q, registrar %q", wir.RegistrantOrganization, wir.Registrar)
+	}
+	if want := []string{"ns1.example.test", "ns2.example.test", "ns3.example.test", "ns4.example.test"}; !reflect.DeepEqual(wir.NameServers, want) {
+		t.Errorf("NameServers = %q, want %q", wir.NameServers, want)
+	}
+	if want := []string{"https://registrar.example https://registrar.example/whois"}; !reflect.DeepEqual(wir.Extra["registrar url"], want) {
+		t.Errorf("Extra[registrar url] = %q, want %q", wir.Extra["registrar url"], want)
+	}
+}
@@ -0,0 +1,262 @@
+package qwis
+
+import (
+	"bytes"
+	"strings"
+)
+
+// tldLayout describes a registry's answer well enough to turn it into the
+// "key: value" lines buildResponse reads. Values indented under a key, or
+// under a bare heading such as "Registrant", are read as that key's with
+// the heading prefixed to their own key ("relevant dates expiry date").
+type tldLayout struct {
+	// keys maps the registry's lower-case keys to buildResponse's; an
+	// empty target leaves the key to Extra. Keys of indented lines go to
+	// Extra unless mapped here or already known to buildResponse; only the
+	// first value of keys other than statuses and name servers is kept.
+	keys map[string]string
+	// flat reads unindented lines after a key with no value, as in
+	// "Registrar:\nExample AG", as that key's values until a blank line.
+	flat bool
+	// headOnly reads only name servers and Extra after the first block of
+	// keys, where the contact objects that follow would otherwise
+	// overwrite the domain's own dates.
+	headOnly bool
+}
+
+type layoutLine struct {
+	indent int
+	key    string
+	// open marks a key with no value of its own, which the unindented
+	// lines of a flat layout belong to.
+	open bool
+}
+
+func (tl tldLayout) parse(raw []byte) (*WhoisResponse, error) {
+	var (
+		kv    []byte
+		stack []layoutLine
+		extra [][2]string
+		seen  = map[string]bool{}
+		head  = true
+		got   bool
+	)
+	emit := func(key, v string, nested bool) {
+		if len(v) == 0 {
+			return
+		}
+		target, ok := tl.keys[key]
+		if _, known := exactKeyFields[key]; !ok && (!nested || known) {
+			target = key
+		}
+		f := noField
+		if len(target) != 0 {
+			f, _ = lookupField([]byte(target))
+		}
+		if len(target) == 0 || !head && f == noField {
+			extra = append(extra, [2]string{key, v})
+			return
+		}
+		if !head && f != nameServerField || f != noField && f != nameServerField && f != statusField && seen[target] {
+			return
+		}
+		seen[target], got = true, true
+		switch target {
+		case "dnssec":
+			v = dnssecValue(v)
+		case "registrar":
+			v = registrarValue(v)
+		}
+		kv = append(kv, target+": "+v+"\n"...)
+	}
+	for _, l := range bytes.Split(raw, lf) {
+		l = bytes.TrimRight(l, " \t\r")
+		text := strings.TrimLeft(string(l), " \t")
+		if len(text) == 0 {
+			if tl.flat {
+				stack = stack[:0]
+			}
+			if got && tl.headOnly {
+				head = false
+			}
+			continue
+		}
+		if text[0] == '%' || text[0] == '#' {
+			continue
+		}
+		indent := len(l) - len(text)
+		key, v, keyed := splitLayoutLine(text)
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if top.indent < indent || top.indent == indent && tl.flat && !keyed && top.open {
+				break
+			}
+			stack = stack[:len(stack)-1]
+		}
+		var prefix string
+		if len(stack) > 0 {
+			prefix = stack[len(stack)-1].key
+		}
+		if !keyed {
+			if len(prefix) != 0 {
+				emit(prefix, text, true)
+			} else {
+				stack = append(stack, layoutLine{indent, strings.ToLower(text), false})
+			}
+			continue
+		}
+		if len(prefix) != 0 {
+			key = prefix + " " + key
+		}
+		emit(key, v, len(prefix) != 0)
+		stack = append(stack, layoutLine{indent, key, len(v) == 0})
+	}
+	r, err := buildResponse(kv)
+	if err != nil {
+		return nil, err
+	}
+	r.rawText = raw
+	r.tagFields(sourceTLDParser, func(int) bool { return true })
+	for _, e := range extra {
+		r.addExtra(e[0], e[1])
+	}
+	return r, nil
+}
+
+// splitLayoutLine splits "Key: value" and "Key:" lines, whose colon is
+// followed by white space or ends the line, and single-word "Key:value"
+// ones. Other colons, as in URLs, times or "flags:257", are values.
+func splitLayoutLine(s string) (key, value string, ok bool) {
+	word := func(s string) bool {
+		return strings.IndexFunc(s, func(r rune) bool { return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z') }) < 0
+	}
+	for i := 0; ; i++ {
+		j := strings.IndexByte(s[i:], ':')
+		if j < 0 {
+			return "", "", false
+		}
+		i += j
+		if i+1 == len(s) || s[i+1] == ' ' || s[i+1] == '\t' ||
+			s[i+1] != '/' && word(s[:i]) && !strings.Contains(s[i+1:], ":") {
+			key = strings.ToLower(strings.TrimSpace(strings.TrimRight(s[:i], ". ")))
+			return key, strings.TrimSpace(s[i+1:]), len(key) != 0
+		}
+	}
+}
+
+func dnssecValue(v string) string {
+	switch strings.ToLower(v) {
+	case "no", "n", "unsigned", "unsigned delegation", "inactive", "false":
+		return "unsigned"
+	}
+	return "signedDelegation"
+}
+
+// registrarValue drops the tag or URL some registries append to the name,
+// as in "Example Ltd [Tag = EXAMPLE]" or "Example GmbH ( https://... )".
+func registrarValue(v string) string {
+	if i := strings.Index(v, " [Tag = "); i > 0 {
+		v = v[:i]
+	}
+	if i := strings.Index(v, "("); i > 0 && strings.Contains(v[i:], "http") {
+		v = v[:i]
+	}
+	return strings.TrimSpace(v)
+}
+
+var tldLayouts = map[string]tldLayout{
+	"at": {headOnly: true, keys: map[string]string{
+		"registrant": "",
+	}},
+	"au": {keys: map[string]string{
+		"registrar name": "registrar",
+		"registrant":     "registrant organization",
+	}},
+	"be": {keys: map[string]string{
+		"status":         "",
+		"flags":          "status",
+		"registered":     "creation date",
+		"registrar name": "registrar",
+		"registrar technical contacts organisation": "tech organization",
+		"nameservers": "name server",
+		"keys":        "dnssec",
+	}},
+	"br": {headOnly: true, keys: map[string]string{
+		"owner":   "registrant organization",
+		"expires": "expiry date",
+	}},
+	"ch": {flat: true, keys: map[string]string{
+		"holder of domain name":   "registrant organization",
+		"name servers":            "name server",
+		"first registration date": "creation date",
+	}},
+	"cn": {keys: map[string]string{
+		"registrant":        "registrant organization",
+		"registration time": "creation date",
+		"expiration time":   "expiry date",
+	}},
+	"dk": {keys: map[string]string{
+		"dns":        "",
+		"registered": "creation date",
+		"expires":    "expiry date",
+		"hostname":   "name server",
+	}},
+	"eu": {keys: map[string]string{
+		"registrar name":         "registrar",
+		"technical organisation": "tech organization",
+		"name servers":           "name server",
+		"keys":                   "dnssec",
+	}},
+	"fr": {headOnly: true, keys: map[string]string{
+		"status":      "",
+		"eppstatus":   "status",
+		"last-update": "updated date",
+	}},
+	"it": {keys: map[string]string{
+		"signed":                          "dnssec",
+		"last update":                     "updated date",
+		"admin contact organization":      "admin organization",
+		"technical contacts organization": "tech organization",
+		"registrar organization":          "registrar",
+		"nameservers":                     "name server",
+	}},
+	"kr": {keys: map[string]string{
+		"registrant":                      "registrant organization",
+		"registered date":                 "creation date",
+		"authorized agency":               "registrar",
+		"primary name server host name":   "name server",
+		"secondary name server host name": "name server",
+	}},
+	"nl": {keys: map[string]string{
+		"domain nameservers": "name server",
+	}},
+	"pl": {flat: true, keys: map[string]string{
+		"renewal date":           "expiry date",
+		"option created":         "",
+		"option expiration date": "",
+	}},
+	"ru": {keys: map[string]string{
+		"org":   "registrant organization",
+		"state": "status",
+	}},
+	"se": {keys: map[string]string{
+		"holder":   "",
+		"state":    "status",
+		"modified": "updated date",
+		"expires":  "expiry date",
+	}},
+	"uk": {keys: map[string]string{
+		"registrant":                   "registrant organization",
+		"relevant dates registered on": "creation date",
+		"relevant dates expiry date":   "expiry date",
+		"relevant dates last updated":  "updated date",
+		"registration status":          "status",
+		"name servers":                 "name server",
+	}},
+}
+
+func init() {
+	for tld, tl := range tldLayouts {
+		RegisterParser(tld, tl.parse)
+	}
+}
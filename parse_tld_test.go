@@ -0,0 +1,160 @@
+package qwis
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestTLDParsers reads the answers recorded in testdata/whois, one per
+// registry, with the parser ParseResponseWithTLD picks for the file's TLD.
+func TestTLDParsers(t *testing.T) {
+	for _, tc := range []struct {
+		tld  string
+		want WhoisResponse
+	}{
+		{"org", WhoisResponse{
+			DomainName: "example.org", Registrar: "Example Registrar, LLC", RegistrantOrganization: "Example Foundation",
+			CreationDate: "1995-08-31T04:00:00Z", ExpirationDate: "2025-08-30T04:00:00Z", UpdatedDate: "2024-08-14T07:01:34Z",
+			Statuses: []string{"clientDeleteProhibited", "clientTransferProhibited"}, NameServers: []string{"ns1.example.org", "ns2.example.org"},
+			DNSSEC: "signedDelegation", RegistrarWhoisServer: "http://whois.example-registrar.org",
+			AbuseEmail: "abuse@example-registrar.org", AbusePhone: "+1.5555555555",
+		}},
+		{"uk", WhoisResponse{
+			DomainName: "example.co.uk", Registrar: "Example Registrar Ltd",
+			CreationDate: "1996-08-26T00:00:00Z", ExpirationDate: "2026-08-26T00:00:00Z", UpdatedDate: "2024-07-11T00:00:00Z",
+			Statuses: []string{"Registered until expiry date."}, NameServers: []string{"ns1.example.co.uk", "ns2.example.co.uk"},
+		}},
+		{"br", WhoisResponse{
+			DomainName: "example.com.br", RegistrantOrganization: "Example Ltda",
+			CreationDate: "1997-01-01T00:00:00Z", ExpirationDate: "2025-01-01T00:00:00Z", UpdatedDate: "2024-03-01T00:00:00Z",
+			Statuses: []string{"published"}, NameServers: []string{"a.dns.br", "b.dns.br"},
+		}},
+		{"fr", WhoisResponse{
+			DomainName: "example.fr", Registrar: "EXAMPLE REGISTRAR",
+			CreationDate: "1995-01-01T00:00:00Z", ExpirationDate: "2025-03-01T10:00:00Z", UpdatedDate: "2024-02-15T09:00:00Z",
+			Statuses: []string{"active", "serverTransferProhibited"}, NameServers: []string{"ns1.example.fr", "ns2.example.fr"},
+		}},
+		{"it", WhoisResponse{
+			DomainName: "example.it", Registrar: "Example Registrar S.r.l.", RegistrantOrganization: "Example S.p.A.",
+			AdminOrganization: "Example S.p.A.", TechOrganization: "Example Hosting S.r.l.",
+			CreationDate: "1996-01-29T00:00:00Z", ExpirationDate: "2025-01-29T00:00:00Z", UpdatedDate: "2024-02-14T00:52:14Z",
+			Statuses: []string{"ok"}, NameServers: []string{"ns1.example.it", "ns2.example.it"}, DNSSEC: "unsigned",
+		}},
+		{"nl", WhoisResponse{
+			DomainName: "example.nl", Registrar: "Example Registrar B.V.",
+			CreationDate: "1999-05-27T00:00:00Z", UpdatedDate: "2023-01-01T00:00:00Z",
+			Statuses: []string{"active"}, NameServers: []string{"ns1.example.nl", "ns2.example.nl"}, DNSSEC: "signedDelegation",
+		}},
+		{"eu", WhoisResponse{
+			DomainName: "example.eu", Registrar: "Example Registrar SA", TechOrganization: "Example Registrar SA",
+			NameServers: []string{"ns1.example.eu", "ns2.example.eu"}, DNSSEC: "signedDelegation",
+		}},
+		{"ru", WhoisResponse{
+			DomainName: "EXAMPLE.RU", Registrar: "RU-CENTER-RU", RegistrantOrganization: "Example LLC",
+			CreationDate: "1997-11-28T12:00:00Z", ExpirationDate: "2024-12-01T21:00:00Z",
+			Statuses: []string{"REGISTERED", "DELEGATED", "VERIFIED"}, NameServers: []string{"ns1.example.ru", "ns2.example.ru"},
+		}},
+		{"au", WhoisResponse{
+			DomainName: "example.com.au", Registrar: "Example Registrar Pty Ltd", RegistrantOrganization: "Example Pty Ltd",
+			RegistrarWhoisServer: "whois.auda.org.au", UpdatedDate: "2024-07-19T03:32:51Z",
+			AbuseEmail: "abuse@example-registrar.com.au", AbusePhone: "+61.390000000",
+			Statuses: []string{"serverRenewProhibited"}, NameServers: []string{"ns1.example.com.au", "ns2.example.com.au"}, DNSSEC: "unsigned",
+		}},
+		{"pl", WhoisResponse{
+			DomainName: "example.pl", Registrar: "Example Registrar Sp. z o.o.",
+			CreationDate: "2001-04-17T13:00:00Z", ExpirationDate: "2025-04-16T13:00:00Z", UpdatedDate: "2024-04-01T10:11:12Z",
+			NameServers: []string{"ns1.example.pl", "ns2.example.pl"}, DNSSEC: "unsigned",
+		}},
+		{"cn", WhoisResponse{
+			DomainName: "example.cn", Registrar: "Example Registrar Co., Ltd.", RegistrantOrganization: "Example Technology Co., Ltd.",
+			CreationDate: "2003-03-17T04:20:05Z", ExpirationDate: "2025-03-17T04:48:36Z",
+			Statuses: []string{"clientDeleteProhibited", "clientTransferProhibited"}, NameServers: []string{"ns1.example.cn", "ns2.example.cn"},
+			DNSSEC: "unsigned",
+		}},
+		{"kr", WhoisResponse{
+			DomainName: "example.kr", Registrar: "Example Registrar Co., Ltd.", RegistrantOrganization: "Example Inc.",
+			CreationDate: "2007-02-13T00:00:00Z", ExpirationDate: "2025-02-13T00:00:00Z", UpdatedDate: "2023-01-30T00:00:00Z",
+			NameServers: []string{"ns1.example.kr", "ns2.example.kr"}, DNSSEC: "unsigned",
+		}},
+		{"ch", WhoisResponse{
+			DomainName: "example.ch", Registrar: "Example Registrar AG", RegistrantOrganization: "Example AG",
+			CreationDate: "1996-01-01T00:00:00Z", NameServers: []string{"ns1.example.ch", "ns2.example.ch"}, DNSSEC: "signedDelegation",
+		}},
+		{"se", WhoisResponse{
+			DomainName: "example.se", Registrar: "Example Registrar AB",
+			CreationDate: "2000-01-01T00:00:00Z", ExpirationDate: "2025-01-01T00:00:00Z", UpdatedDate: "2024-01-01T00:00:00Z",
+			Statuses: []string{"active", "serverUpdateProhibited"}, NameServers: []string{"ns1.example.se", "ns2.example.se"},
+			DNSSEC: "signedDelegation",
+		}},
+		{"be", WhoisResponse{
+			DomainName: "example.be", Registrar: "Example Registrar NV", TechOrganization: "Example Hosting NV",
+			CreationDate: "2000-12-12T00:00:00Z", Statuses: []string{"clientTransferProhibited"},
+			NameServers: []string{"ns1.example.be", "ns2.example.be"}, DNSSEC: "signedDelegation",
+		}},
+		{"at", WhoisResponse{
+			DomainName: "example.at", Registrar: "Example Registrar GmbH", UpdatedDate: "2024-01-01T12:00:00Z",
+			NameServers: []string{"ns1.example.at", "ns2.example.at"},
+		}},
+		{"dk", WhoisResponse{
+			DomainName: "example.dk", CreationDate: "1998-01-19T00:00:00Z", ExpirationDate: "2025-03-31T00:00:00Z",
+			Statuses: []string{"Active"}, NameServers: []string{"ns1.example.dk", "ns2.example.dk"}, DNSSEC: "signedDelegation",
+		}},
+	} {
+		raw, err := os.ReadFile(filepath.Join("testdata", "whois", tc.tld+".txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		wir, err := ParseResponseWithTLD(raw, tc.tld)
+		if err != nil {
+			t.Errorf("%s: %v", tc.tld, err)
+			continue
+		}
+		got := WhoisResponse{
+			DomainName: wir.DomainName, Registrar: wir.Registrar, RegistrantOrganization: wir.RegistrantOrganization,
+			AdminOrganization: wir.AdminOrganization, TechOrganization: wir.TechOrganization,
+			RegistrarWhoisServer: wir.RegistrarWhoisServer, AbuseEmail: wir.AbuseEmail, AbusePhone: wir.AbusePhone,
+			CreationDate: wir.CreationDate, ExpirationDate: wir.ExpirationDate, UpdatedDate: wir.UpdatedDate,
+			Statuses: wir.Statuses, NameServers: wir.NameServers, DNSSEC: wir.DNSSEC,
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", tc.tld, got, tc.want)
+		}
+	}
+}
+
+func TestRegisterParser(t *testing.T) {
+	defer func(p ParserFunc) { tldParsers["test"] = p }(tldParsers["test"])
+	RegisterParser(".TEST", func(raw []byte) (*WhoisResponse, error) {
+		return &WhoisResponse{DomainName: string(raw), CreationDate: "2020/01/02"}, nil
+	})
+	wir, err := ParseResponseWithTLD([]byte("example.test"), "test")
+	if err != nil || wir.DomainName != "example.test" || wir.CreationDate != "2020-01-02T00:00:00Z" {
+		t.Errorf("ParseResponseWithTLD = %+v, %v", wir, err)
+	}
+}
+
+func TestTLDParserExtra(t *testing.T) {
+	for _, tc := range []struct {
+		tld, key string
+		want     []string
+	}{
+		{"pl", "option created", []string{"2020.01.01 00:00:00"}},
+		{"it", "registrant address", []string{"Via Esempio 1", "Roma", "00100", "RM", "IT"}},
+		{"br", "nic-hdl-br", []string{"EXA123"}},
+		{"fr", "hold", []string{"NO"}},
+	} {
+		raw, err := os.ReadFile(filepath.Join("testdata", "whois", tc.tld+".txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		wir, err := ParseResponseWithTLD(raw, tc.tld)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := wir.Extra[tc.key]; !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: Extra[%q] = %q, want %q", tc.tld, tc.key, got, tc.want)
+		}
+	}
+}
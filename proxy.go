@@ -0,0 +1,151 @@
+package qwis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+type forwardDialer DialFunc
+
+func (d forwardDialer) Dial(network, address string) (net.Conn, error) {
+	return d(context.Background(), network, address)
+}
+
+func (d forwardDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return d(ctx, network, address)
+}
+
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func proxyAddress(u *url.URL, defaultPort string) string {
+	if len(u.Port()) != 0 {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
+
+func httpConnectDial(u *url.URL, forward DialFunc) DialFunc {
+	defaultPort := "80"
+	if u.Scheme == "https" {
+		defaultPort = "443"
+	}
+	address := proxyAddress(u, defaultPort)
+	return func(ctx context.Context, network, target string) (net.Conn, error) {
+		conn, err := forward(ctx, "tcp", address)
+		if err != nil {
+			return nil, err
+		}
+		if dl, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(dl)
+		}
+		if u.Scheme == "https" {
+			tc := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+			if err = tc.HandshakeContext(ctx); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			conn = tc
+		}
+		req := &http.Request{
+			Method: http.MethodConnect,
+			URL:    &url.URL{Opaque: target},
+			Host:   target,
+			Header: http.Header{},
+		}
+		if u.User != nil {
+			pw, _ := u.User.Password()
+			creds := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + pw))
+			req.Header.Set("Proxy-Authorization", "Basic "+creds)
+		}
+		if err = req.Write(conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		br := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(br, req)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		// A successful CONNECT has no body; the tunnel starts right away.
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			conn.Close()
+			return nil, fmt.Errorf("proxy %s refused CONNECT: %s", u.Host, resp.Status)
+		}
+		conn.SetDeadline(time.Time{})
+		return bufferedConn{conn, br}, nil
+	}
+}
+
+// ProxyDial returns a DialFunc tunneling connections through a socks5://,
+// http:// or https:// proxy reached with forward.
+func ProxyDial(proxyURL string, forward DialFunc) (DialFunc, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("ProxyDial: %s", err)
+	}
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if u.User != nil {
+			pw, _ := u.User.Password()
+			auth = &proxy.Auth{User: u.User.Username(), Password: pw}
+		}
+		d, err := proxy.SOCKS5("tcp", proxyAddress(u, "1080"), auth, forwardDialer(forward))
+		if err != nil {
+			return nil, fmt.Errorf("ProxyDial: %s", err)
+		}
+		return d.(proxy.ContextDialer).DialContext, nil
+	case "http", "https":
+		return httpConnectDial(u, forward), nil
+	}
+	return nil, fmt.Errorf("ProxyDial: unsupported proxy scheme %q", u.Scheme)
+}
+
+// SetProxy routes whois connections and RDAP requests through proxyURL.
+func SetProxy(proxyURL string) error {
+	d, err := ProxyDial(proxyURL, Dial)
+	if err != nil {
+		return err
+	}
+	u, _ := url.Parse(proxyURL)
+	Dial = d
+	setRDAPTransport(func(t *http.Transport) { t.Proxy = http.ProxyURL(u) })
+	return nil
+}
+
+// setRDAPTransport replaces RDAPClient with a copy whose transport is
+// changed by set, keeping the client's other settings.
+func setRDAPTransport(set func(t *http.Transport)) {
+	RDAPClient = changeTransport(RDAPClient, set)
+}
+
+// changeTransport returns a copy of hc whose transport is changed by set.
+func changeTransport(hc *http.Client, set func(t *http.Transport)) *http.Client {
+	t, ok := hc.Transport.(*http.Transport)
+	if !ok {
+		t = http.DefaultTransport.(*http.Transport)
+	}
+	t = t.Clone()
+	set(t)
+	c := *hc
+	c.Transport = t
+	return &c
+}
@@ -0,0 +1,100 @@
+package qwis
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/pkorotkov/qwis/internal/whoistest"
+)
+
+// mockProxy is an HTTP forward proxy that also tunnels CONNECT requests,
+// recording the method and host of every request it handles.
+type mockProxy struct {
+	sync.Mutex
+	requests []string
+}
+
+func (p *mockProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.Lock()
+	p.requests = append(p.requests, r.Method+" "+r.Host)
+	p.Unlock()
+	if r.Method == http.MethodConnect {
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		io.WriteString(conn, "HTTP/1.1 200 OK\r\n\r\n")
+		go func() {
+			io.Copy(upstream, conn)
+			upstream.Close()
+		}()
+		io.Copy(conn, upstream)
+		conn.Close()
+		return
+	}
+	r.RequestURI = ""
+	resp, err := http.DefaultTransport.RoundTrip(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+func TestSetProxyRDAP(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		start  func(*testing.T, string, map[string]string) *httptest.Server
+		method string
+	}{
+		{"http", useRDAPServer, http.MethodGet},
+		{"https", useRDAPTLSServer, http.MethodConnect},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			useDial(t, (&whoistest.FakeServers{}).Dial)
+			srv := tc.start(t, "com", map[string]string{
+				"/rdap/domain/example.com": `{"ldhName":"EXAMPLE.COM","status":["active"]}`,
+			})
+			p := &mockProxy{}
+			ps := httptest.NewServer(p)
+			defer ps.Close()
+			if err := SetProxy(ps.URL); err != nil {
+				t.Fatal(err)
+			}
+			wir, err := RDAPContext(context.Background(), "example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			RDAPClient.CloseIdleConnections()
+			if wir.DomainName != "EXAMPLE.COM" {
+				t.Errorf("DomainName = %q", wir.DomainName)
+			}
+			p.Lock()
+			defer p.Unlock()
+			if len(p.requests) == 0 {
+				t.Fatal("no requests went through the proxy")
+			}
+			for _, r := range p.requests {
+				if r != tc.method+" "+srv.Listener.Addr().String() {
+					t.Errorf("proxied %q, want %s to %s", r, tc.method, srv.Listener.Addr())
+				}
+			}
+		})
+	}
+}
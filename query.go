@@ -0,0 +1,85 @@
+package qwis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+const domainPlaceholder = "{domain}"
+
+var queryTemplates = map[string]string{
+	"com": "={domain}",
+	"net": "={domain}",
+	"de":  "-T dn,ace {domain}",
+	"jp":  "{domain}/e",
+}
+
+var queryTemplateOverrides struct {
+	sync.Mutex
+	m map[string]string
+}
+
+// SetQueryTemplate sets the query sent for domains under a TLD, e.g.
+// "-T dn,ace {domain}". An empty template restores the built-in one.
+func SetQueryTemplate(tld, tmpl string) error {
+	if len(tmpl) != 0 && !strings.Contains(tmpl, domainPlaceholder) {
+		return fmt.Errorf("SetQueryTemplate: template %q lacks %s", tmpl, domainPlaceholder)
+	}
+	tld = strings.ToLower(strings.TrimPrefix(tld, "."))
+	queryTemplateOverrides.Lock()
+	defer queryTemplateOverrides.Unlock()
+	if len(tmpl) == 0 {
+		delete(queryTemplateOverrides.m, tld)
+		return nil
+	}
+	if queryTemplateOverrides.m == nil {
+		queryTemplateOverrides.m = map[string]string{}
+	}
+	queryTemplateOverrides.m[tld] = tmpl
+	return nil
+}
+
+// ResetQueryTemplates restores the built-in query templates for every TLD.
+func ResetQueryTemplates() {
+	queryTemplateOverrides.Lock()
+	queryTemplateOverrides.m = nil
+	queryTemplateOverrides.Unlock()
+}
+
+// LoadQueryTemplates reads "tld template" lines and installs them as
+// overrides.
+func LoadQueryTemplates(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		l := strings.TrimSpace(sc.Text())
+		if len(l) == 0 || strings.HasPrefix(l, "#") {
+			continue
+		}
+		tld := strings.Fields(l)[0]
+		if err := SetQueryTemplate(tld, strings.TrimSpace(l[len(tld):])); err != nil {
+			return fmt.Errorf("LoadQueryTemplates: line %d: %s", n, err)
+		}
+	}
+	return sc.Err()
+}
+
+func queryTemplate(tld string) string {
+	queryTemplateOverrides.Lock()
+	tmpl, ok := queryTemplateOverrides.m[tld]
+	queryTemplateOverrides.Unlock()
+	if ok {
+		return tmpl
+	}
+	if tmpl, ok = queryTemplates[tld]; ok {
+		return tmpl
+	}
+	return domainPlaceholder
+}
+
+func getQuery(domainName string) []byte {
+	tmpl := queryTemplate(strings.ToLower(TopLevelDomain(domainName)))
+	return append([]byte(strings.ReplaceAll(tmpl, domainPlaceholder, domainName)), crlf...)
+}
@@ -0,0 +1,117 @@
+package qwis
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// QuirkObjectPrefix is the quirk of RWHOIS servers that start every line of
+// an answer with the object queried, as in "example.com:Registrar: R".
+const QuirkObjectPrefix = "object-prefix"
+
+// quirkRewrites undo the quirks by name, rewriting the answer to a query for
+// object into the "key: value" lines the parsers read.
+var quirkRewrites = map[string]func(raw []byte, object string) []byte{
+	QuirkObjectPrefix: stripObjectPrefix,
+}
+
+var serverQuirks struct {
+	sync.Mutex
+	m map[string][]string
+}
+
+// SetServerQuirks has the answers of server, a host with or without a port,
+// rewritten for quirks before they are parsed. No quirks removes the entry.
+func SetServerQuirks(server string, quirks ...string) error {
+	for _, q := range quirks {
+		if _, ok := quirkRewrites[q]; !ok {
+			return fmt.Errorf("SetServerQuirks: unknown quirk %q", q)
+		}
+	}
+	host := serverHost(server)
+	serverQuirks.Lock()
+	defer serverQuirks.Unlock()
+	if len(quirks) == 0 {
+		delete(serverQuirks.m, host)
+		return nil
+	}
+	if serverQuirks.m == nil {
+		serverQuirks.m = map[string][]string{}
+	}
+	serverQuirks.m[host] = append([]string(nil), quirks...)
+	return nil
+}
+
+// ResetServerQuirks forgets the quirks of every server.
+func ResetServerQuirks() {
+	serverQuirks.Lock()
+	serverQuirks.m = nil
+	serverQuirks.Unlock()
+}
+
+// LoadServerQuirks reads "server quirk,..." lines and sets them with
+// SetServerQuirks.
+func LoadServerQuirks(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		l := strings.TrimSpace(sc.Text())
+		if len(l) == 0 || strings.HasPrefix(l, "#") {
+			continue
+		}
+		fs := strings.Fields(l)
+		if len(fs) != 2 {
+			return fmt.Errorf("LoadServerQuirks: line %d: expected \"server quirk,...\"", n)
+		}
+		if err := SetServerQuirks(fs[0], strings.Split(fs[1], ",")...); err != nil {
+			return fmt.Errorf("LoadServerQuirks: line %d: %s", n, err)
+		}
+	}
+	return sc.Err()
+}
+
+// ServerQuirks returns the quirks set for server, sorted.
+func ServerQuirks(server string) []string {
+	serverQuirks.Lock()
+	quirks := append([]string(nil), serverQuirks.m[serverHost(server)]...)
+	serverQuirks.Unlock()
+	sort.Strings(quirks)
+	return quirks
+}
+
+// unquirk rewrites the answer server gave for object for the quirks set for
+// the server, if any.
+func unquirk(raw []byte, server, object string) []byte {
+	for _, q := range ServerQuirks(server) {
+		raw = quirkRewrites[q](raw, object)
+	}
+	return raw
+}
+
+// stripObjectPrefix drops object, and the colon or blanks after it, from the
+// start of the lines of raw that begin with it. Other lines are kept as they
+// are.
+func stripObjectPrefix(raw []byte, object string) []byte {
+	prefix := []byte(strings.TrimSuffix(object, "."))
+	if len(prefix) == 0 {
+		return raw
+	}
+	out := make([]byte, 0, len(raw))
+	for s := (lineScanner{rest: raw}); s.scan(); {
+		l := s.line
+		if len(l) > len(prefix) && bytes.EqualFold(l[:len(prefix)], prefix) {
+			if rest := l[len(prefix):]; rest[0] == ':' || rest[0] == ' ' || rest[0] == '\t' {
+				l = bytes.TrimLeft(rest[1:], " \t")
+			}
+		}
+		out = append(out, l...)
+		if !s.done {
+			out = append(out, '\n')
+		}
+	}
+	return out
+}
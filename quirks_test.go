@@ -0,0 +1,67 @@
+package qwis
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pkorotkov/qwis/internal/whoistest"
+)
+
+const prefixedAnswer = "example.com:Domain Name: EXAMPLE.COM\r\n" +
+	"example.com:Registrar: Example Registrar, Inc.\r\n" +
+	"EXAMPLE.COM: Registry Expiry Date: 2026-08-13T04:00:00Z\r\n" +
+	"example.com:Name Server: A.IANA-SERVERS.NET\r\n" +
+	"example.com:Name Server: B.IANA-SERVERS.NET\r\n" +
+	"%ok\r\n"
+
+func TestServerQuirks(t *testing.T) {
+	useDial(t, (&whoistest.FakeServers{Responses: map[string]string{"rwhois.example.test:4321": prefixedAnswer}}).Dial)
+	SetWhoisServer("com", "rwhois.example.test:4321")
+	t.Cleanup(ResetServerQuirks)
+	if wir, err := WhoisContext(context.Background(), "example.com"); err == nil && len(wir.Registrar) != 0 {
+		t.Fatalf("prefixed answer parsed without the quirk: %+v", wir)
+	}
+	if err := LoadServerQuirks(strings.NewReader("# RWHOIS\nRWHOIS.example.test object-prefix\n")); err != nil {
+		t.Fatal(err)
+	}
+	if q := ServerQuirks("rwhois.example.test:4321"); len(q) != 1 || q[0] != QuirkObjectPrefix {
+		t.Errorf("ServerQuirks = %q", q)
+	}
+	wir, err := WhoisContext(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wir.DomainName != "EXAMPLE.COM" || wir.Registrar != "Example Registrar, Inc." || wir.ExpirationDate != "2026-08-13T04:00:00Z" ||
+		strings.Join(wir.NameServers, ",") != "a.iana-servers.net,b.iana-servers.net" {
+		t.Errorf("parsed %+v", wir)
+	}
+	// Other servers' answers are parsed as they are.
+	if wir, err = ParseServerResponse([]byte(prefixedAnswer), "whois.verisign-grs.com:43", "example.com"); err == nil && len(wir.Registrar) != 0 {
+		t.Errorf("quirk applied to another server: %+v", wir)
+	}
+	if err := SetServerQuirks("rwhois.example.test", "bogus"); err == nil {
+		t.Error("unknown quirk accepted")
+	}
+	if err := LoadServerQuirks(strings.NewReader("rwhois.example.test\n")); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("malformed line: %v", err)
+	}
+	if SetServerQuirks("rwhois.example.test"); len(ServerQuirks("rwhois.example.test")) != 0 {
+		t.Error("quirks not removed")
+	}
+}
+
+func TestStripObjectPrefix(t *testing.T) {
+	for raw, want := range map[string]string{
+		"example.com:Registrar: R\n":  "Registrar: R\n",
+		"example.com\tRegistrar: R\n": "Registrar: R\n",
+		"Example.Com: Registrar: R":   "Registrar: R",
+		"example.community: x\n":      "example.community: x\n",
+		"Domain: example.com\n\n":     "Domain: example.com\n\n",
+		"example.com":                 "example.com",
+	} {
+		if got := string(stripObjectPrefix([]byte(raw), "example.com.")); got != want {
+			t.Errorf("stripObjectPrefix(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package qwis
+
+import (
+	"context"
+	"fmt"
+)
+
+// Values of WhoisResponse.Source.
+const (
+	SourceWhois = "whois"
+	SourceRDAP  = "rdap"
+)
+
+// RaceSourcesContext looks domainName up over whois and RDAP at once and
+// returns the first response that names a domain, cancelling the other
+// lookup. The response's Source tells which lookup won. When neither
+// succeeds the whois error is returned.
+func RaceSourcesContext(ctx context.Context, domainName string) (*WhoisResponse, error) {
+	return raceLookups(ctx, domainName, []string{SourceWhois, SourceRDAP}, []LookupFunc{WhoisContext, RDAPContext})
+}
+
+func RaceSources(domainName string) (*WhoisResponse, error) {
+	return RaceSourcesContext(context.Background(), domainName)
+}
+
+func raceLookups(ctx context.Context, domainName string, sources []string, lookups []LookupFunc) (*WhoisResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		i   int
+		wir *WhoisResponse
+		err error
+	}
+	results := make(chan result, len(lookups))
+	for i, lookup := range lookups {
+		go func(i int, lookup LookupFunc) {
+			wir, err := lookup(ctx, domainName)
+			results <- result{i, wir, err}
+		}(i, lookup)
+	}
+	errs := make([]error, len(lookups))
+	for range lookups {
+		r := <-results
+		if r.err == nil && len(r.wir.DomainName) != 0 {
+			r.wir.Source = sources[r.i]
+			return r.wir, nil
+		}
+		errs[r.i] = r.err
+		if errs[r.i] == nil {
+			errs[r.i] = fmt.Errorf("RaceSources: %w: %s answer names no domain", ErrParse, sources[r.i])
+		}
+	}
+	return nil, errs[0]
+}
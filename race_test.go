@@ -0,0 +1,85 @@
+package qwis
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRaceLookups(t *testing.T) {
+	cancelled := make(chan struct{})
+	slow := func(ctx context.Context, dn string) (*WhoisResponse, error) {
+		select {
+		case <-ctx.Done():
+			close(cancelled)
+			return nil, ctx.Err()
+		case <-time.After(5 * time.Second):
+			return &WhoisResponse{DomainName: "slow." + dn}, nil
+		}
+	}
+	fast := func(ctx context.Context, dn string) (*WhoisResponse, error) {
+		return &WhoisResponse{DomainName: dn}, nil
+	}
+	wir, err := raceLookups(context.Background(), "example.com", []string{SourceWhois, SourceRDAP}, []LookupFunc{slow, fast})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wir.DomainName != "example.com" || wir.Source != SourceRDAP {
+		t.Errorf("got %q from %q, want example.com from rdap", wir.DomainName, wir.Source)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("slow lookup not cancelled")
+	}
+}
+
+func TestRaceLookupsSkipsEmptyAnswer(t *testing.T) {
+	empty := func(ctx context.Context, dn string) (*WhoisResponse, error) {
+		return &WhoisResponse{}, nil
+	}
+	later := func(ctx context.Context, dn string) (*WhoisResponse, error) {
+		time.Sleep(10 * time.Millisecond)
+		return &WhoisResponse{DomainName: dn}, nil
+	}
+	wir, err := raceLookups(context.Background(), "example.com", []string{SourceWhois, SourceRDAP}, []LookupFunc{empty, later})
+	if err != nil || wir.Source != SourceRDAP {
+		t.Errorf("got %+v, %v; want the rdap answer", wir, err)
+	}
+	if _, err = raceLookups(context.Background(), "example.com", []string{SourceWhois}, []LookupFunc{empty}); err == nil {
+		t.Error("empty answer accepted")
+	}
+}
+
+func TestRaceSourcesContext(t *testing.T) {
+	// The whois server never answers; RDAP does at once and must win, and
+	// the whois lookup must be cancelled rather than left to time out.
+	cancelled := make(chan struct{})
+	useDial(t, func(ctx context.Context, network, address string) (net.Conn, error) {
+		<-ctx.Done()
+		if address == "whois.verisign-grs.com:43" {
+			close(cancelled)
+		}
+		return nil, ctx.Err()
+	})
+	SetWhoisServer("com", "whois.verisign-grs.com")
+	t.Cleanup(ResetWhoisServers)
+	useRDAPServer(t, "com", map[string]string{
+		"/rdap/domain/example.com": `{"ldhName":"EXAMPLE.COM"}`,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	wir, err := RaceSourcesContext(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wir.DomainName != "EXAMPLE.COM" || wir.Source != SourceRDAP {
+		t.Errorf("got %q from %q, want EXAMPLE.COM from rdap", wir.DomainName, wir.Source)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("whois lookup not cancelled")
+	}
+}
@@ -0,0 +1,84 @@
+package qwis
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimitPolicy spaces out outbound queries so that batches do not get
+// the source address banned. Rates are in queries per second; zero leaves
+// them unlimited. Queries over the rate wait for their turn, and answers
+// from ResponseCache do not count.
+type RateLimitPolicy struct {
+	// QPS is the rate of queries to all servers together.
+	QPS float64
+	// PerServerQPS is the rate of queries to any one whois server or RDAP
+	// host.
+	PerServerQPS float64
+}
+
+var RateLimit RateLimitPolicy
+
+// rateSlots hold the earliest time the next query may go out, overall and
+// per server.
+type rateSlots struct {
+	sync.Mutex
+	all     time.Time
+	servers map[string]time.Time
+}
+
+func newRateSlots() *rateSlots {
+	return &rateSlots{servers: map[string]time.Time{}}
+}
+
+// packageRateSlots are those of RateLimit; every Client has its own.
+var packageRateSlots = newRateSlots()
+
+func interval(qps float64) time.Duration {
+	return time.Duration(float64(time.Second) / qps)
+}
+
+// waitRateLimit blocks until RateLimit, or the policy of the Client ctx is
+// for, lets a query go out to server, or ctx is done.
+func waitRateLimit(ctx context.Context, server string) error {
+	p, rateSlots := rateLimit(ctx)
+	if p.QPS <= 0 && p.PerServerQPS <= 0 {
+		return nil
+	}
+	now := time.Now()
+	rateSlots.Lock()
+	at := now
+	if p.QPS > 0 && rateSlots.all.After(at) {
+		at = rateSlots.all
+	}
+	if p.PerServerQPS > 0 {
+		if len(rateSlots.servers) > 4096 {
+			for s, t := range rateSlots.servers {
+				if t.Before(now) {
+					delete(rateSlots.servers, s)
+				}
+			}
+		}
+		if t := rateSlots.servers[server]; t.After(at) {
+			at = t
+		}
+		rateSlots.servers[server] = at.Add(interval(p.PerServerQPS))
+	}
+	if p.QPS > 0 {
+		rateSlots.all = at.Add(interval(p.QPS))
+	}
+	rateSlots.Unlock()
+	d := at.Sub(now)
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,66 @@
+package qwis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pkorotkov/qwis/internal/whoistest"
+)
+
+func useRateLimit(t *testing.T, p RateLimitPolicy) {
+	t.Helper()
+	RateLimit = p
+	packageRateSlots.Lock()
+	packageRateSlots.all, packageRateSlots.servers = time.Time{}, map[string]time.Time{}
+	packageRateSlots.Unlock()
+	t.Cleanup(func() { RateLimit = RateLimitPolicy{} })
+}
+
+func TestWaitRateLimit(t *testing.T) {
+	ctx := context.Background()
+	useRateLimit(t, RateLimitPolicy{QPS: 20})
+	start := time.Now()
+	for _, s := range []string{"a:43", "b:43", "c:43"} {
+		if err := waitRateLimit(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d < 90*time.Millisecond {
+		t.Errorf("3 queries at 20 QPS took %s, want at least 100ms", d)
+	}
+
+	useRateLimit(t, RateLimitPolicy{PerServerQPS: 2})
+	start = time.Now()
+	for _, s := range []string{"a:43", "b:43", "c:43"} {
+		if err := waitRateLimit(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("queries to distinct servers waited %s", d)
+	}
+	cctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := waitRateLimit(cctx, "a:43"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("second query to a:43 = %v, want the context's error", err)
+	}
+}
+
+func TestWhoisRateLimited(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": "Domain Name: EXAMPLE.COM\r\n"}}
+	useDial(t, fs.Dial)
+	FollowReferrals = false
+	defer func() { FollowReferrals = true }()
+	useRateLimit(t, RateLimitPolicy{PerServerQPS: 10})
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := Whois("example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d < 190*time.Millisecond {
+		t.Errorf("3 queries at 10 QPS took %s, want at least 200ms", d)
+	}
+}
@@ -0,0 +1,326 @@
+package qwis
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	RDAPBootstrapURL = "https://data.iana.org/rdap/dns.json"
+	RDAPClient       = &http.Client{}
+)
+
+// DefaultRDAPOnlyTLDs lists TLDs launched as RDAP-only.
+var DefaultRDAPOnlyTLDs = []string{"app", "dev"}
+
+// RDAPOnlyTLDs are looked up over RDAP by Whois and IsAvailable, skipping
+// port 43 entirely.
+var RDAPOnlyTLDs = DefaultRDAPOnlyTLDs
+
+func IsRDAPOnly(tld string) bool {
+	tld = strings.TrimPrefix(tld, ".")
+	for _, t := range RDAPOnlyTLDs {
+		if strings.EqualFold(strings.TrimPrefix(t, "."), tld) {
+			return true
+		}
+	}
+	return false
+}
+
+var rdapBootstrap struct {
+	sync.Mutex
+	url      string
+	services map[string][]string
+}
+
+type rdapBootstrapFile struct {
+	Services [][][]string `json:"services"`
+}
+
+type rdapEntity struct {
+	Roles      []string          `json:"roles"`
+	VCardArray []json.RawMessage `json:"vcardArray"`
+	PublicIDs  []struct {
+		Type       string `json:"type"`
+		Identifier string `json:"identifier"`
+	} `json:"publicIds"`
+	Entities []rdapEntity `json:"entities"`
+}
+
+type rdapDomain struct {
+	LDHName  string   `json:"ldhName"`
+	Statuses []string `json:"status"`
+	Events   []struct {
+		Action string `json:"eventAction"`
+		Date   string `json:"eventDate"`
+	} `json:"events"`
+	Entities    []rdapEntity `json:"entities"`
+	Nameservers []struct {
+		LDHName string `json:"ldhName"`
+	} `json:"nameservers"`
+	SecureDNS *struct {
+		DelegationSigned bool `json:"delegationSigned"`
+	} `json:"secureDNS"`
+}
+
+func rdapGet(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	key, cache := "GET "+url, responseCache(ctx)
+	if cache != nil {
+		if body, ok := cacheGet(ctx, cache, key); ok {
+			logEvent(ctx, slog.LevelDebug, "cache hit", "url", url, "received", len(body))
+			hookCacheHit(ctx, req.URL.Host)
+			return body, nil
+		}
+	}
+	req.Header.Set("Accept", "application/rdap+json, application/json")
+	// Set by hand, the header keeps the Transport from decompressing
+	// answers itself; gzip is then undone below, also for servers that
+	// send it unasked and for clients with DisableCompression.
+	req.Header.Set("Accept-Encoding", "gzip")
+	if err = waitRateLimit(ctx, req.URL.Host); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := rdapClient(ctx).Do(req)
+	if err != nil {
+		elapsed := time.Since(start)
+		logEvent(ctx, slog.LevelInfo, "query failed", "url", url, "elapsed", elapsed, "err", err)
+		err = &ServerError{req.URL.Host, fmt.Errorf("%w: %s", ErrServerUnavailable, err)}
+		hookQuery(ctx, req.URL.Host, elapsed, err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var r io.Reader = resp.Body
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") && !resp.Uncompressed {
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, &ServerError{req.URL.Host, fmt.Errorf("%w: bad gzip body: %v", ErrParse, err)}
+		}
+		defer zr.Close()
+		r = zr
+	}
+	// The bound applies to the answer decompressed.
+	limit := maxResponseSize(ctx)
+	if limit > 0 {
+		r = io.LimitReader(r, limit+1)
+	}
+	body, err := io.ReadAll(r)
+	if err == nil && limit > 0 && int64(len(body)) > limit {
+		err = errTooLarge(req.URL.Host, limit)
+	}
+	elapsed := time.Since(start)
+	hookQuery(ctx, req.URL.Host, elapsed, err)
+	logEvent(ctx, slog.LevelInfo, "query", "url", url, "status", resp.StatusCode, "received", len(body), "elapsed", elapsed)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s returned %s", ErrNoSuchDomain, url, resp.Status)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return nil, &ServerError{req.URL.Host, ErrRateLimited}
+	case resp.StatusCode >= 500:
+		return nil, &ServerError{req.URL.Host, fmt.Errorf("%w: %s", ErrServerUnavailable, resp.Status)}
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	if cache != nil {
+		cacheSet(ctx, cache, key, body)
+	}
+	return body, nil
+}
+
+func rdapBaseURLs(ctx context.Context, tld string) ([]string, error) {
+	rdapBootstrap.Lock()
+	defer rdapBootstrap.Unlock()
+	if rdapBootstrap.services == nil || rdapBootstrap.url != RDAPBootstrapURL {
+		body, err := rdapGet(ctx, RDAPBootstrapURL)
+		if err != nil {
+			return nil, err
+		}
+		var bf rdapBootstrapFile
+		if err = json.Unmarshal(body, &bf); err != nil {
+			return nil, err
+		}
+		rdapBootstrap.url, rdapBootstrap.services = RDAPBootstrapURL, map[string][]string{}
+		for _, s := range bf.Services {
+			if len(s) != 2 {
+				continue
+			}
+			for _, t := range s[0] {
+				rdapBootstrap.services[strings.ToLower(t)] = s[1]
+			}
+		}
+	}
+	urls, ok := rdapBootstrap.services[strings.ToLower(tld)]
+	if !ok || len(urls) == 0 {
+		return nil, fmt.Errorf("%w: no RDAP service registered for .%s", ErrUnsupportedTLD, tld)
+	}
+	return urls, nil
+}
+
+func rdapStatus(s string) string {
+	if s == "active" {
+		return "ok"
+	}
+	ws := strings.Fields(s)
+	for i := 1; i < len(ws); i++ {
+		ws[i] = strings.ToUpper(ws[i][:1]) + ws[i][1:]
+	}
+	return strings.Join(ws, "")
+}
+
+func (e *rdapEntity) vcardProperty(name string) json.RawMessage {
+	if len(e.VCardArray) != 2 {
+		return nil
+	}
+	var props [][]json.RawMessage
+	if json.Unmarshal(e.VCardArray[1], &props) != nil {
+		return nil
+	}
+	for _, p := range props {
+		var pn string
+		if len(p) >= 4 && json.Unmarshal(p[0], &pn) == nil && pn == name {
+			return p[3]
+		}
+	}
+	return nil
+}
+
+func (e *rdapEntity) vcardText(name string) string {
+	var v string
+	json.Unmarshal(e.vcardProperty(name), &v)
+	return strings.TrimPrefix(strings.TrimPrefix(v, "mailto:"), "tel:")
+}
+
+func (e *rdapEntity) country() string {
+	var adr []interface{}
+	if json.Unmarshal(e.vcardProperty("adr"), &adr) != nil || len(adr) < 7 {
+		return ""
+	}
+	c, _ := adr[6].(string)
+	return c
+}
+
+func (e *rdapEntity) organization() string {
+	if org := e.vcardText("org"); len(org) != 0 {
+		return org
+	}
+	return e.vcardText("fn")
+}
+
+func hasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func ParseRDAPResponse(raw []byte) (*WhoisResponse, error) {
+	var d rdapDomain
+	if err := json.Unmarshal(raw, &d); err != nil {
+		return nil, fmt.Errorf("ParseRDAPResponse: %w: %s", ErrParse, err)
+	}
+	r := &WhoisResponse{rawText: raw, DomainName: d.LDHName}
+	for _, st := range d.Statuses {
+		r.Statuses = append(r.Statuses, rdapStatus(st))
+	}
+	for _, ev := range d.Events {
+		switch ev.Action {
+		case "registration":
+			r.CreationDate = ev.Date
+		case "expiration":
+			r.ExpirationDate = ev.Date
+		case "last changed":
+			r.UpdatedDate = ev.Date
+		}
+	}
+	for i := range d.Entities {
+		e := &d.Entities[i]
+		switch {
+		case hasRole(e.Roles, "registrar"):
+			r.Registrar = e.vcardText("fn")
+			for _, id := range e.PublicIDs {
+				if id.Type == "IANA Registrar ID" {
+					r.RegistrarIANAID = id.Identifier
+				}
+			}
+			for j := range e.Entities {
+				if ae := &e.Entities[j]; hasRole(ae.Roles, "abuse") {
+					r.AbuseEmail, r.AbusePhone = ae.vcardText("email"), ae.vcardText("tel")
+				}
+			}
+		case hasRole(e.Roles, "registrant"):
+			r.RegistrantOrganization, r.RegistrantCountry = e.organization(), e.country()
+		case hasRole(e.Roles, "administrative"):
+			r.AdminOrganization, r.AdminCountry = e.organization(), e.country()
+		case hasRole(e.Roles, "technical"):
+			r.TechOrganization, r.TechCountry = e.organization(), e.country()
+		}
+	}
+	for _, ns := range d.Nameservers {
+		r.NameServers = append(r.NameServers, strings.ToLower(ns.LDHName))
+	}
+	if d.SecureDNS != nil {
+		r.DNSSEC = "unsigned"
+		if d.SecureDNS.DelegationSigned {
+			r.DNSSEC = "signedDelegation"
+		}
+	}
+	r.normalizeDates("")
+	r.fillDomainForms()
+	r.fillStatusCodes()
+	r.tagFields(sourceRDAP, func(int) bool { return true })
+	return r, nil
+}
+
+func RDAPRawContext(ctx context.Context, domainName string) ([]byte, error) {
+	re := func(e error) error {
+		return fmt.Errorf("RDAP: %w", e)
+	}
+	domainName, err := asciiDomainName(domainName)
+	if err != nil {
+		return nil, re(err)
+	}
+	urls, err := rdapBaseURLs(ctx, TopLevelDomain(domainName))
+	if err != nil {
+		return nil, re(err)
+	}
+	for _, u := range urls {
+		var raw []byte
+		if raw, err = rdapGet(ctx, strings.TrimSuffix(u, "/")+"/domain/"+domainName); err == nil {
+			return raw, nil
+		}
+	}
+	return nil, re(err)
+}
+
+func RDAPRaw(domainName string) ([]byte, error) {
+	return RDAPRawContext(context.Background(), domainName)
+}
+
+func RDAPContext(ctx context.Context, domainName string) (*WhoisResponse, error) {
+	raw, err := RDAPRawContext(ctx, domainName)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRDAPResponse(raw)
+}
+
+func RDAP(domainName string) (*WhoisResponse, error) {
+	return RDAPContext(context.Background(), domainName)
+}
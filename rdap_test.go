@@ -0,0 +1,114 @@
+package qwis
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkorotkov/qwis/internal/whoistest"
+)
+
+// useRDAPServer points RDAP bootstrap for tld at a test server serving the
+// domain objects in domains by path.
+func useRDAPServer(t *testing.T, tld string, domains map[string]string) *httptest.Server {
+	t.Helper()
+	return startRDAPServer(t, httptest.NewServer, tld, domains)
+}
+
+// useRDAPTLSServer is useRDAPServer over https.
+func useRDAPTLSServer(t *testing.T, tld string, domains map[string]string) *httptest.Server {
+	t.Helper()
+	return startRDAPServer(t, httptest.NewTLSServer, tld, domains)
+}
+
+func startRDAPServer(t *testing.T, start func(http.Handler) *httptest.Server, tld string, domains map[string]string) *httptest.Server {
+	var srv *httptest.Server
+	srv = start(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/dns.json" {
+			fmt.Fprintf(w, `{"services":[[["%s"],["%s/rdap/"]]]}`, tld, srv.URL)
+			return
+		}
+		d, ok := domains[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/rdap+json")
+		fmt.Fprint(w, d)
+	}))
+	bootstrapURL, client := RDAPBootstrapURL, RDAPClient
+	RDAPBootstrapURL, RDAPClient = srv.URL+"/dns.json", srv.Client()
+	t.Cleanup(func() {
+		srv.Close()
+		RDAPBootstrapURL, RDAPClient = bootstrapURL, client
+	})
+	return srv
+}
+
+func TestRDAPOnlyTLD(t *testing.T) {
+	fs := &whoistest.FakeServers{}
+	useDial(t, fs.Dial)
+	useRDAPServer(t, "dev", map[string]string{
+		"/rdap/domain/example.dev": `{"ldhName":"example.dev","status":["active"]}`,
+	})
+	wir, err := WhoisContext(context.Background(), "example.dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wir.DomainName != "example.dev" || len(fs.Dialed) != 0 {
+		t.Errorf("domain %q, port 43 dials %q", wir.DomainName, fs.Dialed)
+	}
+	available, err := IsAvailableContext(context.Background(), "free.dev")
+	if err != nil || !available || len(fs.Dialed) != 0 {
+		t.Errorf("IsAvailable(free.dev) = %v, %v; port 43 dials %q", available, err, fs.Dialed)
+	}
+}
+
+func TestRDAPGzip(t *testing.T) {
+	srv := useRDAPServer(t, "dev", map[string]string{
+		"/rdap/domain/example.dev": `{"ldhName":"example.dev","status":["active"],"events":[{"eventAction":"expiration","eventDate":"2030-01-02T03:04:05Z"}]}`,
+	})
+	// The server compresses whatever the client asked for, as some do.
+	plain := srv.Config.Handler
+	accepted := make(chan string, 16)
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepted <- r.Header.Get("Accept-Encoding")
+		rec := httptest.NewRecorder()
+		plain.ServeHTTP(rec, r)
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(rec.Code)
+		zw := gzip.NewWriter(w)
+		zw.Write(rec.Body.Bytes())
+		zw.Close()
+	})
+	for _, hc := range []*http.Client{srv.Client(), {Transport: &http.Transport{DisableCompression: true}}} {
+		wir, err := NewClient(WithHTTPClient(hc)).RDAP(context.Background(), "example.dev")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wir.DomainName != "example.dev" || wir.ExpirationDate != "2030-01-02T03:04:05Z" {
+			t.Errorf("parsed %q expiring %q", wir.DomainName, wir.ExpirationDate)
+		}
+	}
+	for len(accepted) != 0 {
+		if ae := <-accepted; ae != "gzip" {
+			t.Errorf("Accept-Encoding = %q, want gzip", ae)
+		}
+	}
+}
+
+func TestRDAPMaxResponseSize(t *testing.T) {
+	useRDAPServer(t, "dev", map[string]string{
+		"/rdap/domain/example.dev": `{"ldhName":"example.dev","status":["active"],"remarks":[{"description":["` + strings.Repeat("x", 2000) + `"]}]}`,
+	})
+	defer func(n int64) { MaxResponseSize = n }(MaxResponseSize)
+	MaxResponseSize = 1000
+	if _, err := RDAPContext(context.Background(), "example.dev"); !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("RDAPContext = %v, want ErrResponseTooLarge", err)
+	}
+}
@@ -0,0 +1,51 @@
+package qwis
+
+import "strings"
+
+var redactionPhrases = []string{
+	"redacted",
+	"withheld",
+	"not disclosed",
+	"data protected",
+	"gdpr masked",
+	"statutory masking",
+	"non-public data",
+}
+
+func isRedacted(v string) bool {
+	v = strings.ToLower(v)
+	for _, p := range redactionPhrases {
+		if strings.Contains(v, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripRedacted clears contact organizations that only hold a privacy
+// placeholder. A redacted registrant organization is then taken from the
+// tech, or failing that the billing, contact, with a warning saying so.
+func (wir *WhoisResponse) stripRedacted() {
+	redacted := isRedacted(wir.RegistrantOrganization)
+	for _, org := range []*string{&wir.RegistrantOrganization, &wir.AdminOrganization, &wir.TechOrganization, &wir.BillingOrganization} {
+		if isRedacted(*org) {
+			*org = ""
+		}
+	}
+	if !redacted {
+		return
+	}
+	for _, c := range []struct {
+		contact, org string
+	}{
+		{"tech", wir.TechOrganization},
+		{"billing", wir.BillingOrganization},
+	} {
+		if len(c.org) != 0 {
+			wir.RegistrantOrganization = c.org
+			wir.setSource("registrant_organization", c.contact+"_organization")
+			wir.Warnings = append(wir.Warnings, "registrant organization is redacted; using the "+c.contact+" organization")
+			return
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package qwis
+
+import "testing"
+
+func TestRedactedRegistrantFallback(t *testing.T) {
+	wir, err := ParseResponse([]byte("Domain Name: example.com\n" +
+		"Registrant Organization: REDACTED FOR PRIVACY\n" +
+		"Admin Organization: Data Protected\n" +
+		"Tech Organization: Example Hosting GmbH\n" +
+		"Billing Organization: Example Billing Ltd\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wir.RegistrantOrganization != "Example Hosting GmbH" || len(wir.AdminOrganization) != 0 {
+		t.Errorf("registrant %q, admin %q", wir.RegistrantOrganization, wir.AdminOrganization)
+	}
+	if len(wir.Warnings) != 1 || wir.Warnings[0] != "registrant organization is redacted; using the tech organization" {
+		t.Errorf("Warnings = %q", wir.Warnings)
+	}
+}
+
+func TestUnredactedRegistrantKept(t *testing.T) {
+	wir, err := ParseResponse([]byte("Registrant Organization: Example Inc.\nTech Organization: Example Hosting GmbH\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wir.RegistrantOrganization != "Example Inc." || len(wir.Warnings) != 0 {
+		t.Errorf("registrant %q, warnings %q", wir.RegistrantOrganization, wir.Warnings)
+	}
+}
@@ -0,0 +1,263 @@
+package qwis
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+)
+
+type WhoisResponse struct {
+	rawText                []byte
+	DomainName             string              `json:"domain_name"`
+	ASCIIDomainName        string              `json:"ascii_domain_name,omitempty"`
+	UnicodeDomainName      string              `json:"unicode_domain_name,omitempty"`
+	Registrar              string              `json:"registrar"`
+	Statuses               []string            `json:"statuses"`
+	StatusCodes            []Status            `json:"status_codes,omitempty"`
+	CreationDate           string              `json:"creation_date"`
+	ExpirationDate         string              `json:"expiration_date"`
+	DaysUntilExpiry        *int                `json:"days_until_expiry,omitempty"`
+	UpdatedDate            string              `json:"updated_date"`
+	PendingDeleteDate      string              `json:"pending_delete_date,omitempty"`
+	MatchedObject          string              `json:"matched_object,omitempty"`
+	RegistrarWhoisServer   string              `json:"registrar_whois_server,omitempty"`
+	DNSSEC                 string              `json:"dnssec,omitempty"`
+	NameServers            []string            `json:"name_servers,omitempty"`
+	RegistrarIANAID        string              `json:"registrar_iana_id,omitempty"`
+	RegistrantOrganization string              `json:"registrant_organization,omitempty"`
+	RegistrantCountry      string              `json:"registrant_country,omitempty"`
+	AdminOrganization      string              `json:"admin_organization,omitempty"`
+	AdminCountry           string              `json:"admin_country,omitempty"`
+	TechOrganization       string              `json:"tech_organization,omitempty"`
+	TechCountry            string              `json:"tech_country,omitempty"`
+	BillingOrganization    string              `json:"billing_organization,omitempty"`
+	AbuseEmail             string              `json:"abuse_email,omitempty"`
+	AbusePhone             string              `json:"abuse_phone,omitempty"`
+	Extra                  map[string][]string `json:"extra,omitempty"`
+	Related                []*WhoisResponse    `json:"related,omitempty"`
+	RawText                string              `json:"raw_text,omitempty"`
+	StatusDescriptions     []string            `json:"status_descriptions,omitempty"`
+	FieldSources           map[string]string   `json:"field_sources,omitempty"`
+	Discrepancies          []FieldChange       `json:"discrepancies,omitempty"`
+	DNS                    *DNSRecords         `json:"dns,omitempty"`
+	Certificate            *CertificateInfo    `json:"certificate,omitempty"`
+	Warnings               []string            `json:"warnings,omitempty"`
+	Source                 string              `json:"source,omitempty"`
+	CreationTime           time.Time           `json:"-"`
+	ExpirationTime         time.Time           `json:"-"`
+	UpdatedTime            time.Time           `json:"-"`
+	PendingDeleteTime      time.Time           `json:"-"`
+}
+
+var eppStatusDescriptions = map[string]string{
+	"ok":                       "No pending operations or restrictions",
+	"active":                   "No pending operations or restrictions",
+	"inactive":                 "Not activated in DNS (no nameservers delegated)",
+	"addperiod":                "Within the grace period after initial registration",
+	"autorenewperiod":          "Within the grace period after automatic renewal",
+	"renewperiod":              "Within the grace period after explicit renewal",
+	"transferperiod":           "Within the grace period after a transfer",
+	"redemptionperiod":         "Deleted; can still be restored by the registrar",
+	"pendingcreate":            "Registration request is being processed",
+	"pendingdelete":            "Scheduled for deletion",
+	"pendingrenew":             "Renewal request is being processed",
+	"pendingrestore":           "Restore request is being processed",
+	"pendingtransfer":          "Transfer request is being processed",
+	"pendingupdate":            "Update request is being processed",
+	"clientdeleteprohibited":   "Deletion locked by registrar",
+	"clienthold":               "Removed from DNS by registrar",
+	"clientrenewprohibited":    "Renewal locked by registrar",
+	"clienttransferprohibited": "Transfer locked by registrar",
+	"clientupdateprohibited":   "Updates locked by registrar",
+	"serverdeleteprohibited":   "Deletion locked by registry",
+	"serverhold":               "Removed from DNS by registry",
+	"serverrenewprohibited":    "Renewal locked by registry",
+	"servertransferprohibited": "Transfer locked by registry",
+	"serverupdateprohibited":   "Updates locked by registry",
+}
+
+func (wir *WhoisResponse) AnnotateStatuses() {
+	wir.StatusDescriptions = make([]string, len(wir.Statuses))
+	for i, st := range wir.Statuses {
+		wir.StatusDescriptions[i] = eppStatusDescriptions[strings.ToLower(st)]
+	}
+}
+
+func WriteIndentedJSON(w io.Writer, v interface{}) (err error) {
+	vj, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	// json.Marshal already coerces invalid UTF-8 in values to U+FFFD; should
+	// indentation still fail, fall back to the compact encoding.
+	var out bytes.Buffer
+	if json.Indent(&out, vj, "", "    ") != nil {
+		out.Reset()
+		out.Write(vj)
+	}
+	_, err = out.WriteTo(w)
+	return
+}
+
+var protectiveLocks = []EPPStatus{
+	StatusClientDeleteProhibited,
+	StatusClientTransferProhibited,
+	StatusClientUpdateProhibited,
+	StatusServerTransferProhibited,
+}
+
+// SecurityPosture reports which of the protective locks the domain has, by
+// their EPP codes however the registry spells them, and whether it is
+// signed with DNSSEC.
+func (wir *WhoisResponse) SecurityPosture() map[string]bool {
+	p := make(map[string]bool, len(protectiveLocks)+1)
+	for _, l := range protectiveLocks {
+		p[string(l)] = wir.HasStatus(l)
+	}
+	d := strings.ToLower(wir.DNSSEC)
+	p["dnssec"] = strings.HasPrefix(d, "signed") || d == "yes"
+	return p
+}
+
+func (wir *WhoisResponse) WriteAsJSON(w io.Writer) error {
+	return WriteIndentedJSON(w, wir)
+}
+
+func jsonFieldName(f reflect.StructField) string {
+	if n := strings.Split(f.Tag.Get("json"), ",")[0]; n != "-" && f.IsExported() {
+		return n
+	}
+	return ""
+}
+
+func JSONFieldNames() map[string]bool {
+	names := map[string]bool{}
+	t := reflect.TypeOf(WhoisResponse{})
+	for i := 0; i < t.NumField(); i++ {
+		if n := jsonFieldName(t.Field(i)); len(n) != 0 {
+			names[n] = true
+		}
+	}
+	return names
+}
+
+// CheckFieldMap validates an old=new JSON key mapping: every old key must
+// be a WhoisResponse field, and no new key may repeat another or name a
+// field that keeps its own name.
+func CheckFieldMap(fieldMap map[string]string) error {
+	known := JSONFieldNames()
+	targets := map[string]string{}
+	for old, nk := range fieldMap {
+		if !known[old] {
+			return fmt.Errorf("unknown field %q", old)
+		}
+		if _, renamed := fieldMap[nk]; known[nk] && !renamed {
+			return fmt.Errorf("%s=%s clashes with the existing field %q", old, nk, nk)
+		}
+		if prev, ok := targets[nk]; ok {
+			return fmt.Errorf("%s and %s are both renamed to %q", prev, old, nk)
+		}
+		targets[nk] = old
+	}
+	return nil
+}
+
+func (wir *WhoisResponse) RenameFields(fieldMap map[string]string) (map[string]json.RawMessage, error) {
+	if err := CheckFieldMap(fieldMap); err != nil {
+		return nil, fmt.Errorf("RenameFields: %s", err)
+	}
+	return wir.jsonFields(nil, fieldMap)
+}
+
+// SelectFields returns only the named JSON fields of wir, renamed by
+// fieldMap, which may be nil.
+func (wir *WhoisResponse) SelectFields(fields []string, fieldMap map[string]string) (map[string]json.RawMessage, error) {
+	if err := CheckFieldMap(fieldMap); err != nil {
+		return nil, fmt.Errorf("SelectFields: %s", err)
+	}
+	known := JSONFieldNames()
+	for _, f := range fields {
+		if !known[f] {
+			return nil, fmt.Errorf("SelectFields: unknown field %q", f)
+		}
+	}
+	return wir.jsonFields(fields, fieldMap)
+}
+
+func (wir *WhoisResponse) jsonFields(keep []string, fieldMap map[string]string) (map[string]json.RawMessage, error) {
+	wirj, err := json.Marshal(wir)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(wirj, &fields); err != nil {
+		return nil, err
+	}
+	if keep != nil {
+		kept := make(map[string]json.RawMessage, len(keep))
+		for _, k := range keep {
+			if v, ok := fields[k]; ok {
+				kept[k] = v
+			}
+		}
+		fields = kept
+	}
+	renamed := make(map[string]json.RawMessage, len(fields))
+	for k, v := range fields {
+		if nk, ok := fieldMap[k]; ok {
+			k = nk
+		}
+		renamed[k] = v
+	}
+	return renamed, nil
+}
+
+func (wir *WhoisResponse) WriteAsJSONWithFieldMap(w io.Writer, fieldMap map[string]string) error {
+	renamed, err := wir.RenameFields(fieldMap)
+	if err != nil {
+		return err
+	}
+	return WriteIndentedJSON(w, renamed)
+}
+
+func (wir *WhoisResponse) WriteSecurityPostureAsJSON(w io.Writer) error {
+	return WriteIndentedJSON(w, wir.SecurityPosture())
+}
+
+func (wir *WhoisResponse) fillMissing(from *WhoisResponse) {
+	dv, sv := reflect.ValueOf(wir).Elem(), reflect.ValueOf(from).Elem()
+	filled := map[int]bool{}
+	for i := 0; i < dv.NumField(); i++ {
+		if f := dv.Field(i); f.CanSet() && f.IsZero() && !sv.Field(i).IsZero() && dv.Type().Field(i).Name != "FieldSources" {
+			f.Set(sv.Field(i))
+			filled[i] = true
+		}
+	}
+	wir.tagFields(sourceReferral, func(i int) bool { return filled[i] })
+	raw := make([]byte, 0, len(wir.rawText)+len(lf)+len(from.rawText))
+	raw = append(append(append(raw, wir.rawText...), lf...), from.rawText...)
+	wir.rawText = raw
+}
+
+func (wir *WhoisResponse) Raw() []byte {
+	return wir.rawText
+}
+
+func (wir *WhoisResponse) WriteAsRawText(w io.Writer) (err error) {
+	_, err = w.Write(wir.rawText)
+	return
+}
+
+func (wir *WhoisResponse) WriteAsExpirationDate(w io.Writer) (err error) {
+	_, err = fmt.Fprintln(w, wir.ExpirationDate)
+	return
+}
+
+func (wir *WhoisResponse) WriteWithTemplate(w io.Writer, t *template.Template) error {
+	return t.Execute(w, wir)
+}
@@ -0,0 +1,114 @@
+package qwis
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestAnnotateStatuses(t *testing.T) {
+	wir := &WhoisResponse{Statuses: []string{"clientTransferProhibited", "serverHold", "madeUpStatus"}}
+	wir.AnnotateStatuses()
+	want := []string{"Transfer locked by registrar", "Removed from DNS by registry", ""}
+	if !reflect.DeepEqual(wir.StatusDescriptions, want) {
+		t.Errorf("StatusDescriptions = %q, want %q", wir.StatusDescriptions, want)
+	}
+}
+
+func TestWriteAsJSONInvalidUTF8(t *testing.T) {
+	wir := &WhoisResponse{DomainName: "example.com", Registrar: "M\xfcller GmbH"}
+	var out bytes.Buffer
+	if err := wir.WriteAsJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !utf8.Valid(out.Bytes()) || !json.Valid(out.Bytes()) {
+		t.Fatalf("invalid output: %q", out.Bytes())
+	}
+	if !strings.Contains(out.String(), "\"registrar\": \"M\ufffdller GmbH\"") {
+		t.Errorf("invalid byte not replaced with U+FFFD:\n%s", out.String())
+	}
+}
+
+func TestCheckFieldMap(t *testing.T) {
+	for _, tc := range []struct {
+		fieldMap map[string]string
+		ok       bool
+	}{
+		{map[string]string{"domain_name": "domain"}, true},
+		{map[string]string{"registrar": "domain_name", "domain_name": "registrar"}, true},
+		{map[string]string{"registrar": "domain_name"}, false},
+		{map[string]string{"registrar": "name", "domain_name": "name"}, false},
+		{map[string]string{"no_such_field": "x"}, false},
+	} {
+		if err := CheckFieldMap(tc.fieldMap); (err == nil) != tc.ok {
+			t.Errorf("CheckFieldMap(%v) = %v", tc.fieldMap, err)
+		}
+	}
+}
+
+func TestRenameFields(t *testing.T) {
+	wir := &WhoisResponse{DomainName: "example.com", Registrar: "Example Registrar"}
+	renamed, err := wir.RenameFields(map[string]string{"domain_name": "registrar", "registrar": "domain_name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(renamed["registrar"]) != `"example.com"` || string(renamed["domain_name"]) != `"Example Registrar"` {
+		t.Errorf("renamed = %s", renamed)
+	}
+	if renamed, _ = wir.RenameFields(map[string]string{"domain_name": "domain"}); renamed["domain_name"] != nil || renamed["domain"] == nil {
+		t.Errorf("renamed = %s", renamed)
+	}
+}
+
+func TestSecurityPosture(t *testing.T) {
+	for _, tc := range []struct {
+		statuses []string
+		dnssec   string
+		want     map[string]bool
+	}{
+		{
+			[]string{"clientDeleteProhibited", "ClientTransferProhibited", "serverHold"}, "signedDelegation",
+			map[string]bool{"clientDeleteProhibited": true, "clientTransferProhibited": true,
+				"clientUpdateProhibited": false, "serverTransferProhibited": false, "dnssec": true},
+		},
+		{
+			[]string{"client delete prohibited", "SERVER_TRANSFER_PROHIBITED", "clientUpdateProhibited https://icann.org/epp#clientUpdateProhibited"}, "yes",
+			map[string]bool{"clientDeleteProhibited": true, "clientTransferProhibited": false,
+				"clientUpdateProhibited": true, "serverTransferProhibited": true, "dnssec": true},
+		},
+		{
+			nil, "unsigned",
+			map[string]bool{"clientDeleteProhibited": false, "clientTransferProhibited": false,
+				"clientUpdateProhibited": false, "serverTransferProhibited": false, "dnssec": false},
+		},
+	} {
+		answer := "Domain Name: example.com\nDNSSEC: " + tc.dnssec + "\n"
+		for _, st := range tc.statuses {
+			answer += "Domain Status: " + st + "\n"
+		}
+		wir, err := ParseResponse([]byte(answer))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := wir.SecurityPosture(); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("SecurityPosture(%q, %q) = %v, want %v", tc.statuses, tc.dnssec, got, tc.want)
+		}
+	}
+}
+
+func TestSelectFields(t *testing.T) {
+	wir := &WhoisResponse{DomainName: "example.com", Registrar: "Example Registrar", ExpirationDate: "2026-08-13T04:00:00Z"}
+	fields, err := wir.SelectFields([]string{"domain_name", "expiration_date", "updated_date"}, map[string]string{"domain_name": "domain"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 3 || string(fields["domain"]) != `"example.com"` || fields["registrar"] != nil {
+		t.Errorf("fields = %s", fields)
+	}
+	if _, err = wir.SelectFields([]string{"expiry"}, nil); err == nil {
+		t.Error("unknown field accepted")
+	}
+}
@@ -0,0 +1,94 @@
+package qwis
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+type RetryPolicy struct {
+	// Attempts is the total number of tries per server; values below 1
+	// mean a single try.
+	Attempts int
+	// Backoff is the delay before the first retry; it doubles on every
+	// following one up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Jitter randomizes each delay by up to this fraction of it.
+	Jitter float64
+}
+
+var DefaultRetryPolicy = RetryPolicy{Attempts: 1, Backoff: time.Second, MaxBackoff: 30 * time.Second, Jitter: 0.2}
+
+var Retry = DefaultRetryPolicy
+
+func (p RetryPolicy) delay(retry int) time.Duration {
+	d := p.Backoff << retry
+	if p.MaxBackoff > 0 && (d > p.MaxBackoff || d <= 0) {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d))
+	}
+	return d
+}
+
+func retryable(err error) bool {
+	return !errors.Is(err, ErrNoSuchDomain) && !errors.Is(err, ErrUnsupportedTLD) && !errors.Is(err, ErrParse) &&
+		!errors.Is(err, ErrResponseTooLarge)
+}
+
+func withRetry[T any](ctx context.Context, f func() (T, error)) (T, error) {
+	var (
+		res  T
+		zero T
+		err  error
+	)
+	p := retryPolicy(ctx)
+	for i := 0; ; i++ {
+		if res, err = f(); err == nil || i+1 >= p.Attempts || ctx.Err() != nil || !retryable(err) {
+			return res, err
+		}
+		d := p.delay(i)
+		logEvent(ctx, slog.LevelInfo, "retry", "attempt", i+2, "delay", d, "err", err)
+		t := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return zero, err
+		case <-t.C:
+		}
+	}
+}
+
+func failoverServers(suffix, primary string) []string {
+	servers := []string{primary}
+	// Suffixes below a TLD fail over to the embedded server of the nearest
+	// one that has it; whois-servers.net only has aliases for TLDs.
+	for strings.Contains(suffix, ".") {
+		if _, ok := fallbackWhoisServers[suffix]; ok {
+			break
+		}
+		suffix = suffix[strings.IndexByte(suffix, '.')+1:]
+	}
+	candidates := []string{fallbackWhoisServers[suffix]}
+	if !strings.Contains(suffix, ".") {
+		candidates = append(candidates, suffix+".whois-servers.net")
+	}
+	for _, s := range candidates {
+		if len(s) == 0 {
+			continue
+		}
+		dup := false
+		for _, seen := range servers {
+			dup = dup || strings.EqualFold(serverHost(referralAddress(seen)), serverHost(referralAddress(s)))
+		}
+		if !dup {
+			servers = append(servers, s)
+		}
+	}
+	return servers
+}
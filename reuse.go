@@ -0,0 +1,150 @@
+package qwis
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// ReuseConnections keeps the connection to a server open after an answer
+// ending in an RWHOIS-style "%ok" or "%error" line and sends the following
+// queries for that server over it. Servers that close the connection after
+// answering are queried one connection per query as usual.
+var ReuseConnections = false
+
+var idleConns struct {
+	sync.Mutex
+	m map[string][]*reusableConn
+}
+
+type reusableConn struct {
+	net.Conn
+	r       *bufio.Reader
+	address string
+}
+
+func takeIdleConn(address string) *reusableConn {
+	idleConns.Lock()
+	defer idleConns.Unlock()
+	cs := idleConns.m[address]
+	if len(cs) == 0 {
+		return nil
+	}
+	rc := cs[len(cs)-1]
+	idleConns.m[address] = cs[:len(cs)-1]
+	return rc
+}
+
+func putIdleConn(address string, rc *reusableConn) {
+	idleConns.Lock()
+	defer idleConns.Unlock()
+	if idleConns.m == nil {
+		idleConns.m = map[string][]*reusableConn{}
+	}
+	idleConns.m[address] = append(idleConns.m[address], rc)
+}
+
+// CloseIdleConnections closes the connections kept open by ReuseConnections.
+func CloseIdleConnections() {
+	idleConns.Lock()
+	defer idleConns.Unlock()
+	for _, cs := range idleConns.m {
+		for _, rc := range cs {
+			rc.Close()
+		}
+	}
+	idleConns.m = nil
+}
+
+func isAnswerEnd(l []byte) bool {
+	l = bytes.ToLower(bytes.TrimSpace(l))
+	return bytes.HasPrefix(l, []byte("%ok")) || bytes.HasPrefix(l, []byte("%error"))
+}
+
+// exchange sends query over rc and reads the answer. open reports whether
+// the server kept the connection open for another query.
+func (rc *reusableConn) exchange(ctx context.Context, query []byte) (res []byte, open bool, err error) {
+	dl, _ := ctx.Deadline()
+	rc.SetDeadline(dl)
+	stop := context.AfterFunc(ctx, func() { rc.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+	if _, err = rc.Write(query); err != nil {
+		return nil, false, err
+	}
+	// Lines are read a buffer at a time so that one without an end can't
+	// grow past MaxResponseSize; line is where the current one starts.
+	line, timeout, limit := 0, readTimeout(ctx), maxResponseSize(ctx)
+	for {
+		if timeout > 0 {
+			rdl := time.Now().Add(timeout)
+			if !dl.IsZero() && dl.Before(rdl) {
+				rdl = dl
+			}
+			rc.SetReadDeadline(rdl)
+		}
+		l, err := rc.r.ReadSlice('\n')
+		res = append(res, l...)
+		switch {
+		case limit > 0 && int64(len(res)) > limit:
+			return nil, false, errTooLarge(rc.address, limit)
+		case err == bufio.ErrBufferFull:
+			continue
+		case err == io.EOF && len(res) != 0:
+			return res, false, nil
+		case err != nil:
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			return nil, false, err
+		case isAnswerEnd(res[line:]):
+			rc.SetDeadline(time.Time{})
+			return res, true, nil
+		}
+		line = len(res)
+	}
+}
+
+func queryReusingConn(ctx context.Context, address string, query []byte) ([]byte, error) {
+	re := func(e error) error {
+		return fmt.Errorf("Whois: %w", e)
+	}
+	if err := waitRateLimit(ctx, address); err != nil {
+		return nil, re(err)
+	}
+	// A kept connection may have been dropped by the server in the
+	// meantime; fall back to a fresh one.
+	if rc := takeIdleConn(address); rc != nil {
+		if res, open, err := rc.exchange(ctx, query); err == nil {
+			if open {
+				putIdleConn(address, rc)
+			} else {
+				rc.Close()
+			}
+			return res, nil
+		}
+		rc.Close()
+		if ctx.Err() != nil {
+			return nil, re(ctx.Err())
+		}
+	}
+	conn, err := dialServer(ctx, address)
+	if err != nil {
+		return nil, re(err)
+	}
+	rc := &reusableConn{conn, bufio.NewReader(conn), address}
+	res, open, err := rc.exchange(ctx, query)
+	if err != nil || !open {
+		rc.Close()
+	} else {
+		putIdleConn(address, rc)
+	}
+	if err != nil {
+		return nil, re(err)
+	}
+	return res, nil
+}
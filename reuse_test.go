@@ -0,0 +1,61 @@
+package qwis
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// rwhoisDial answers every query on a connection with a record terminated by
+// "%ok", until the connection has served limit queries.
+func rwhoisDial(dials *int32, limit int) DialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		atomic.AddInt32(dials, 1)
+		c, s := net.Pipe()
+		go func() {
+			defer s.Close()
+			r := bufio.NewReader(s)
+			for i := 0; i < limit; i++ {
+				q, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				fmt.Fprintf(s, "domain: %s\r\n%%ok\r\n", strings.TrimSpace(q))
+			}
+		}()
+		return c, nil
+	}
+}
+
+func TestReuseConnections(t *testing.T) {
+	var dials int32
+	useDial(t, rwhoisDial(&dials, 3))
+	Server, ReuseConnections = "rwhois.test:4321", true
+	t.Cleanup(func() {
+		ReuseConnections = false
+		CloseIdleConnections()
+	})
+	for _, dn := range []string{"a.test", "b.test", "c.test"} {
+		res, err := WhoisRawContext(context.Background(), dn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "domain: " + dn + "\r\n%ok\r\n"; string(res) != want {
+			t.Errorf("response = %q, want %q", res, want)
+		}
+	}
+	if dials != 1 {
+		t.Errorf("dialed %d times, want 1", dials)
+	}
+	// The server hung up after three queries; the next one redials.
+	if _, err := WhoisRawContext(context.Background(), "d.test"); err != nil {
+		t.Fatal(err)
+	}
+	if dials != 2 {
+		t.Errorf("dialed %d times, want 2", dials)
+	}
+}
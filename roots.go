@@ -0,0 +1,56 @@
+package qwis
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// RootCAs verifies the servers RDAP is fetched from over https and the
+// whois servers reached over TLS; nil means the system roots.
+var RootCAs *x509.CertPool
+
+// WhoisTLS has whois queries sent over TLS, to port 853 in place of 43.
+var WhoisTLS = false
+
+// InsecureSkipVerify accepts any certificate from whois servers reached
+// over TLS; SetInsecureSkipVerify sets it for RDAP as well.
+var InsecureSkipVerify = false
+
+// SetInsecureSkipVerify sets InsecureSkipVerify and has RDAPClient accept
+// any certificate too when skip is true.
+func SetInsecureSkipVerify(skip bool) {
+	InsecureSkipVerify = skip
+	setRDAPTransport(func(t *http.Transport) {
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{}
+		}
+		t.TLSClientConfig.InsecureSkipVerify = skip
+	})
+}
+
+// LoadRootCAs adds the PEM certificates in path to the system roots and
+// has RDAPClient verify servers against them.
+func LoadRootCAs(path string) error {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("LoadRootCAs: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("LoadRootCAs: no certificates in %s", path)
+	}
+	RootCAs = pool
+	setRDAPTransport(func(t *http.Transport) {
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{}
+		}
+		t.TLSClientConfig.RootCAs = pool
+	})
+	return nil
+}
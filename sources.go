@@ -0,0 +1,58 @@
+package qwis
+
+import "reflect"
+
+// RecordFieldSources makes parsing fill WhoisResponse.FieldSources with how
+// each populated field was obtained.
+var RecordFieldSources = false
+
+const (
+	sourceExactKey     = "exact_key"
+	sourceSubstringKey = "substring_key"
+	sourceTLDParser    = "tld_parser"
+	sourceQuery        = "query"
+	sourceReferral     = "referral"
+	sourceRDAP         = "rdap"
+)
+
+var untaggedFields = map[string]bool{
+	"ascii_domain_name":   true,
+	"unicode_domain_name": true,
+	"raw_text":            true,
+	"status_descriptions": true,
+	"field_sources":       true,
+	"extra":               true,
+	"related":             true,
+	"source":              true,
+}
+
+func (wir *WhoisResponse) setSource(field, src string) {
+	if !RecordFieldSources {
+		return
+	}
+	if wir.FieldSources == nil {
+		wir.FieldSources = map[string]string{}
+	}
+	wir.FieldSources[field] = src
+}
+
+// tagFields records src for the populated fields for which keep returns
+// true.
+func (wir *WhoisResponse) tagFields(src string, keep func(i int) bool) {
+	v := reflect.ValueOf(wir).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name := jsonFieldName(v.Type().Field(i))
+		if len(name) != 0 && !untaggedFields[name] && !v.Field(i).IsZero() && keep(i) {
+			wir.setSource(name, src)
+		}
+	}
+}
+
+// InferDomainName sets DomainName to the queried name when the response
+// doesn't carry one.
+func (wir *WhoisResponse) InferDomainName(domainName string) {
+	if len(wir.DomainName) == 0 {
+		wir.DomainName = domainName
+		wir.setSource("domain_name", sourceQuery)
+	}
+}
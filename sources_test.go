@@ -0,0 +1,61 @@
+package qwis
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func recordFieldSources(t *testing.T) {
+	RecordFieldSources = true
+	t.Cleanup(func() { RecordFieldSources = false })
+}
+
+func TestFieldSources(t *testing.T) {
+	recordFieldSources(t)
+	wir, err := ParseResponse([]byte("Registrar WHOIS Server: whois.registrar.test\nCreation Date: 1997-09-15T04:00:00Z\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	referred, err := ParseResponse([]byte("Registrar: Registrar Inc.\nRegistrar WHOIS Server: whois.other.test\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wir.fillMissing(referred)
+	wir.InferDomainName("example.com")
+	for field, want := range map[string]string{
+		"registrar_whois_server": sourceExactKey,
+		"creation_date":          sourceSubstringKey,
+		"registrar":              sourceReferral,
+		"domain_name":            sourceQuery,
+	} {
+		if got := wir.FieldSources[field]; got != want {
+			t.Errorf("FieldSources[%s] = %q, want %q", field, got, want)
+		}
+	}
+}
+
+func TestRDAPFieldSources(t *testing.T) {
+	recordFieldSources(t)
+	wir, err := ParseRDAPResponse([]byte(`{"ldhName":"example.com","status":["active"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wir.FieldSources["domain_name"] != sourceRDAP || wir.FieldSources["statuses"] != sourceRDAP {
+		t.Errorf("FieldSources = %v", wir.FieldSources)
+	}
+}
+
+func TestFieldSourcesOffByDefault(t *testing.T) {
+	wir, err := ParseResponse([]byte(verisignResponse))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err = wir.WriteAsJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	if wir.FieldSources != nil || strings.Contains(out.String(), "field_sources") {
+		t.Errorf("field sources recorded unasked:\n%s", out.String())
+	}
+}
@@ -0,0 +1,117 @@
+package qwis
+
+import "strings"
+
+// EPPStatus is a domain status code of RFC 5731 and RFC 3915, as used by
+// registries in their whois and RDAP answers.
+type EPPStatus string
+
+const (
+	StatusOK                       EPPStatus = "ok"
+	StatusInactive                 EPPStatus = "inactive"
+	StatusAddPeriod                EPPStatus = "addPeriod"
+	StatusAutoRenewPeriod          EPPStatus = "autoRenewPeriod"
+	StatusRenewPeriod              EPPStatus = "renewPeriod"
+	StatusTransferPeriod           EPPStatus = "transferPeriod"
+	StatusRedemptionPeriod         EPPStatus = "redemptionPeriod"
+	StatusPendingCreate            EPPStatus = "pendingCreate"
+	StatusPendingDelete            EPPStatus = "pendingDelete"
+	StatusPendingRenew             EPPStatus = "pendingRenew"
+	StatusPendingRestore           EPPStatus = "pendingRestore"
+	StatusPendingTransfer          EPPStatus = "pendingTransfer"
+	StatusPendingUpdate            EPPStatus = "pendingUpdate"
+	StatusClientDeleteProhibited   EPPStatus = "clientDeleteProhibited"
+	StatusClientHold               EPPStatus = "clientHold"
+	StatusClientRenewProhibited    EPPStatus = "clientRenewProhibited"
+	StatusClientTransferProhibited EPPStatus = "clientTransferProhibited"
+	StatusClientUpdateProhibited   EPPStatus = "clientUpdateProhibited"
+	StatusServerDeleteProhibited   EPPStatus = "serverDeleteProhibited"
+	StatusServerHold               EPPStatus = "serverHold"
+	StatusServerRenewProhibited    EPPStatus = "serverRenewProhibited"
+	StatusServerTransferProhibited EPPStatus = "serverTransferProhibited"
+	StatusServerUpdateProhibited   EPPStatus = "serverUpdateProhibited"
+)
+
+var eppStatuses = map[string]EPPStatus{}
+
+func init() {
+	for _, s := range []EPPStatus{
+		StatusOK, StatusInactive, StatusAddPeriod, StatusAutoRenewPeriod, StatusRenewPeriod,
+		StatusTransferPeriod, StatusRedemptionPeriod, StatusPendingCreate, StatusPendingDelete,
+		StatusPendingRenew, StatusPendingRestore, StatusPendingTransfer, StatusPendingUpdate,
+		StatusClientDeleteProhibited, StatusClientHold, StatusClientRenewProhibited,
+		StatusClientTransferProhibited, StatusClientUpdateProhibited, StatusServerDeleteProhibited,
+		StatusServerHold, StatusServerRenewProhibited, StatusServerTransferProhibited,
+		StatusServerUpdateProhibited,
+	} {
+		eppStatuses[strings.ToLower(string(s))] = s
+	}
+	// RDAP (RFC 8056) calls ok "active".
+	eppStatuses["active"] = StatusOK
+}
+
+// ParseEPPStatus returns the EPP status code s spells, whatever its case
+// and however its words are separated, as in "client transfer prohibited"
+// or "CLIENT_TRANSFER_PROHIBITED".
+func ParseEPPStatus(s string) (EPPStatus, bool) {
+	k := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '_' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(s)))
+	st, ok := eppStatuses[k]
+	return st, ok
+}
+
+// Status is one status of a domain: its EPP code, empty for statuses of
+// registries that use codes of their own, the text it was given as, and
+// the URL explaining it that some registries add.
+type Status struct {
+	Code EPPStatus `json:"code,omitempty"`
+	Text string    `json:"text"`
+	URL  string    `json:"url,omitempty"`
+}
+
+// splitStatusURL splits a status line such as "clientHold
+// https://icann.org/epp#clientHold" into its text and URL.
+func splitStatusURL(s string) (string, string) {
+	i := strings.Index(s, "http")
+	if i < 0 {
+		return s, ""
+	}
+	url := strings.Trim(strings.Fields(s[i:])[0], "()")
+	return strings.TrimRight(s[:i], " (\t"), url
+}
+
+// fillStatusCodes gives every status without a Status one and looks up
+// the EPP codes.
+func (wir *WhoisResponse) fillStatusCodes() {
+	for _, st := range wir.Statuses[min(len(wir.StatusCodes), len(wir.Statuses)):] {
+		wir.StatusCodes = append(wir.StatusCodes, Status{Text: st})
+	}
+	for i := range wir.StatusCodes {
+		wir.StatusCodes[i].Code, _ = ParseEPPStatus(wir.StatusCodes[i].Text)
+	}
+}
+
+func (wir *WhoisResponse) HasStatus(code EPPStatus) bool {
+	for _, st := range wir.StatusCodes {
+		if st.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// IsLocked reports whether the registrar or the registry prohibits moving
+// the domain to another registrar, the usual meaning of a registrar lock.
+func (wir *WhoisResponse) IsLocked() bool {
+	return wir.HasStatus(StatusClientTransferProhibited) || wir.HasStatus(StatusServerTransferProhibited)
+}
+
+// IsPendingDelete reports whether the domain is to be deleted, either past
+// its redemption period or still in it.
+func (wir *WhoisResponse) IsPendingDelete() bool {
+	return wir.HasStatus(StatusPendingDelete) || wir.HasStatus(StatusRedemptionPeriod)
+}
@@ -0,0 +1,66 @@
+package qwis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEPPStatus(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want EPPStatus
+		ok   bool
+	}{
+		{"clientTransferProhibited", StatusClientTransferProhibited, true},
+		{"client transfer prohibited", StatusClientTransferProhibited, true},
+		{"SERVER_HOLD", StatusServerHold, true},
+		{"Active", StatusOK, true},
+		{"ok", StatusOK, true},
+		{"connect", "", false},
+	} {
+		if got, ok := ParseEPPStatus(tc.in); got != tc.want || ok != tc.ok {
+			t.Errorf("ParseEPPStatus(%q) = %q, %v, want %q, %v", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestStatusCodes(t *testing.T) {
+	wir, err := ParseResponse([]byte(verisignResponse))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Status{
+		{StatusClientDeleteProhibited, "clientDeleteProhibited", "https://icann.org/epp#clientDeleteProhibited"},
+		{StatusClientTransferProhibited, "clientTransferProhibited", "https://icann.org/epp#clientTransferProhibited"},
+		{StatusServerTransferProhibited, "serverTransferProhibited", "https://icann.org/epp#serverTransferProhibited"},
+	}
+	if !reflect.DeepEqual(wir.StatusCodes, want) {
+		t.Errorf("StatusCodes = %+v, want %+v", wir.StatusCodes, want)
+	}
+	if !wir.IsLocked() || wir.IsPendingDelete() {
+		t.Errorf("IsLocked() = %v, IsPendingDelete() = %v", wir.IsLocked(), wir.IsPendingDelete())
+	}
+
+	wir, err = ParseResponse([]byte("Domain Name: example.org\nDomain Status: redemptionPeriod (https://icann.org/epp#redemptionPeriod)\nDomain Status: Registrar-Locked\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []Status{
+		{StatusRedemptionPeriod, "redemptionPeriod", "https://icann.org/epp#redemptionPeriod"},
+		{"", "Registrar-Locked", ""},
+	}
+	if !reflect.DeepEqual(wir.StatusCodes, want) {
+		t.Errorf("StatusCodes = %+v, want %+v", wir.StatusCodes, want)
+	}
+	if wir.IsLocked() || !wir.IsPendingDelete() {
+		t.Errorf("IsLocked() = %v, IsPendingDelete() = %v", wir.IsLocked(), wir.IsPendingDelete())
+	}
+
+	wir, err = ParseRDAPResponse([]byte(`{"ldhName":"example.dev","status":["client transfer prohibited","pending delete"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wir.IsLocked() || !wir.IsPendingDelete() || wir.StatusCodes[1].Text != "pendingDelete" {
+		t.Errorf("RDAP StatusCodes = %+v", wir.StatusCodes)
+	}
+}
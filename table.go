@@ -0,0 +1,54 @@
+package qwis
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// DefaultTableFields are the columns of tabular output unless others are
+// chosen.
+var DefaultTableFields = []string{
+	"domain_name",
+	"registrar",
+	"creation_date",
+	"expiration_date",
+	"updated_date",
+	"statuses",
+	"name_servers",
+}
+
+// FieldStrings returns the named JSON fields of wir as text for a table
+// cell, joining list values with sep.
+func (wir *WhoisResponse) FieldStrings(fields []string, sep string) ([]string, error) {
+	v := reflect.ValueOf(wir).Elem()
+	index := map[string]int{}
+	for i := 0; i < v.NumField(); i++ {
+		if n := jsonFieldName(v.Type().Field(i)); len(n) != 0 {
+			index[n] = i
+		}
+	}
+	values := make([]string, len(fields))
+	for i, f := range fields {
+		fi, ok := index[f]
+		if !ok {
+			return nil, fmt.Errorf("FieldStrings: unknown field %q", f)
+		}
+		switch fv := v.Field(fi).Interface().(type) {
+		case string:
+			values[i] = fv
+		case []string:
+			values[i] = strings.Join(fv, sep)
+		default:
+			if !v.Field(fi).IsZero() {
+				b, err := json.Marshal(fv)
+				if err != nil {
+					return nil, fmt.Errorf("FieldStrings: %w", err)
+				}
+				values[i] = string(b)
+			}
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,25 @@
+package qwis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFieldStrings(t *testing.T) {
+	wir := &WhoisResponse{
+		DomainName:   "example.com",
+		Statuses:     []string{"clientTransferProhibited", "serverHold"},
+		FieldSources: map[string]string{"domain_name": sourceExactKey},
+	}
+	got, err := wir.FieldStrings([]string{"domain_name", "statuses", "name_servers", "field_sources"}, "|")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"example.com", "clientTransferProhibited|serverHold", "", `{"domain_name":"exact_key"}`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FieldStrings = %q, want %q", got, want)
+	}
+	if _, err = wir.FieldStrings([]string{"expiry"}, ";"); err == nil {
+		t.Error("unknown field accepted")
+	}
+}
@@ -0,0 +1,65 @@
+package qwis
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// ValidateDomainName checks domainName, in its Unicode or ASCII form, with
+// an optional trailing dot, against the syntax of host names (RFC 1123) and
+// of internationalized labels (RFC 5891). Its errors wrap
+// ErrInvalidDomainName and say what is wrong.
+func ValidateDomainName(domainName string) error {
+	a, err := ToASCII(domainName)
+	if err != nil {
+		return err
+	}
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w %q: %s", ErrInvalidDomainName, domainName, fmt.Sprintf(format, args...))
+	}
+	a = strings.TrimSuffix(a, ".")
+	switch {
+	case len(a) == 0:
+		return invalid("empty")
+	case len(a) > 253:
+		return invalid("longer than 253 characters")
+	}
+	labels := strings.Split(a, ".")
+	for _, l := range labels {
+		switch {
+		case len(l) == 0:
+			return invalid("empty label")
+		case len(l) > 63:
+			return invalid("label %q is longer than 63 characters", l)
+		case strings.HasPrefix(l, "-") || strings.HasSuffix(l, "-"):
+			return invalid("label %q starts or ends with a hyphen", l)
+		}
+		for i := 0; i < len(l); i++ {
+			if c := l[i]; !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-') {
+				return invalid("label %q has %q; only letters, digits and hyphens are allowed", l, c)
+			}
+		}
+		if len(l) >= 4 && l[2:4] == "--" {
+			if !strings.EqualFold(l[:2], "xn") {
+				return invalid("label %q has hyphens in the third and fourth places", l)
+			}
+			if _, err := idna.Lookup.ToUnicode(l); err != nil {
+				return invalid("label %q is not a valid internationalized label", l)
+			}
+		}
+	}
+	if strings.Trim(labels[len(labels)-1], "0123456789") == "" {
+		return invalid("the top-level domain is numeric")
+	}
+	return nil
+}
+
+// asciiDomainName is the ASCII form of domainName, which must be valid.
+func asciiDomainName(domainName string) (string, error) {
+	if err := ValidateDomainName(domainName); err != nil {
+		return "", err
+	}
+	return ToASCII(domainName)
+}
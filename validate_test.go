@@ -0,0 +1,52 @@
+package qwis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestValidateDomainName(t *testing.T) {
+	for _, dn := range []string{"example.com", "Example.COM.", "xn--bcher-kva.de", "bücher.de", "a-b.co.uk", "com"} {
+		if err := ValidateDomainName(dn); err != nil {
+			t.Errorf("ValidateDomainName(%q) = %v", dn, err)
+		}
+	}
+	for dn, why := range map[string]string{
+		"":                                "empty",
+		"example..com":                    "empty label",
+		strings.Repeat("a", 64) + ".com":  "longer than 63",
+		strings.Repeat("a.", 127) + "com": "longer than 253",
+		"-example.com":                    "hyphen",
+		"exa_mple.com":                    "only letters, digits and hyphens",
+		"example.com/path":                "only letters, digits and hyphens",
+		"ab--cd.com":                      "third and fourth",
+		"xn--a.com":                       "internationalized",
+		"192.0.2.1":                       "numeric",
+		"bü_cher.de":                      "disallowed rune",
+	} {
+		err := ValidateDomainName(dn)
+		if !errors.Is(err, ErrInvalidDomainName) || !strings.Contains(err.Error(), why) {
+			t.Errorf("ValidateDomainName(%q) = %v, want an error about %q", dn, err, why)
+		}
+	}
+}
+
+func TestWhoisInvalidDomainName(t *testing.T) {
+	useDial(t, func(ctx context.Context, network, address string) (net.Conn, error) {
+		t.Errorf("dialed %s", address)
+		return nil, errors.New("connection refused")
+	})
+	ctx := context.Background()
+	if _, err := WhoisContext(ctx, "exa mple.com"); !errors.Is(err, ErrInvalidDomainName) {
+		t.Errorf("WhoisContext error = %v", err)
+	}
+	if _, err := RDAPRawContext(ctx, "example..dev"); !errors.Is(err, ErrInvalidDomainName) {
+		t.Errorf("RDAPRawContext error = %v", err)
+	}
+	if _, err := WhoisRawStreamContext(ctx, "co.uk"); !errors.Is(err, ErrInvalidDomainName) {
+		t.Errorf("WhoisRawStreamContext(co.uk) error = %v", err)
+	}
+}
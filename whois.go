@@ -0,0 +1,477 @@
+// Package qwis implements a quick whois client and response parser.
+package qwis
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+var crlf = []byte("\r\n")
+
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// DefaultReadTimeout and DefaultMaxResponseSize are the initial
+// ReadTimeout and MaxResponseSize.
+const (
+	DefaultReadTimeout     = 30 * time.Second
+	DefaultMaxResponseSize = 4 << 20
+)
+
+var (
+	Dialer net.Dialer
+	Dial   DialFunc = Dialer.DialContext
+
+	// ReadTimeout bounds every read of an answer, so that a server stalling
+	// mid-response fails the query rather than holding it; zero disables it.
+	ReadTimeout = DefaultReadTimeout
+
+	// MaxResponseSize is the most bytes read of an answer, whois or RDAP;
+	// queries answered with more fail with ErrResponseTooLarge. Zero or
+	// less lifts the bound.
+	MaxResponseSize int64 = DefaultMaxResponseSize
+
+	// Network is what dialServer passes to Dial: "tcp4" or "tcp6" keep to
+	// one address family, while "tcp" has Dialer race the other family in
+	// after Dialer.FallbackDelay when the first makes no progress, as it does
+	// against servers with broken AAAA records.
+	Network = "tcp"
+
+	FollowReferrals = true
+
+	// Server, when set to host[:port], receives every query regardless of
+	// the TLD or address being looked up.
+	Server string
+)
+
+func TopLevelDomain(domainName string) string {
+	parts := strings.Split(domainName, ".")
+	return parts[len(parts)-1]
+}
+
+// EffectiveTLD returns the public suffix domainName is registered under by
+// the ICANN section of the Public Suffix List, such as co.uk for
+// www.example.co.uk, or its last label for TLDs the list does not know.
+func EffectiveTLD(domainName string) string {
+	s, icann := publicsuffix.PublicSuffix(strings.ToLower(strings.TrimSuffix(domainName, ".")))
+	// Private suffixes such as github.io are run by companies, not
+	// registries; the ICANN one they are under is wanted.
+	for !icann && strings.Contains(s, ".") {
+		s, icann = publicsuffix.PublicSuffix(s[strings.IndexByte(s, '.')+1:])
+	}
+	return s
+}
+
+// rawStream reads an answer within the ReadTimeout and MaxResponseSize of
+// the lookup it was opened for, readTimeout and maxSize.
+type rawStream struct {
+	net.Conn
+	ctx         context.Context
+	stop        func() bool
+	address     string
+	readTimeout time.Duration
+	maxSize     int64
+	read        int64
+}
+
+// errTooLarge is the error of an answer from address over limit bytes.
+func errTooLarge(address string, limit int64) error {
+	return &ServerError{address, fmt.Errorf("%w: over %d bytes", ErrResponseTooLarge, limit)}
+}
+
+func (rs *rawStream) Read(p []byte) (int, error) {
+	// Re-arming the deadline below would undo the one set on cancellation.
+	if err := rs.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if rs.readTimeout > 0 {
+		dl := time.Now().Add(rs.readTimeout)
+		if cdl, ok := rs.ctx.Deadline(); ok && cdl.Before(dl) {
+			dl = cdl
+		}
+		rs.SetReadDeadline(dl)
+	}
+	// One byte past the bound is enough to tell that the answer exceeds it.
+	if rs.maxSize > 0 {
+		if rs.read > rs.maxSize {
+			return 0, errTooLarge(rs.address, rs.maxSize)
+		}
+		if left := rs.maxSize - rs.read + 1; int64(len(p)) > left {
+			p = p[:left]
+		}
+	}
+	n, err := rs.Conn.Read(p)
+	if err != nil && rs.ctx.Err() != nil {
+		err = rs.ctx.Err()
+	}
+	if rs.read += int64(n); rs.maxSize > 0 && rs.read > rs.maxSize {
+		return n - int(rs.read-rs.maxSize), errTooLarge(rs.address, rs.maxSize)
+	}
+	return n, err
+}
+
+func (rs *rawStream) Close() error {
+	rs.stop()
+	return rs.Conn.Close()
+}
+
+// dialServer connects to address within Dialer.Timeout, which thereby also
+// bounds dial functions other than the Dialer's own, and completes the TLS
+// handshake within it when WhoisTLS is set.
+func dialServer(ctx context.Context, address string) (net.Conn, error) {
+	dctx := ctx
+	if d := dialTimeout(ctx); d > 0 {
+		var cancel context.CancelFunc
+		dctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	host, port, _ := net.SplitHostPort(address)
+	config := whoisTLSConfig(ctx, host)
+	if config != nil && port == "43" {
+		address = net.JoinHostPort(host, "853")
+	}
+	network := dialNetwork(ctx)
+	start := time.Now()
+	conn, err := dialFunc(ctx)(dctx, network, address)
+	logEvent(ctx, slog.LevelDebug, "dial", "server", address, "network", network, "elapsed", time.Since(start), "err", err)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, &ServerError{address, fmt.Errorf("%w: failed to establish TCP connection", ErrServerUnavailable)}
+	}
+	if config != nil {
+		tc := tls.Client(conn, config)
+		if err = tc.HandshakeContext(dctx); err != nil {
+			conn.Close()
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, &ServerError{address, fmt.Errorf("%w: TLS handshake failed: %v", ErrServerUnavailable, err)}
+		}
+		conn = tc
+	}
+	return conn, nil
+}
+
+func queryServer(ctx context.Context, address string, query []byte) (io.ReadCloser, error) {
+	re := func(e error) error {
+		return fmt.Errorf("Whois: %w", e)
+	}
+	if err := waitRateLimit(ctx, address); err != nil {
+		return nil, re(err)
+	}
+	conn, err := dialServer(ctx, address)
+	if err != nil {
+		return nil, re(err)
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(dl)
+	}
+	rs := &rawStream{
+		Conn:        conn,
+		ctx:         ctx,
+		stop:        context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) }),
+		address:     address,
+		readTimeout: readTimeout(ctx),
+		maxSize:     maxResponseSize(ctx),
+	}
+	n, err := conn.Write(query)
+	logEvent(ctx, slog.LevelDebug, "query sent", "server", address, "sent", n)
+	if err != nil {
+		rs.Close()
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, re(err)
+	}
+	return rs, nil
+}
+
+// responseBuffers hold answers as they are read, so that batches of
+// lookups grow a few buffers rather than one per answer.
+var responseBuffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// maxPooledBuffer is the largest buffer put back in responseBuffers; the
+// rare huge answer shouldn't stay in memory for good.
+const maxPooledBuffer = 256 << 10
+
+// readResponse reads rs to the end into a pooled buffer and returns a copy
+// of the answer sized to fit.
+func readResponse(rs io.ReadCloser) ([]byte, error) {
+	defer rs.Close()
+	b := responseBuffers.Get().(*bytes.Buffer)
+	defer func() {
+		if b.Cap() <= maxPooledBuffer {
+			b.Reset()
+			responseBuffers.Put(b)
+		}
+	}()
+	if _, err := b.ReadFrom(rs); err != nil {
+		return nil, fmt.Errorf("Whois: %w", err)
+	}
+	if b.Len() == 0 {
+		return nil, nil
+	}
+	return bytes.Clone(b.Bytes()), nil
+}
+
+func queryAndRead(ctx context.Context, address string, query []byte) ([]byte, error) {
+	return withRetry(ctx, func() ([]byte, error) {
+		return queryAndReadOnce(ctx, address, query)
+	})
+}
+
+func cacheKey(address string, query []byte) string {
+	return address + " " + string(query)
+}
+
+func queryAndReadOnce(ctx context.Context, address string, query []byte) ([]byte, error) {
+	key, cache := cacheKey(address, query), responseCache(ctx)
+	if cache != nil {
+		if res, ok := cacheGet(ctx, cache, key); ok {
+			logEvent(ctx, slog.LevelDebug, "cache hit", "server", address, "received", len(res))
+			hookCacheHit(ctx, address)
+			return res, nil
+		}
+	}
+	var (
+		res   []byte
+		rs    io.ReadCloser
+		err   error
+		start = time.Now()
+	)
+	if reuseConnections(ctx) {
+		res, err = queryReusingConn(ctx, address, query)
+	} else if rs, err = queryServer(ctx, address, query); err == nil {
+		res, err = readResponse(rs)
+	}
+	elapsed := time.Since(start)
+	hookQuery(ctx, address, elapsed, err)
+	if err != nil {
+		logEvent(ctx, slog.LevelInfo, "query failed", "server", address, "elapsed", elapsed, "err", err)
+		return nil, err
+	}
+	logEvent(ctx, slog.LevelInfo, "query", "server", address, "sent", len(query), "received", len(res), "elapsed", elapsed)
+	if isRateLimited(res) {
+		return nil, fmt.Errorf("Whois: %w", &ServerError{address, ErrRateLimited})
+	}
+	if cache != nil && len(res) != 0 {
+		cacheSet(ctx, cache, key, res)
+	}
+	return res, nil
+}
+
+func domainQuery(ctx context.Context, domainName string) (string, []byte, error) {
+	domainName, err := asciiDomainName(domainName)
+	if err != nil {
+		return "", nil, fmt.Errorf("Whois: %w", err)
+	}
+	if etld := EffectiveTLD(domainName); strings.Contains(etld, ".") && strings.EqualFold(strings.TrimSuffix(domainName, "."), etld) {
+		return "", nil, fmt.Errorf("Whois: %w %q: a public suffix, not a registrable domain", ErrInvalidDomainName, domainName)
+	}
+	server := fixedServer(ctx)
+	if len(server) == 0 {
+		if server, err = WhoisServer(ctx, EffectiveTLD(domainName)); err != nil {
+			return "", nil, fmt.Errorf("Whois: %w", err)
+		}
+	}
+	logEvent(ctx, slog.LevelDebug, "server selected", "domain", domainName, "server", server, "fixed", len(fixedServer(ctx)) != 0)
+	return referralAddress(server), getQuery(domainName), nil
+}
+
+// maxCachedStream bounds the responses a stream keeps for ResponseCache.
+const maxCachedStream = 1 << 20
+
+// cachingStream stores what is read through it in cache once the response
+// has ended and unless it was a rate-limit notice or too large.
+type cachingStream struct {
+	io.ReadCloser
+	ctx   context.Context
+	cache Cache
+	key   string
+	res   []byte
+}
+
+func (cs *cachingStream) Read(p []byte) (int, error) {
+	n, err := cs.ReadCloser.Read(p)
+	if len(cs.key) != 0 {
+		if cs.res = append(cs.res, p[:n]...); len(cs.res) > maxCachedStream {
+			cs.key, cs.res = "", nil
+		}
+	}
+	if err == io.EOF && len(cs.key) != 0 && len(cs.res) != 0 && !isRateLimited(cs.res) {
+		cacheSet(cs.ctx, cs.cache, cs.key, cs.res)
+		cs.key = ""
+	}
+	return n, err
+}
+
+// WhoisRawStreamContext returns the registry's answer for domainName as it
+// arrives, served from and saved to ResponseCache like WhoisRawContext's.
+// Connections are retried and failed over as WhoisRawContext does them, but
+// a rate-limit notice is passed on as the answer rather than retried.
+func WhoisRawStreamContext(ctx context.Context, domainName string) (io.ReadCloser, error) {
+	domainName, err := asciiDomainName(domainName)
+	if err != nil {
+		return nil, fmt.Errorf("Whois: %w", err)
+	}
+	address, query, err := domainQuery(ctx, domainName)
+	if err != nil {
+		return nil, err
+	}
+	servers := []string{address}
+	if len(fixedServer(ctx)) == 0 {
+		servers = failoverServers(EffectiveTLD(domainName), address)
+	}
+	var rs io.ReadCloser
+	for i, server := range servers {
+		address := referralAddress(server)
+		rs, err = withRetry(ctx, func() (io.ReadCloser, error) {
+			return openRawStream(ctx, address, query)
+		})
+		if err == nil || !errors.Is(err, ErrServerUnavailable) {
+			break
+		}
+		logFailover(ctx, servers, i, err)
+	}
+	return rs, err
+}
+
+func openRawStream(ctx context.Context, address string, query []byte) (io.ReadCloser, error) {
+	cache := responseCache(ctx)
+	if cache == nil {
+		return queryServer(ctx, address, query)
+	}
+	key := cacheKey(address, query)
+	if res, ok := cacheGet(ctx, cache, key); ok {
+		return io.NopCloser(bytes.NewReader(res)), nil
+	}
+	rs, err := queryServer(ctx, address, query)
+	if err != nil {
+		return nil, err
+	}
+	return &cachingStream{ReadCloser: rs, ctx: ctx, cache: cache, key: key}, nil
+}
+
+// logFailover records that servers[i] failed with err and, if there is
+// one, which server is tried next.
+func logFailover(ctx context.Context, servers []string, i int, err error) {
+	if i+1 < len(servers) {
+		logEvent(ctx, slog.LevelInfo, "failover", "from", servers[i], "to", servers[i+1], "err", err)
+	}
+}
+
+func WhoisRawStream(domainName string) (io.ReadCloser, error) {
+	return WhoisRawStreamContext(context.Background(), domainName)
+}
+
+func WhoisRawContext(ctx context.Context, domainName string) ([]byte, error) {
+	res, _, err := WhoisRawServerContext(ctx, domainName)
+	return res, err
+}
+
+// WhoisRawServerContext is WhoisRawContext also returning the address of
+// the server that answered, for ParseServerResponse.
+func WhoisRawServerContext(ctx context.Context, domainName string) ([]byte, string, error) {
+	domainName, err := asciiDomainName(domainName)
+	if err != nil {
+		return nil, "", fmt.Errorf("Whois: %w", err)
+	}
+	address, query, err := domainQuery(ctx, domainName)
+	if err != nil {
+		return nil, "", err
+	}
+	servers := []string{address}
+	if len(fixedServer(ctx)) == 0 {
+		servers = failoverServers(EffectiveTLD(domainName), address)
+	}
+	var res []byte
+	for i, server := range servers {
+		address = referralAddress(server)
+		if res, err = queryAndRead(ctx, address, query); err == nil || !errors.Is(err, ErrServerUnavailable) {
+			break
+		}
+		logFailover(ctx, servers, i, err)
+	}
+	return res, address, err
+}
+
+func WhoisRaw(domainName string) ([]byte, error) {
+	return WhoisRawContext(context.Background(), domainName)
+}
+
+func referralAddress(server string) string {
+	if i := strings.Index(server, "://"); i >= 0 {
+		server = server[i+3:]
+	}
+	server = strings.TrimSuffix(server, "/")
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "43")
+	}
+	return server
+}
+
+func FollowReferral(ctx context.Context, wir *WhoisResponse, domainName string) error {
+	if len(wir.RegistrarWhoisServer) == 0 {
+		return nil
+	}
+	address := referralAddress(wir.RegistrarWhoisServer)
+	if server, err := WhoisServer(ctx, EffectiveTLD(domainName)); err == nil && serverHost(address) == serverHost(server) {
+		return nil
+	}
+	logEvent(ctx, slog.LevelInfo, "referral", "domain", domainName, "server", address)
+	hookReferral(ctx, domainName, address)
+	res, err := queryAndRead(ctx, address, append([]byte(domainName), crlf...))
+	if err != nil {
+		return err
+	}
+	referred, err := parseServerResponse(res, address, domainName, multiDomain(ctx))
+	if err != nil {
+		return err
+	}
+	wir.fillMissing(referred)
+	return nil
+}
+
+func WhoisContext(ctx context.Context, domainName string) (*WhoisResponse, error) {
+	domainName, err := asciiDomainName(domainName)
+	if err != nil {
+		return nil, fmt.Errorf("Whois: %w", err)
+	}
+	if IsRDAPOnly(TopLevelDomain(domainName)) {
+		return RDAPContext(ctx, domainName)
+	}
+	res, server, err := WhoisRawServerContext(ctx, domainName)
+	if err != nil {
+		return nil, err
+	}
+	wir, err := parseServerResponse(res, server, domainName, multiDomain(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if wir.IsAvailable() {
+		return nil, fmt.Errorf("Whois: %w: %s", ErrNoSuchDomain, domainName)
+	}
+	if followReferrals(ctx) {
+		// The registry answer stands on its own when the registrar
+		// server is unreachable.
+		FollowReferral(ctx, wir, domainName)
+	}
+	wir.InferDomainName(domainName)
+	return wir, nil
+}
+
+func Whois(domainName string) (*WhoisResponse, error) {
+	return WhoisContext(context.Background(), domainName)
+}
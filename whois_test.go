@@ -0,0 +1,307 @@
+package qwis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkorotkov/qwis/internal/whoistest"
+)
+
+// useDial routes the package's connections through d for the duration of
+// the test, without a response cache or a fixed server.
+func useDial(t *testing.T, d DialFunc) {
+	t.Helper()
+	dial, server, cache, retry := Dial, Server, ResponseCache, Retry
+	Dial, Server, ResponseCache, Retry = d, "", nil, DefaultRetryPolicy
+	ResetWhoisServers()
+	t.Cleanup(func() {
+		Dial, Server, ResponseCache, Retry = dial, server, cache, retry
+		ResetWhoisServers()
+	})
+}
+
+// closeRecorder records whether the connection was closed.
+type closeRecorder struct {
+	net.Conn
+	closed chan struct{}
+}
+
+func (c *closeRecorder) Close() error {
+	close(c.closed)
+	return c.Conn.Close()
+}
+
+func TestWhoisRawStream(t *testing.T) {
+	resp := strings.Repeat("Domain Name: EXAMPLE.COM\r\n", 10000)
+	conn := &closeRecorder{closed: make(chan struct{})}
+	useDial(t, func(ctx context.Context, network, address string) (net.Conn, error) {
+		if address != "whois.verisign-grs.com:43" {
+			return nil, errors.New("connection refused")
+		}
+		c, s := net.Pipe()
+		go func() {
+			bufio.NewReader(s).ReadString('\n')
+			io.WriteString(s, resp)
+			// Hold the connection open: only the reader's Close ends it.
+			io.Copy(io.Discard, s)
+		}()
+		conn.Conn = c
+		return conn, nil
+	})
+	rs, err := WhoisRawStream("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, len(resp))
+	if _, err = io.ReadFull(rs, got); err != nil || string(got) != resp {
+		t.Fatalf("read %d bytes, %v", len(got), err)
+	}
+	select {
+	case <-conn.closed:
+		t.Fatal("connection closed before the stream")
+	default:
+	}
+	if err = rs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-conn.closed:
+	default:
+		t.Error("Close did not close the connection")
+	}
+}
+
+func TestWhoisRawStreamCancel(t *testing.T) {
+	useDial(t, func(ctx context.Context, network, address string) (net.Conn, error) {
+		if address != "whois.verisign-grs.com:43" {
+			return nil, errors.New("connection refused")
+		}
+		c, s := net.Pipe()
+		go func() {
+			bufio.NewReader(s).ReadString('\n')
+			io.WriteString(s, "Domain Name: EXAMPLE.COM\r\n")
+			io.Copy(io.Discard, s)
+		}()
+		return c, nil
+	})
+	defer func(rt time.Duration) { ReadTimeout = rt }(ReadTimeout)
+	ReadTimeout = time.Minute
+	ctx, cancel := context.WithCancel(context.Background())
+	rs, err := WhoisRawStreamContext(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+	if _, err = rs.Read(make([]byte, 64)); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	// Let the cancellation deadline land before reading again.
+	time.Sleep(10 * time.Millisecond)
+	done := make(chan error, 1)
+	go func() {
+		_, err := rs.Read(make([]byte, 64))
+		done <- err
+	}()
+	select {
+	case err = <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Read after cancel = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Read blocked after cancel")
+	}
+}
+
+func TestWhoisRawStreamCache(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": "Domain Name: EXAMPLE.COM\r\n"}}
+	useDial(t, fs.Dial)
+	ResponseCache = NewMemoryCache(time.Hour)
+	for i := 0; i < 2; i++ {
+		rs, err := WhoisRawStream("example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := io.ReadAll(rs)
+		rs.Close()
+		if err != nil || string(res) != "Domain Name: EXAMPLE.COM\r\n" {
+			t.Fatalf("read %q, %v", res, err)
+		}
+	}
+	// The streamed answer is what WhoisRaw finds in the cache too.
+	if res, err := WhoisRaw("example.com"); err != nil || string(res) != "Domain Name: EXAMPLE.COM\r\n" {
+		t.Errorf("WhoisRaw = %q, %v", res, err)
+	}
+	var servers []string
+	for _, a := range fs.Dialed {
+		if a == "whois.verisign-grs.com:43" {
+			servers = append(servers, a)
+		}
+	}
+	if len(servers) != 1 {
+		t.Errorf("dialed the registry %d times, want once", len(servers))
+	}
+}
+
+func TestWhoisRawStreamRetryAndFailover(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"com.whois-servers.net:43": "Domain Name: EXAMPLE.COM\r\n"}}
+	useDial(t, fs.Dial)
+	Retry = RetryPolicy{Attempts: 2, Backoff: time.Millisecond}
+	rs, err := WhoisRawStream("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := io.ReadAll(rs)
+	rs.Close()
+	if err != nil || string(res) != "Domain Name: EXAMPLE.COM\r\n" {
+		t.Fatalf("read %q, %v", res, err)
+	}
+	var primary int
+	for _, a := range fs.Dialed {
+		if a == "whois.verisign-grs.com:43" {
+			primary++
+		}
+	}
+	if primary != 2 {
+		t.Errorf("primary dialed %d times, want 2 (one retry): %q", primary, fs.Dialed)
+	}
+}
+
+func TestWhoisOverTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	srv.Close()
+	var dialed []string
+	useDial(t, func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = append(dialed, address)
+		c, s := net.Pipe()
+		go func() {
+			ts := tls.Server(s, srv.TLS)
+			defer ts.Close()
+			if _, err := bufio.NewReader(ts).ReadString('\n'); err == nil {
+				io.WriteString(ts, "Domain Name: EXAMPLE.COM\r\n")
+			}
+		}()
+		return c, nil
+	})
+	// The test certificate is issued for example.com.
+	Server, WhoisTLS = "example.com", true
+	defer func() { WhoisTLS, RootCAs, InsecureSkipVerify = false, nil, false }()
+	if _, err := WhoisRawContext(context.Background(), "example.com"); !errors.Is(err, ErrServerUnavailable) {
+		t.Errorf("untrusted certificate: err = %v, want ErrServerUnavailable", err)
+	}
+	RootCAs = x509.NewCertPool()
+	RootCAs.AddCert(srv.Certificate())
+	if res, err := WhoisRawContext(context.Background(), "example.com"); err != nil || !strings.Contains(string(res), "EXAMPLE.COM") {
+		t.Errorf("trusted certificate: %q, %v", res, err)
+	}
+	RootCAs, InsecureSkipVerify = nil, true
+	if res, err := WhoisRawContext(context.Background(), "example.com"); err != nil || !strings.Contains(string(res), "EXAMPLE.COM") {
+		t.Errorf("InsecureSkipVerify: %q, %v", res, err)
+	}
+	if dialed[0] != "example.com:853" {
+		t.Errorf("dialed %q, want example.com:853", dialed)
+	}
+}
+
+func TestWhoisNetwork(t *testing.T) {
+	fs := &whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": "Domain Name: EXAMPLE.COM\r\n"}}
+	var networks []string
+	useDial(t, func(ctx context.Context, network, address string) (net.Conn, error) {
+		networks = append(networks, network)
+		return fs.Dial(ctx, network, address)
+	})
+	defer func(n string) { Network = n }(Network)
+	for _, n := range []string{"tcp", "tcp6", "tcp4"} {
+		Network, networks = n, nil
+		if _, err := Whois("example.com"); err != nil {
+			t.Fatal(err)
+		}
+		for _, got := range networks {
+			if got != n {
+				t.Errorf("Network %s: dialed %q", n, networks)
+				break
+			}
+		}
+	}
+}
+
+// floodDial answers every query to the .com server with line, repeated
+// until the connection is closed, and refuses connections to other servers.
+func floodDial(dials *int32, line string) DialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if address != "whois.verisign-grs.com:43" {
+			return nil, errors.New("connection refused")
+		}
+		atomic.AddInt32(dials, 1)
+		c, s := net.Pipe()
+		go func() {
+			bufio.NewReader(s).ReadString('\n')
+			for {
+				if _, err := io.WriteString(s, line); err != nil {
+					return
+				}
+			}
+		}()
+		return c, nil
+	}
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	var dials int32
+	useDial(t, floodDial(&dials, "Domain Name: EXAMPLE.COM\r\n"))
+	defer func(n int64) { MaxResponseSize = n }(MaxResponseSize)
+	MaxResponseSize = 10000
+	_, err := WhoisRawContext(context.Background(), "example.com")
+	var se *ServerError
+	if !errors.Is(err, ErrResponseTooLarge) || !errors.As(err, &se) || se.Server != "whois.verisign-grs.com:43" {
+		t.Errorf("WhoisRawContext = %v, want ErrResponseTooLarge from whois.verisign-grs.com:43", err)
+	}
+	if dials != 1 {
+		t.Errorf("dialed %d times; a response too large is not retried", dials)
+	}
+	rs, err := WhoisRawStreamContext(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+	res, err := io.ReadAll(rs)
+	if !errors.Is(err, ErrResponseTooLarge) || len(res) != 10000 {
+		t.Errorf("stream read %d bytes, %v; want 10000 and ErrResponseTooLarge", len(res), err)
+	}
+
+	// A line without end is cut off as well when connections are reused.
+	useDial(t, floodDial(&dials, "x"))
+	ReuseConnections = true
+	t.Cleanup(func() {
+		ReuseConnections = false
+		CloseIdleConnections()
+	})
+	if _, err = WhoisRawContext(context.Background(), "example.com"); !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("WhoisRawContext reusing connections = %v, want ErrResponseTooLarge", err)
+	}
+}
+
+func TestMaxResponseSizeExact(t *testing.T) {
+	resp := strings.Repeat("x", 9998) + "\r\n"
+	useDial(t, (&whoistest.FakeServers{Responses: map[string]string{"whois.verisign-grs.com:43": resp}}).Dial)
+	defer func(n int64) { MaxResponseSize = n }(MaxResponseSize)
+	MaxResponseSize = int64(len(resp))
+	if res, err := WhoisRawContext(context.Background(), "example.com"); err != nil || string(res) != resp {
+		t.Errorf("WhoisRawContext = %d bytes, %v; want the whole response", len(res), err)
+	}
+	MaxResponseSize = 0
+	if _, err := WhoisRawContext(context.Background(), "example.com"); err != nil {
+		t.Errorf("WhoisRawContext without a bound = %v", err)
+	}
+}